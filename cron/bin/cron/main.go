@@ -10,6 +10,9 @@ func main() {
 
 	container := services.NewContainer()
 	container.UsedRefreshTokenService.StartRemovingUsedRefreshTokenCronAsync()
+	container.AccountDeletionService.StartDeletingScheduledAccountsCronAsync()
+	container.MailOutboxService.StartDeliveringOutboxMailsCronAsync()
+	container.ExpenseDigestService.StartSendingExpenseDigestsCronAsync()
 
 	fmt.Println("Press ctrl+C to exit")
 	<-forever
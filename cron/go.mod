@@ -2,7 +2,10 @@ module nft-raffle-cron
 
 go 1.20
 
-require github.com/go-co-op/gocron v1.19.0
+require (
+	github.com/go-co-op/gocron v1.19.0
+	nft-raffle-mail v0.0.0
+)
 
 require (
 	github.com/golang/snappy v0.0.1 // indirect
@@ -26,3 +29,5 @@ require (
 	go.uber.org/zap v1.24.0
 	golang.org/x/sync v0.1.0 // indirect
 )
+
+replace nft-raffle-mail => ../mail
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExpenseDigest claims the weekly digest of a week for the user, user_id and week_start are unique,
+// so a digest is queued once even when several cron instances run or a run stopped halfway is resumed
+type ExpenseDigest struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Expense_digest_id string             `json:"expense_digest_id" bson:"expense_digest_id"`
+	User_id           string             `json:"user_id" bson:"user_id"`
+	Week_start        time.Time          `json:"week_start" bson:"week_start"`
+	Is_sent           bool               `json:"is_sent" bson:"is_sent"`
+	Created_at        time.Time          `json:"created_at" bson:"created_at"`
+}
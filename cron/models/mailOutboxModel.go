@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MailAddress struct {
+	Name    string `json:"name" bson:"name"`
+	Address string `json:"address" bson:"address"`
+}
+
+type MailOutbox struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Mail_outbox_id  string             `json:"mail_outbox_id" bson:"mail_outbox_id"`
+	Mail_type       string             `json:"mail_type" bson:"mail_type"`
+	User_id         string             `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	From_name       string             `json:"from_name" bson:"from_name"`
+	From_email      string             `json:"from_email" bson:"from_email"`
+	Tos             []MailAddress      `json:"tos" bson:"tos"`
+	Locale          string             `json:"locale" bson:"locale"`
+	Template_data   map[string]string  `json:"template_data" bson:"template_data"`
+	Subject         string             `json:"subject" bson:"subject"`
+	Text_body       string             `json:"text_body" bson:"text_body"`
+	Html_body       string             `json:"html_body" bson:"html_body"`
+	Headers         map[string]string  `json:"headers" bson:"headers,omitempty"`
+	Status          string             `json:"status" bson:"status"`
+	Attempts        int                `json:"attempts" bson:"attempts"`
+	Next_attempt_at time.Time          `json:"next_attempt_at" bson:"next_attempt_at"`
+	Locked_until    *time.Time         `json:"locked_until,omitempty" bson:"locked_until,omitempty"`
+	Last_error      string             `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	Created_at      time.Time          `json:"created_at" bson:"created_at"`
+	Updated_at      time.Time          `json:"updated_at" bson:"updated_at"`
+	Sent_at         *time.Time         `json:"sent_at,omitempty" bson:"sent_at,omitempty"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// User only maps the fields the cron jobs need, the full document is owned by the server module
+type User struct {
+	User_id               string     `json:"user_id" bson:"user_id"`
+	First_name            string     `json:"first_name" bson:"first_name"`
+	Last_name             string     `json:"last_name" bson:"last_name"`
+	Email                 string     `json:"email" bson:"email"`
+	Locale                string     `json:"locale" bson:"locale"`
+	Timezone              string     `json:"timezone" bson:"timezone"`
+	Deletion_scheduled_at *time.Time `json:"deletion_scheduled_at" bson:"deletion_scheduled_at"`
+}
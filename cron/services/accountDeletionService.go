@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"nft-raffle-cron/database"
+	"nft-raffle-cron/logger"
+	"nft-raffle-cron/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	USER             = "user"
+	EXPENSE          = "expense"
+	MAIL             = "mail"
+	ADMIN_ACTION_LOG = "adminActionLog"
+	API_KEY          = "apiKey"
+	MAIL_SUPPRESSION = "mailSuppression"
+	EXPENSE_DIGEST   = "expenseDigest"
+)
+
+var (
+	accountDeletionService     *AccountDeletionService
+	accountDeletionServiceOnce sync.Once
+)
+
+type AccountDeletionService struct {
+	nftRaffleMongoDb *database.NftRaffleMongoDb
+}
+
+func GetAccountDeletionService(nftRaffleMongoDb *database.NftRaffleMongoDb) *AccountDeletionService {
+	if accountDeletionService == nil {
+		accountDeletionServiceOnce.Do(func() {
+			accountDeletionService = &AccountDeletionService{
+				nftRaffleMongoDb: nftRaffleMongoDb,
+			}
+		})
+	}
+	return accountDeletionService
+}
+
+func (s *AccountDeletionService) StartDeletingScheduledAccountsCronAsync() {
+	loc, err := timeUtil.GetCurrentLocation()
+	if err != nil {
+		logger.Logger.Panic("unable to load current location")
+	}
+	scheduler := gocron.NewScheduler(loc)
+	scheduler.Every(1).Hour().Do(s.DeleteScheduledAccounts)
+	scheduler.StartAsync()
+}
+
+// DeleteScheduledAccounts removes the owned data of every account whose grace period has passed.
+// The user document is kept as an anonymized tombstone so the user_id is never reused and
+// retained records such as the admin action log still point to a valid id.
+// Tokens were revoked when the deletion was requested, the tombstone is disabled so no new ones are issued.
+func (s *AccountDeletionService) DeleteScheduledAccounts() {
+	client := s.nftRaffleMongoDb.GetClient()
+	userCollection := s.nftRaffleMongoDb.OpenCollection(client, USER)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cursor, err := userCollection.Find(ctx, bson.D{{Key: "deletion_scheduled_at", Value: bson.D{
+		{Key: "$lte", Value: time.Now()},
+	}}})
+
+	if err != nil {
+		logger.Logger.Warn(fmt.Sprintf("error occured when finding accounts scheduled for deletion: %v", err.Error()))
+		return
+	}
+
+	var users []models.User
+
+	if err := cursor.All(ctx, &users); err != nil {
+		logger.Logger.Warn(fmt.Sprintf("error occured when decoding accounts scheduled for deletion: %v", err.Error()))
+		return
+	}
+
+	for _, user := range users {
+		if err := s.deleteAccount(user); err != nil {
+			// retried on the next run
+			logger.Logger.Warn(fmt.Sprintf("error occured when deleting account %s: %v", user.User_id, err.Error()))
+			continue
+		}
+
+		logger.Logger.Info(fmt.Sprintf("account %s has been deleted", user.User_id))
+	}
+}
+
+func (s *AccountDeletionService) deleteAccount(user models.User) error {
+	client := s.nftRaffleMongoDb.GetClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	return client.UseSession(ctx, func(sessionContext mongo.SessionContext) error {
+		err := sessionContext.StartTransaction()
+		if err != nil {
+			return err
+		}
+
+		_, err = s.nftRaffleMongoDb.OpenCollection(client, EXPENSE).DeleteMany(sessionContext, bson.M{"user_id": user.User_id})
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		_, err = s.nftRaffleMongoDb.OpenCollection(client, EXPENSE_DIGEST).DeleteMany(sessionContext, bson.M{"user_id": user.User_id})
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		_, err = s.nftRaffleMongoDb.OpenCollection(client, API_KEY).DeleteMany(sessionContext, bson.M{"user_id": user.User_id})
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		_, err = s.nftRaffleMongoDb.OpenCollection(client, MAIL).DeleteMany(sessionContext, bson.M{"$or": bson.A{
+			bson.M{"user_id": user.User_id},
+			bson.M{"email": user.Email},
+		}})
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		// queued mails still carry the address and the template data, redirected mails only carry the user id,
+		// mails queued before the user id was recorded are found by address
+		_, err = s.nftRaffleMongoDb.OpenCollection(client, MAIL_OUTBOX).DeleteMany(sessionContext, bson.M{"$or": []bson.M{
+			{"user_id": user.User_id},
+			{"tos.address": user.Email},
+		}})
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		_, err = s.nftRaffleMongoDb.OpenCollection(client, MAIL_SUPPRESSION).DeleteMany(sessionContext, bson.M{"email": strings.ToLower(user.Email)})
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		// free text written by admins may mention the user
+		_, err = s.nftRaffleMongoDb.OpenCollection(client, ADMIN_ACTION_LOG).UpdateMany(
+			sessionContext,
+			bson.M{"target_user_id": user.User_id},
+			bson.D{{Key: "$unset", Value: bson.D{{Key: "details", Value: ""}}}},
+		)
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		now := time.Now()
+
+		_, err = s.nftRaffleMongoDb.OpenCollection(client, USER).UpdateOne(
+			sessionContext,
+			bson.M{"user_id": user.User_id},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "first_name", Value: "Deleted"},
+					{Key: "last_name", Value: "User"},
+					{Key: "email", Value: fmt.Sprintf("deleted-%s@deleted.invalid", user.User_id)},
+					{Key: "phone", Value: ""},
+					{Key: "is_phone_verified", Value: false},
+					{Key: "password", Value: ""},
+					{Key: "access_token", Value: ""},
+					{Key: "refresh_token", Value: ""},
+					{Key: "avatar_url", Value: ""},
+					{Key: "is_disabled", Value: true},
+					{Key: "is_mfa_enabled", Value: false},
+					{Key: "deleted_at", Value: now},
+					{Key: "updated_at", Value: now},
+				}},
+				{Key: "$unset", Value: bson.D{
+					{Key: "deletion_scheduled_at", Value: ""},
+					{Key: "mfa_secret", Value: ""},
+					{Key: "mfa_pending_secret", Value: ""},
+					{Key: "mfa_recovery_code_hashes", Value: ""},
+					{Key: "oidc_identities", Value: ""},
+				}},
+			},
+		)
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		return sessionContext.CommitTransaction(sessionContext)
+	})
+}
@@ -0,0 +1,457 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nft-raffle-cron/database"
+	"nft-raffle-cron/logger"
+	"nft-raffle-cron/models"
+	"nft-raffle-mail/actionlink"
+	"nft-raffle-mail/expensedigest"
+	"nft-raffle-mail/mailtemplate"
+	"nft-raffle-mail/mailtransport"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	WEEKLY_EXPENSE_DIGEST_MAIL = "WeeklyExpenseDigest"
+	// mail category of the digest in the mail preferences of the user
+	DIGEST_MAIL_CATEGORY = "digest"
+
+	// local hour of the monday the digest of the week before is due
+	expenseDigestSendHour = 8
+	// categories listed in the digest, biggest spend first
+	expenseDigestTopCategories = 3
+	expenseDigestDateFormat    = "2006-01-02"
+	// mail clients may call the unsubscribe link long after the mail was sent
+	unsubscribeLinkExpiration = 90 * 24 * time.Hour
+)
+
+var (
+	expenseDigestService     *ExpenseDigestService
+	expenseDigestServiceOnce sync.Once
+
+	errExpenseDigestClaimed = errors.New("expense digest of the week is already claimed")
+)
+
+type ExpenseDigestService struct {
+	nftRaffleMongoDb     *database.NftRaffleMongoDb
+	mailTemplateRenderer *mailtemplate.MailTemplateRenderer
+	actionLink           *actionlink.ActionLink
+	recipientPolicy      *mailtransport.RecipientPolicy
+	fromName             string
+	fromEmail            string
+}
+
+func GetExpenseDigestService(nftRaffleMongoDb *database.NftRaffleMongoDb) *ExpenseDigestService {
+	if expenseDigestService == nil {
+		expenseDigestServiceOnce.Do(func() {
+			mailTemplateRenderer, err := mailtemplate.NewMailTemplateRenderer()
+
+			if err != nil {
+				logger.Logger.Panic(fmt.Sprintf("unable to parse the mail templates: %v", err.Error()))
+			}
+
+			expenseDigestService = &ExpenseDigestService{
+				nftRaffleMongoDb:     nftRaffleMongoDb,
+				mailTemplateRenderer: mailTemplateRenderer,
+				actionLink:           actionlink.NewActionLinkFromEnv(dotEnvUtil.GetEnvVariable),
+				recipientPolicy:      mailtransport.NewRecipientPolicyFromEnv(dotEnvUtil.GetEnvVariable),
+				fromName:             dotEnvUtil.GetEnvVariable("SENDGRID_FROM_NAME"),
+				fromEmail:            dotEnvUtil.GetEnvVariable("SENDGRID_FROM_EMAIL"),
+			}
+		})
+	}
+	return expenseDigestService
+}
+
+// StartSendingExpenseDigestsCronAsync checks every hour, the monday morning arrives at a different hour in each timezone
+func (s *ExpenseDigestService) StartSendingExpenseDigestsCronAsync() {
+	loc, err := timeUtil.GetCurrentLocation()
+	if err != nil {
+		logger.Logger.Panic("unable to load current location")
+	}
+
+	if err := s.EnsureExpenseDigestIndexes(); err != nil {
+		logger.Logger.Panic(fmt.Sprintf("unable to create the expense digest indexes: %v", err.Error()))
+	}
+
+	scheduler := gocron.NewScheduler(loc)
+	scheduler.Every(1).Hour().SingletonMode().Do(s.SendDueExpenseDigests)
+	scheduler.StartAsync()
+}
+
+// EnsureExpenseDigestIndexes makes the digest record the claim on the week, a second insert for the same week fails
+func (s *ExpenseDigestService) EnsureExpenseDigestIndexes() error {
+	client := s.nftRaffleMongoDb.GetClient()
+	expenseDigestCollection := s.nftRaffleMongoDb.OpenCollection(client, EXPENSE_DIGEST)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	_, err := expenseDigestCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "week_start", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	return err
+}
+
+// SendDueExpenseDigests queues the digest of every opted-in user whose last week ended and has no digest record yet.
+// Users are handled one by one, so a run that stops halfway is finished by the next one.
+func (s *ExpenseDigestService) SendDueExpenseDigests() {
+	client := s.nftRaffleMongoDb.GetClient()
+	userCollection := s.nftRaffleMongoDb.OpenCollection(client, USER)
+
+	loc, err := timeUtil.GetCurrentLocation()
+
+	if err != nil {
+		logger.Logger.Warn(fmt.Sprintf("error occured when loading the current location: %v", err.Error()))
+		return
+	}
+
+	ctx := context.Background()
+
+	// the digest is only sent to users who turned it on, nil matches both a missing and a null deletion_scheduled_at
+	filter := bson.M{
+		"is_disabled":                              bson.M{"$ne": true},
+		"is_email_verified":                        true,
+		"deletion_scheduled_at":                    nil,
+		"mail_preferences." + DIGEST_MAIL_CATEGORY: true,
+	}
+
+	cursor, err := userCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "user_id", Value: 1}}))
+
+	if err != nil {
+		logger.Logger.Warn(fmt.Sprintf("error occured when finding users for the expense digest: %v", err.Error()))
+		return
+	}
+
+	defer cursor.Close(ctx)
+
+	queuedCount := 0
+
+	for cursor.Next(ctx) {
+		var user models.User
+
+		if err := cursor.Decode(&user); err != nil {
+			logger.Logger.Warn(fmt.Sprintf("error occured when decoding user for the expense digest: %v", err.Error()))
+			continue
+		}
+
+		weekStart, isDue := ExpenseDigestWeek(time.Now().In(userLocation(user, loc)))
+
+		if !isDue {
+			continue
+		}
+
+		isQueued, err := s.sendExpenseDigest(user, weekStart)
+
+		if err != nil {
+			// the claim is rolled back with the transaction, retried on the next run
+			logger.Logger.Warn(fmt.Sprintf("error occured when sending the expense digest of user %s: %v", user.User_id, err.Error()))
+			continue
+		}
+
+		if isQueued {
+			queuedCount++
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		logger.Logger.Warn(fmt.Sprintf("error occured when iterating users for the expense digest: %v", err.Error()))
+	}
+
+	logger.Logger.Info(fmt.Sprintf("%d expense digest mails have been queued", queuedCount))
+}
+
+// sendExpenseDigest claims the week by inserting its digest record first, the unique index lets one run win.
+// The claim and the queued mail are committed together, a failed mail releases the claim.
+func (s *ExpenseDigestService) sendExpenseDigest(user models.User, weekStart time.Time) (bool, error) {
+	client := s.nftRaffleMongoDb.GetClient()
+	expenseDigestCollection := s.nftRaffleMongoDb.OpenCollection(client, EXPENSE_DIGEST)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	now := time.Now()
+
+	var digest models.ExpenseDigest
+	digest.ID = primitive.NewObjectID()
+	digest.Expense_digest_id = digest.ID.Hex()
+	digest.User_id = user.User_id
+	digest.Week_start = weekStart
+	digest.Created_at = now
+
+	isQueued := false
+
+	err := client.UseSession(ctx, func(sessionContext mongo.SessionContext) error {
+		err := sessionContext.StartTransaction()
+		if err != nil {
+			return err
+		}
+
+		_, err = expenseDigestCollection.InsertOne(sessionContext, digest)
+
+		if mongo.IsDuplicateKeyError(err) {
+			sessionContext.AbortTransaction(sessionContext)
+			return errExpenseDigestClaimed
+		}
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		// the aggregation bounds are inclusive
+		currentWeek, err := s.aggregateExpensesByType(sessionContext, user.User_id, weekStart, weekStart.AddDate(0, 0, 7).Add(-time.Nanosecond))
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		previousWeek, err := s.aggregateExpensesByType(sessionContext, user.User_id, weekStart.AddDate(0, 0, -7), weekStart.Add(-time.Nanosecond))
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		// nothing to summarize, the claim is kept so the week is not checked again
+		if len(currentWeek) < 1 && len(previousWeek) < 1 {
+			return sessionContext.CommitTransaction(sessionContext)
+		}
+
+		templateData := BuildExpenseDigestTemplateData(currentWeek, previousWeek)
+		templateData["Full_Name"] = user.First_name + " " + user.Last_name
+		templateData["Week_Start"] = weekStart.Format(expenseDigestDateFormat)
+		templateData["Week_End"] = weekStart.AddDate(0, 0, 6).Format(expenseDigestDateFormat)
+
+		isQueued, err = s.queueExpenseDigestMail(sessionContext, user, templateData)
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		if isQueued {
+			_, err = expenseDigestCollection.UpdateOne(
+				sessionContext,
+				bson.M{"expense_digest_id": digest.Expense_digest_id},
+				bson.D{{Key: "$set", Value: bson.D{{Key: "is_sent", Value: true}}}},
+			)
+
+			if err != nil {
+				sessionContext.AbortTransaction(sessionContext)
+				return err
+			}
+		}
+
+		return sessionContext.CommitTransaction(sessionContext)
+	})
+
+	// already sent by an earlier run or by another cron instance
+	if errors.Is(err, errExpenseDigestClaimed) {
+		return false, nil
+	}
+
+	return isQueued && err == nil, err
+}
+
+// queueExpenseDigestMail writes the rendered digest to the outbox like the mail service of the server,
+// suppressed addresses and the recipient policy are applied and the unsubscribe link is added
+func (s *ExpenseDigestService) queueExpenseDigestMail(ctx context.Context, user models.User, templateData map[string]string) (bool, error) {
+	client := s.nftRaffleMongoDb.GetClient()
+
+	suppressedCount, err := s.nftRaffleMongoDb.OpenCollection(client, MAIL_SUPPRESSION).CountDocuments(ctx, bson.M{"email": strings.ToLower(user.Email)})
+
+	if err != nil {
+		return false, err
+	}
+
+	if suppressedCount > 0 {
+		logger.Logger.Warn(fmt.Sprintf("%s mail skipped, the address of user %s is suppressed", WEEKLY_EXPENSE_DIGEST_MAIL, user.User_id))
+		return false, nil
+	}
+
+	unsubscribeLink, err := s.actionLink.GenerateLink(actionlink.UnsubscribePath, actionlink.UnsubscribePurpose, user.User_id, DIGEST_MAIL_CATEGORY, time.Now().Add(unsubscribeLinkExpiration), nil)
+
+	if err != nil {
+		return false, err
+	}
+
+	templateData["Unsubscribe_Link"] = unsubscribeLink
+
+	headers := map[string]string{
+		"List-Unsubscribe":      "<" + unsubscribeLink + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+
+	renderedMail, err := s.mailTemplateRenderer.Render(WEEKLY_EXPENSE_DIGEST_MAIL, user.Locale, templateData)
+
+	if err != nil {
+		return false, err
+	}
+
+	fullName := user.First_name + " " + user.Last_name
+	recipients, originalTos := s.recipientPolicy.Apply([]mailtransport.Address{{Name: fullName, Address: user.Email}})
+
+	if len(recipients) < 1 {
+		logger.Logger.Info(fmt.Sprintf("%s mail skipped, the recipient policy allows none of the recipients", WEEKLY_EXPENSE_DIGEST_MAIL))
+		return false, nil
+	}
+
+	if len(originalTos) > 0 {
+		headers[mailtransport.OriginalRecipientHeader] = strings.Join(originalTos, ", ")
+	}
+
+	now := time.Now()
+
+	var outboxMail models.MailOutbox
+	outboxMail.ID = primitive.NewObjectID()
+	outboxMail.Mail_outbox_id = outboxMail.ID.Hex()
+	outboxMail.Mail_type = WEEKLY_EXPENSE_DIGEST_MAIL
+	// the recipient policy may redirect the mail, the account deletion finds it by user
+	outboxMail.User_id = user.User_id
+	outboxMail.From_name = s.fromName
+	outboxMail.From_email = s.fromEmail
+	outboxMail.Template_data = templateData
+	outboxMail.Locale = renderedMail.Locale
+	outboxMail.Subject = renderedMail.Subject
+	outboxMail.Text_body = renderedMail.TextBody
+	outboxMail.Html_body = renderedMail.HtmlBody
+	outboxMail.Headers = headers
+	outboxMail.Status = MAIL_OUTBOX_PENDING
+	outboxMail.Created_at = now
+	outboxMail.Updated_at = now
+	outboxMail.Next_attempt_at = now
+
+	for _, recipient := range recipients {
+		outboxMail.Tos = append(outboxMail.Tos, models.MailAddress{Name: recipient.Name, Address: recipient.Address})
+	}
+
+	if _, err := s.nftRaffleMongoDb.OpenCollection(client, MAIL_OUTBOX).InsertOne(ctx, outboxMail); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// aggregateExpensesByType groups the expenses of the user between both dates by type, biggest type first
+func (s *ExpenseDigestService) aggregateExpensesByType(ctx context.Context, userId string, fromDate time.Time, toDate time.Time) ([]expensedigest.ExpenseType, error) {
+	client := s.nftRaffleMongoDb.GetClient()
+	expenseCollection := s.nftRaffleMongoDb.OpenCollection(client, EXPENSE)
+
+	cursor, err := expenseCollection.Aggregate(ctx, expensedigest.ExpensesByTypePipeline(userId, fromDate, toDate))
+
+	if err != nil {
+		return nil, err
+	}
+
+	var expensesByType []expensedigest.ExpensesByType
+
+	if err := cursor.All(ctx, &expensesByType); err != nil {
+		return nil, err
+	}
+
+	if len(expensesByType) == 0 {
+		return nil, nil
+	}
+
+	return expensesByType[0].Data, nil
+}
+
+// ExpenseDigestWeek returns the start of the week before localNow, in the location of localNow,
+// the digest of that week is due from monday at expenseDigestSendHour
+func ExpenseDigestWeek(localNow time.Time) (time.Time, bool) {
+	year, month, day := localNow.Date()
+	daysSinceMonday := (int(localNow.Weekday()) + 6) % 7
+	currentWeekStart := time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, localNow.Location())
+
+	isDue := !localNow.Before(currentWeekStart.Add(time.Duration(expenseDigestSendHour) * time.Hour))
+
+	return currentWeekStart.AddDate(0, 0, -7), isDue
+}
+
+// BuildExpenseDigestTemplateData summarizes the expense types of the week against the ones of the week before
+func BuildExpenseDigestTemplateData(currentWeek []expensedigest.ExpenseType, previousWeek []expensedigest.ExpenseType) map[string]string {
+	var totalSpend, previousTotalSpend, expenseCount int64
+	var topCategories []string
+	var largestLabel, largestType string
+	var largestAmount int64
+	hasLargest := false
+
+	for i, expenseType := range currentWeek {
+		totalSpend += expenseType.ExpenseAmountByType
+		expenseCount += expenseType.ExpenseCountByType
+
+		// the aggregation sorts the types by amount already
+		if i < expenseDigestTopCategories {
+			topCategories = append(topCategories, fmt.Sprintf("%s %d", expenseType.Expense_type, expenseType.ExpenseAmountByType))
+		}
+
+		for _, expense := range expenseType.ExpenseTypeData {
+			if !hasLargest || expense.Expense_amount > largestAmount {
+				hasLargest = true
+				largestLabel = expense.Expense_label
+				largestType = expense.Expense_type
+				largestAmount = expense.Expense_amount
+			}
+		}
+	}
+
+	for _, expenseType := range previousWeek {
+		previousTotalSpend += expenseType.ExpenseAmountByType
+	}
+
+	templateData := map[string]string{
+		"Total_Spend":          strconv.FormatInt(totalSpend, 10),
+		"Previous_Total_Spend": strconv.FormatInt(previousTotalSpend, 10),
+		"Spend_Change":         spendChange(totalSpend, previousTotalSpend),
+		"Expense_Count":        strconv.FormatInt(expenseCount, 10),
+		"Top_Categories":       strings.Join(topCategories, ", "),
+	}
+
+	// left out when the week has no expense, the templates hide the line
+	if hasLargest {
+		templateData["Largest_Expense_Label"] = largestLabel
+		templateData["Largest_Expense_Type"] = largestType
+		templateData["Largest_Expense_Amount"] = strconv.FormatInt(largestAmount, 10)
+	}
+
+	return templateData
+}
+
+func spendChange(totalSpend int64, previousTotalSpend int64) string {
+	if previousTotalSpend == 0 {
+		return "n/a"
+	}
+
+	return fmt.Sprintf("%+.1f%%", float64(totalSpend-previousTotalSpend)*100/float64(previousTotalSpend))
+}
+
+// userLocation is the IANA timezone of the user, or the cron location when it is unset or unknown
+func userLocation(user models.User, fallback *time.Location) *time.Location {
+	if user.Timezone == "" {
+		return fallback
+	}
+
+	loc, err := time.LoadLocation(user.Timezone)
+
+	if err != nil {
+		return fallback
+	}
+
+	return loc
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"nft-raffle-cron/database"
+	"nft-raffle-cron/logger"
+	"nft-raffle-cron/models"
+	"nft-raffle-cron/utils"
+	"nft-raffle-mail/mailtransport"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	MAIL_OUTBOX = "mailOutbox"
+
+	MAIL_OUTBOX_PENDING = "PENDING"
+	MAIL_OUTBOX_SENDING = "SENDING"
+	MAIL_OUTBOX_SENT    = "SENT"
+	MAIL_OUTBOX_DEAD    = "DEAD"
+
+	// a mail claimed by a worker that crashed is picked up again after the lock expires
+	mailOutboxLockDuration = 5 * time.Minute
+	// mails delivered in one run, the rest wait for the next run
+	mailOutboxBatchSize   = 50
+	mailOutboxBaseBackoff = 30 * time.Second
+	mailOutboxMaxBackoff  = 6 * time.Hour
+)
+
+var (
+	mailOutboxService     *MailOutboxService
+	mailOutboxServiceOnce sync.Once
+
+	dotEnvUtil = utils.GetDotEnvUtil()
+)
+
+type MailOutboxService struct {
+	nftRaffleMongoDb *database.NftRaffleMongoDb
+	mailTransport    mailtransport.MailTransport
+	maxAttempts      int
+	retentionDays    int
+}
+
+func GetMailOutboxService(nftRaffleMongoDb *database.NftRaffleMongoDb) *MailOutboxService {
+	if mailOutboxService == nil {
+		mailOutboxServiceOnce.Do(func() {
+			maxAttempts, err := strconv.Atoi(dotEnvUtil.GetEnvVariable("MAIL_OUTBOX_MAX_ATTEMPTS"))
+
+			if err != nil || maxAttempts < 1 {
+				maxAttempts = 8
+			}
+
+			retentionDays, err := strconv.Atoi(dotEnvUtil.GetEnvVariable("MAIL_OUTBOX_SENT_RETENTION_DAYS"))
+
+			if err != nil || retentionDays < 1 {
+				retentionDays = 7
+			}
+
+			mailOutboxService = &MailOutboxService{
+				nftRaffleMongoDb: nftRaffleMongoDb,
+				mailTransport:    mailtransport.NewMailTransport(dotEnvUtil.GetEnvVariable),
+				maxAttempts:      maxAttempts,
+				retentionDays:    retentionDays,
+			}
+		})
+	}
+	return mailOutboxService
+}
+
+func (s *MailOutboxService) StartDeliveringOutboxMailsCronAsync() {
+	loc, err := timeUtil.GetCurrentLocation()
+	if err != nil {
+		logger.Logger.Panic("unable to load current location")
+	}
+	scheduler := gocron.NewScheduler(loc)
+	scheduler.Every(10).Seconds().SingletonMode().Do(s.DeliverOutboxMails)
+	scheduler.Every(1).Day().At("03:00").Do(s.RemoveSentOutboxMails)
+	scheduler.StartAsync()
+}
+
+// DeliverOutboxMails claims due mails one at a time so several cron instances never send the same mail twice
+func (s *MailOutboxService) DeliverOutboxMails() {
+	for i := 0; i < mailOutboxBatchSize; i++ {
+		outboxMail, err := s.claimOutboxMail()
+
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return
+		}
+
+		if err != nil {
+			logger.Logger.Warn(fmt.Sprintf("error occured when claiming outbox mail: %v", err.Error()))
+			return
+		}
+
+		s.deliverOutboxMail(outboxMail)
+	}
+}
+
+func (s *MailOutboxService) claimOutboxMail() (models.MailOutbox, error) {
+	client := s.nftRaffleMongoDb.GetClient()
+	mailOutboxCollection := s.nftRaffleMongoDb.OpenCollection(client, MAIL_OUTBOX)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	now := time.Now()
+
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var outboxMail models.MailOutbox
+
+	err := mailOutboxCollection.FindOneAndUpdate(ctx, MailOutboxClaimFilter(now), MailOutboxClaimUpdate(now), opts).Decode(&outboxMail)
+
+	return outboxMail, err
+}
+
+func (s *MailOutboxService) deliverOutboxMail(outboxMail models.MailOutbox) {
+	client := s.nftRaffleMongoDb.GetClient()
+	mailOutboxCollection := s.nftRaffleMongoDb.OpenCollection(client, MAIL_OUTBOX)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// only a worker that crashed on the last attempt leaves a lock behind past the max attempts
+	if MailOutboxIsExhausted(outboxMail.Attempts, s.maxAttempts) {
+		logger.Logger.Error(fmt.Sprintf("outbox mail %s is dead after %d attempts: the worker lock expired", outboxMail.Mail_outbox_id, outboxMail.Attempts-1))
+
+		_, err := mailOutboxCollection.UpdateOne(ctx, bson.M{"mail_outbox_id": outboxMail.Mail_outbox_id}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: MAIL_OUTBOX_DEAD},
+				{Key: "attempts", Value: outboxMail.Attempts - 1},
+				{Key: "last_error", Value: "worker lock expired before the mail was sent"},
+				{Key: "updated_at", Value: time.Now()},
+			}},
+			{Key: "$unset", Value: bson.D{{Key: "locked_until", Value: ""}}},
+		})
+
+		if err != nil {
+			logger.Logger.Warn(fmt.Sprintf("error occured when updating outbox mail %s: %v", outboxMail.Mail_outbox_id, err.Error()))
+		}
+
+		return
+	}
+
+	sendErr := s.mailTransport.Send(outboxMailMessage(outboxMail))
+
+	now := time.Now()
+
+	var update bson.D
+
+	if sendErr == nil {
+		update = bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: MAIL_OUTBOX_SENT},
+				{Key: "sent_at", Value: now},
+				{Key: "updated_at", Value: now},
+			}},
+			{Key: "$unset", Value: bson.D{{Key: "locked_until", Value: ""}}},
+		}
+	} else {
+		// the claim already counted this attempt
+		attempts := outboxMail.Attempts
+		status := MailOutboxFailureStatus(attempts, s.maxAttempts)
+
+		if status == MAIL_OUTBOX_DEAD {
+			logger.Logger.Error(fmt.Sprintf("outbox mail %s is dead after %d attempts: %v", outboxMail.Mail_outbox_id, attempts, sendErr.Error()))
+		} else {
+			logger.Logger.Warn(fmt.Sprintf("outbox mail %s failed attempt %d: %v", outboxMail.Mail_outbox_id, attempts, sendErr.Error()))
+		}
+
+		update = bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: status},
+				{Key: "attempts", Value: attempts},
+				{Key: "last_error", Value: sendErr.Error()},
+				{Key: "next_attempt_at", Value: now.Add(MailOutboxBackoff(attempts))},
+				{Key: "updated_at", Value: now},
+			}},
+			{Key: "$unset", Value: bson.D{{Key: "locked_until", Value: ""}}},
+		}
+	}
+
+	_, err := mailOutboxCollection.UpdateOne(ctx, bson.M{"mail_outbox_id": outboxMail.Mail_outbox_id}, update)
+
+	if err != nil {
+		// the lock expires and the mail is claimed again, a sent mail may then go out twice
+		logger.Logger.Warn(fmt.Sprintf("error occured when updating outbox mail %s: %v", outboxMail.Mail_outbox_id, err.Error()))
+	}
+}
+
+// MailOutboxClaimFilter matches the due pending mails and the mails whose worker lock expired,
+// a mail locked by a running worker never matches so no other worker claims it
+func MailOutboxClaimFilter(now time.Time) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"status": MAIL_OUTBOX_PENDING, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"status": MAIL_OUTBOX_SENDING, "locked_until": bson.M{"$lt": now}},
+	}}
+}
+
+// MailOutboxClaimUpdate counts every claim as an attempt, so a mail that crashes its worker is not reclaimed forever
+func MailOutboxClaimUpdate(now time.Time) bson.D {
+	return bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: MAIL_OUTBOX_SENDING},
+			{Key: "locked_until", Value: now.Add(mailOutboxLockDuration)},
+			{Key: "updated_at", Value: now},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+	}
+}
+
+// MailOutboxFailureStatus retries a failed mail until it reached the max attempts, it is dead from then on
+func MailOutboxFailureStatus(attempts int, maxAttempts int) string {
+	if attempts >= maxAttempts {
+		return MAIL_OUTBOX_DEAD
+	}
+
+	return MAIL_OUTBOX_PENDING
+}
+
+// MailOutboxIsExhausted tells whether a claimed mail is past the max attempts, it is dead without being sent again
+func MailOutboxIsExhausted(attempts int, maxAttempts int) bool {
+	return attempts > maxAttempts
+}
+
+// outboxMailMessage hands the rendered outbox mail to the transports shared with the server
+func outboxMailMessage(outboxMail models.MailOutbox) mailtransport.Message {
+	message := mailtransport.Message{
+		Id:           outboxMail.Mail_outbox_id,
+		MailType:     outboxMail.Mail_type,
+		FromName:     outboxMail.From_name,
+		FromEmail:    outboxMail.From_email,
+		Locale:       outboxMail.Locale,
+		TemplateData: outboxMail.Template_data,
+		Subject:      outboxMail.Subject,
+		TextBody:     outboxMail.Text_body,
+		HtmlBody:     outboxMail.Html_body,
+		Headers:      outboxMail.Headers,
+	}
+
+	for _, to := range outboxMail.Tos {
+		message.Tos = append(message.Tos, mailtransport.Address{Name: to.Name, Address: to.Address})
+	}
+
+	return message
+}
+
+// MailOutboxBackoff doubles the wait after every failed attempt, with up to 20% jitter so failed mails do not retry together
+func MailOutboxBackoff(attempts int) time.Duration {
+	backoff := time.Duration(float64(mailOutboxBaseBackoff) * math.Pow(2, float64(attempts-1)))
+
+	if backoff > mailOutboxMaxBackoff || backoff <= 0 {
+		backoff = mailOutboxMaxBackoff
+	}
+
+	return backoff + time.Duration(rand.Int63n(int64(backoff)/5+1))
+}
+
+// RemoveSentOutboxMails keeps delivered mails for a while for troubleshooting, dead ones stay until an admin retries them
+func (s *MailOutboxService) RemoveSentOutboxMails() {
+	client := s.nftRaffleMongoDb.GetClient()
+	mailOutboxCollection := s.nftRaffleMongoDb.OpenCollection(client, MAIL_OUTBOX)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	result, err := mailOutboxCollection.DeleteMany(ctx, bson.M{
+		"status":  MAIL_OUTBOX_SENT,
+		"sent_at": bson.M{"$lte": time.Now().AddDate(0, 0, -s.retentionDays)},
+	})
+
+	if err != nil {
+		logger.Logger.Warn(fmt.Sprintf("error occured when removing sent outbox mails: %v", err.Error()))
+		return
+	}
+
+	logger.Logger.Info(fmt.Sprintf("%d sent outbox mails removed", result.DeletedCount))
+}
@@ -5,6 +5,9 @@ import "nft-raffle-cron/database"
 type Container struct {
 	HelloService            *HelloService
 	UsedRefreshTokenService *UsedRefreshTokenService
+	AccountDeletionService  *AccountDeletionService
+	MailOutboxService       *MailOutboxService
+	ExpenseDigestService    *ExpenseDigestService
 
 	NftRaffleMongoDb *database.NftRaffleMongoDb
 }
@@ -15,6 +18,9 @@ func NewContainer() *Container {
 	return &Container{
 		HelloService:            GetHelloService(nftRaffleMongoDb),
 		UsedRefreshTokenService: GetUsedRefreshTokenService(nftRaffleMongoDb),
+		AccountDeletionService:  GetAccountDeletionService(nftRaffleMongoDb),
+		MailOutboxService:       GetMailOutboxService(nftRaffleMongoDb),
+		ExpenseDigestService:    GetExpenseDigestService(nftRaffleMongoDb),
 
 		NftRaffleMongoDb: nftRaffleMongoDb,
 	}
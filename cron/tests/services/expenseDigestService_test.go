@@ -0,0 +1,101 @@
+package tests_services
+
+import (
+	"nft-raffle-cron/services"
+	"nft-raffle-mail/expensedigest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpenseDigestWeek(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name              string
+		localNow          time.Time
+		expectedWeekStart time.Time
+		expectedIsDue     bool
+	}{
+		{
+			name:              "monday before the send hour",
+			localNow:          time.Date(2024, 1, 8, 7, 59, 0, 0, newYork),
+			expectedWeekStart: time.Date(2024, 1, 1, 0, 0, 0, 0, newYork),
+			expectedIsDue:     false,
+		},
+		{
+			name:              "monday at the send hour",
+			localNow:          time.Date(2024, 1, 8, 8, 0, 0, 0, newYork),
+			expectedWeekStart: time.Date(2024, 1, 1, 0, 0, 0, 0, newYork),
+			expectedIsDue:     true,
+		},
+		{
+			name:              "sunday belongs to the week that started on monday",
+			localNow:          time.Date(2024, 1, 14, 23, 0, 0, 0, newYork),
+			expectedWeekStart: time.Date(2024, 1, 1, 0, 0, 0, 0, newYork),
+			expectedIsDue:     true,
+		},
+		{
+			name:              "week across a month",
+			localNow:          time.Date(2024, 3, 4, 9, 0, 0, 0, newYork),
+			expectedWeekStart: time.Date(2024, 2, 26, 0, 0, 0, 0, newYork),
+			expectedIsDue:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			weekStart, isDue := services.ExpenseDigestWeek(test.localNow)
+
+			if !weekStart.Equal(test.expectedWeekStart) || isDue != test.expectedIsDue {
+				t.Errorf("got %v %v, expected %v %v", weekStart, isDue, test.expectedWeekStart, test.expectedIsDue)
+			}
+		})
+	}
+}
+
+func TestBuildExpenseDigestTemplateData(t *testing.T) {
+	currentWeek := []expensedigest.ExpenseType{
+		{Expense_type: "Food", ExpenseAmountByType: 180, ExpenseCountByType: 6, ExpenseTypeData: []expensedigest.Expense{
+			{Expense_label: "Groceries", Expense_type: "Food", Expense_amount: 50},
+		}},
+		{Expense_type: "Entertainment", ExpenseAmountByType: 120, ExpenseCountByType: 2, ExpenseTypeData: []expensedigest.Expense{
+			{Expense_label: "Concert tickets", Expense_type: "Entertainment", Expense_amount: 90},
+		}},
+		{Expense_type: "Transport", ExpenseAmountByType: 100, ExpenseCountByType: 3},
+		{Expense_type: "Other", ExpenseAmountByType: 20, ExpenseCountByType: 1},
+	}
+	previousWeek := []expensedigest.ExpenseType{{Expense_type: "Food", ExpenseAmountByType: 350, ExpenseCountByType: 9}}
+
+	expected := map[string]string{
+		"Total_Spend":            "420",
+		"Previous_Total_Spend":   "350",
+		"Spend_Change":           "+20.0%",
+		"Expense_Count":          "12",
+		"Top_Categories":         "Food 180, Entertainment 120, Transport 100",
+		"Largest_Expense_Label":  "Concert tickets",
+		"Largest_Expense_Type":   "Entertainment",
+		"Largest_Expense_Amount": "90",
+	}
+
+	if data := services.BuildExpenseDigestTemplateData(currentWeek, previousWeek); !reflect.DeepEqual(data, expected) {
+		t.Errorf("got %v, expected %v", data, expected)
+	}
+
+	// a week without expenses after a week with some
+	expected = map[string]string{
+		"Total_Spend":          "0",
+		"Previous_Total_Spend": "350",
+		"Spend_Change":         "-100.0%",
+		"Expense_Count":        "0",
+		"Top_Categories":       "",
+	}
+
+	if data := services.BuildExpenseDigestTemplateData(nil, previousWeek); !reflect.DeepEqual(data, expected) {
+		t.Errorf("got %v, expected %v", data, expected)
+	}
+}
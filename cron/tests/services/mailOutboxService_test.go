@@ -0,0 +1,227 @@
+package tests_services
+
+import (
+	"nft-raffle-cron/models"
+	"nft-raffle-cron/services"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMailOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts        int
+		expectedBackoff time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{10, 256 * time.Minute},
+		// capped from here on
+		{11, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+
+	for _, test := range tests {
+		// the jitter adds up to 20%
+		for i := 0; i < 20; i++ {
+			backoff := services.MailOutboxBackoff(test.attempts)
+
+			if backoff < test.expectedBackoff || backoff > test.expectedBackoff+test.expectedBackoff/5 {
+				t.Errorf("attempt %d: expected %v plus up to 20%%, got %v", test.attempts, test.expectedBackoff, backoff)
+			}
+		}
+	}
+}
+
+func TestMailOutboxFailureStatus(t *testing.T) {
+	tests := []struct {
+		attempts       int
+		maxAttempts    int
+		expectedStatus string
+	}{
+		{1, 8, services.MAIL_OUTBOX_PENDING},
+		{7, 8, services.MAIL_OUTBOX_PENDING},
+		{8, 8, services.MAIL_OUTBOX_DEAD},
+		{9, 8, services.MAIL_OUTBOX_DEAD},
+		{1, 1, services.MAIL_OUTBOX_DEAD},
+	}
+
+	for _, test := range tests {
+		if status := services.MailOutboxFailureStatus(test.attempts, test.maxAttempts); status != test.expectedStatus {
+			t.Errorf("attempt %d of %d: expected %s, got %s", test.attempts, test.maxAttempts, test.expectedStatus, status)
+		}
+	}
+}
+
+func TestMailOutboxClaimFilter(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	tests := []struct {
+		name        string
+		outboxMail  models.MailOutbox
+		isClaimable bool
+	}{
+		{"due pending mail", models.MailOutbox{Status: services.MAIL_OUTBOX_PENDING, Next_attempt_at: past}, true},
+		{"pending mail waiting for its backoff", models.MailOutbox{Status: services.MAIL_OUTBOX_PENDING, Next_attempt_at: future}, false},
+		{"mail locked by a running worker", models.MailOutbox{Status: services.MAIL_OUTBOX_SENDING, Next_attempt_at: past, Locked_until: &future}, false},
+		{"mail of a crashed worker", models.MailOutbox{Status: services.MAIL_OUTBOX_SENDING, Next_attempt_at: past, Locked_until: &past}, true},
+		{"sending mail without lock", models.MailOutbox{Status: services.MAIL_OUTBOX_SENDING, Next_attempt_at: past}, false},
+		{"sent mail", models.MailOutbox{Status: services.MAIL_OUTBOX_SENT, Next_attempt_at: past}, false},
+		{"dead mail", models.MailOutbox{Status: services.MAIL_OUTBOX_DEAD, Next_attempt_at: past}, false},
+	}
+
+	for _, test := range tests {
+		if isClaimable := matchesFilter(t, services.MailOutboxClaimFilter(now), toDocument(t, test.outboxMail)); isClaimable != test.isClaimable {
+			t.Errorf("%s: expected claimable %v, got %v", test.name, test.isClaimable, isClaimable)
+		}
+	}
+}
+
+func TestMailOutboxClaimIsExclusive(t *testing.T) {
+	now := time.Now()
+	outboxMail := toDocument(t, models.MailOutbox{Status: services.MAIL_OUTBOX_PENDING, Next_attempt_at: now.Add(-time.Second)})
+
+	if !matchesFilter(t, services.MailOutboxClaimFilter(now), outboxMail) {
+		t.Fatal("expected the first worker to claim the due mail")
+	}
+
+	applyUpdate(t, outboxMail, services.MailOutboxClaimUpdate(now))
+
+	tests := []struct {
+		name        string
+		claimedAt   time.Time
+		isClaimable bool
+	}{
+		{"second worker in the same run", now, false},
+		{"second worker while the first one sends", now.Add(4 * time.Minute), false},
+		{"second worker after the lock of a crashed worker expired", now.Add(6 * time.Minute), true},
+	}
+
+	for _, test := range tests {
+		if isClaimable := matchesFilter(t, services.MailOutboxClaimFilter(test.claimedAt), outboxMail); isClaimable != test.isClaimable {
+			t.Errorf("%s: expected claimable %v, got %v", test.name, test.isClaimable, isClaimable)
+		}
+	}
+}
+
+func TestMailOutboxCrashingMailIsExhausted(t *testing.T) {
+	maxAttempts := 3
+	claimedAt := time.Now()
+	outboxMail := toDocument(t, models.MailOutbox{Status: services.MAIL_OUTBOX_PENDING, Next_attempt_at: claimedAt.Add(-time.Second)})
+
+	// every worker crashes before updating the mail, only the expired lock lets the next one claim it
+	for claims := 1; claims <= maxAttempts+1; claims++ {
+		if !matchesFilter(t, services.MailOutboxClaimFilter(claimedAt), outboxMail) {
+			t.Fatalf("claim %d: expected the mail to be claimable", claims)
+		}
+
+		applyUpdate(t, outboxMail, services.MailOutboxClaimUpdate(claimedAt))
+
+		attempts := int(outboxMail["attempts"].(int32))
+
+		if attempts != claims {
+			t.Fatalf("claim %d: expected %d attempts, got %d", claims, claims, attempts)
+		}
+
+		if isExhausted := services.MailOutboxIsExhausted(attempts, maxAttempts); isExhausted != (claims > maxAttempts) {
+			t.Errorf("claim %d: expected exhausted %v, got %v", claims, claims > maxAttempts, isExhausted)
+		}
+
+		claimedAt = claimedAt.Add(6 * time.Minute)
+	}
+}
+
+func toDocument(t *testing.T, value interface{}) bson.M {
+	raw, err := bson.Marshal(value)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var document bson.M
+
+	if err := bson.Unmarshal(raw, &document); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return document
+}
+
+// applyUpdate runs the $set and $inc of the update on the document, the way mongo stores the values
+func applyUpdate(t *testing.T, document bson.M, update bson.D) {
+	for _, operation := range update {
+		for key, value := range toDocument(t, operation.Value) {
+			switch operation.Key {
+			case "$set":
+				document[key] = value
+			case "$inc":
+				current, _ := document[key].(int32)
+				document[key] = current + value.(int32)
+			default:
+				t.Fatalf("unsupported update operator %s", operation.Key)
+			}
+		}
+	}
+}
+
+// matchesFilter evaluates the operators of the claim filter the way mongo does, comparisons never match a missing field
+func matchesFilter(t *testing.T, filter bson.M, document bson.M) bool {
+	for key, condition := range filter {
+		if key == "$or" {
+			isMatched := false
+
+			for _, clause := range condition.(bson.A) {
+				if matchesFilter(t, clause.(bson.M), document) {
+					isMatched = true
+				}
+			}
+
+			if !isMatched {
+				return false
+			}
+
+			continue
+		}
+
+		value, ok := document[key]
+		operators, isOperator := condition.(bson.M)
+
+		if !isOperator {
+			if !ok || value != condition {
+				return false
+			}
+
+			continue
+		}
+
+		for operator, operand := range operators {
+			dateTime, isDateTime := value.(primitive.DateTime)
+
+			if !ok || !isDateTime {
+				return false
+			}
+
+			limit := primitive.NewDateTimeFromTime(operand.(time.Time))
+
+			switch operator {
+			case "$lte":
+				if dateTime > limit {
+					return false
+				}
+			case "$lt":
+				if dateTime >= limit {
+					return false
+				}
+			default:
+				t.Fatalf("unsupported filter operator %s", operator)
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,232 @@
+package actionlink
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Every link sent by mail carries one token: v1.<kid>.<base64url json claims>.<base64url hmac-sha256>
+//
+// Key rotation:
+//  1. add the new key to ACTION_LINK_KEYS next to the current one
+//  2. point ACTION_LINK_SIGNING_KEY_ID at the new key
+//  3. once the longest link expiration has passed, remove the old key, unsubscribe links last the longest
+//
+// ACTION_LINK_KEYS is a comma separated list of <kid>:<base64 key>, keys must decode to at least 32 bytes.
+// The server and the cron module must share the keys, both sign links.
+
+const (
+	actionLinkVersion    string = "v1"
+	actionLinkMinKeySize int    = 32
+	ActionLinkTokenQuery string = "token"
+
+	// the unsubscribe link of the optional mails, the code is the mail category
+	UnsubscribePurpose string = "Unsubscribe"
+	UnsubscribePath    string = "/api/mail/unsubscribe"
+)
+
+var (
+	ErrInvalidActionLink       = errors.New("link is invalid")
+	ErrActionLinkExpired       = errors.New("link has expired")
+	ErrActionLinkNotConfigured = errors.New("action link keys are not configured")
+)
+
+type ActionLinkClaims struct {
+	Purpose    string `json:"pur"`
+	Subject    string `json:"sub"`
+	Code       string `json:"code"`
+	Expires_at int64  `json:"exp"`
+}
+
+type ActionLink struct {
+	baseUrl      string
+	keys         map[string][]byte
+	signingKeyId string
+	// the configuration error is returned on use, so a process without mail links still starts
+	keyErr error
+}
+
+// NewActionLinkFromEnv reads ACTION_LINK_BASE_URL, ACTION_LINK_KEYS and ACTION_LINK_SIGNING_KEY_ID
+func NewActionLinkFromEnv(getEnvVariable func(key string) string) *ActionLink {
+	return NewActionLink(actionLinkBaseUrl(getEnvVariable), getEnvVariable("ACTION_LINK_KEYS"), getEnvVariable("ACTION_LINK_SIGNING_KEY_ID"))
+}
+
+func NewActionLink(baseUrl string, keys string, signingKeyId string) *ActionLink {
+	parsedKeys, err := parseActionLinkKeys(keys)
+
+	if err != nil {
+		return &ActionLink{keyErr: err}
+	}
+
+	// a single key needs no signing key id
+	if signingKeyId == "" && len(parsedKeys) == 1 {
+		for kid := range parsedKeys {
+			signingKeyId = kid
+		}
+	}
+
+	if _, ok := parsedKeys[signingKeyId]; !ok {
+		return &ActionLink{keyErr: fmt.Errorf("action link signing key %q is not in the keys", signingKeyId)}
+	}
+
+	return &ActionLink{
+		baseUrl:      strings.TrimSuffix(baseUrl, "/"),
+		keys:         parsedKeys,
+		signingKeyId: signingKeyId,
+	}
+}
+
+// GenerateLink builds the url of the route at path, with the token and the extra query params
+func (a *ActionLink) GenerateLink(path string, purpose string, subject string, code string, expires_at time.Time, query url.Values) (string, error) {
+	token, err := a.GenerateToken(purpose, subject, code, expires_at)
+
+	if err != nil {
+		return "", err
+	}
+
+	if a.baseUrl == "" {
+		return "", errors.New("action link base url is not configured")
+	}
+
+	linkQuery := url.Values{}
+
+	for key, values := range query {
+		linkQuery[key] = values
+	}
+
+	linkQuery.Set(ActionLinkTokenQuery, token)
+
+	return a.baseUrl + path + "?" + linkQuery.Encode(), nil
+}
+
+func (a *ActionLink) GenerateToken(purpose string, subject string, code string, expires_at time.Time) (string, error) {
+	if a.keyErr != nil {
+		return "", a.keyErr
+	}
+
+	claims, err := json.Marshal(ActionLinkClaims{
+		Purpose:    purpose,
+		Subject:    subject,
+		Code:       code,
+		Expires_at: expires_at.Unix(),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := actionLinkVersion + "." + a.signingKeyId + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signActionLink(a.keys[a.signingKeyId], unsigned)), nil
+}
+
+// ParseToken accepts tokens signed by any configured key, for the given purpose only
+func (a *ActionLink) ParseToken(token string, purpose string) (*ActionLinkClaims, error) {
+	if a.keyErr != nil {
+		return nil, a.keyErr
+	}
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 4 || parts[0] != actionLinkVersion {
+		return nil, ErrInvalidActionLink
+	}
+
+	key, ok := a.keys[parts[1]]
+
+	if !ok {
+		return nil, ErrInvalidActionLink
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[3])
+
+	if err != nil || !hmac.Equal(signature, signActionLink(key, strings.Join(parts[:3], "."))) {
+		return nil, ErrInvalidActionLink
+	}
+
+	decodedClaims, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, ErrInvalidActionLink
+	}
+
+	var claims ActionLinkClaims
+
+	if err := json.Unmarshal(decodedClaims, &claims); err != nil {
+		return nil, ErrInvalidActionLink
+	}
+
+	if claims.Purpose != purpose || claims.Subject == "" {
+		return nil, ErrInvalidActionLink
+	}
+
+	if claims.Expires_at < time.Now().Unix() {
+		return nil, ErrActionLinkExpired
+	}
+
+	return &claims, nil
+}
+
+func signActionLink(key []byte, unsigned string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("action-link." + unsigned))
+	return mac.Sum(nil)
+}
+
+func parseActionLinkKeys(keys string) (map[string][]byte, error) {
+	parsedKeys := map[string][]byte{}
+
+	for _, entry := range strings.Split(keys, ",") {
+		entry = strings.TrimSpace(entry)
+
+		if entry == "" {
+			continue
+		}
+
+		kid, encodedKey, ok := strings.Cut(entry, ":")
+
+		if !ok || kid == "" || strings.Contains(kid, ".") {
+			return nil, fmt.Errorf("action link key %q must be <kid>:<base64 key>", kid)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+
+		if err != nil {
+			return nil, fmt.Errorf("action link key %s is not base64: %w", kid, err)
+		}
+
+		if len(key) < actionLinkMinKeySize {
+			return nil, fmt.Errorf("action link key %s must be at least %d bytes", kid, actionLinkMinKeySize)
+		}
+
+		parsedKeys[kid] = key
+	}
+
+	if len(parsedKeys) == 0 {
+		return nil, ErrActionLinkNotConfigured
+	}
+
+	return parsedKeys, nil
+}
+
+// actionLinkBaseUrl falls back to the verification mail return host of older configurations
+func actionLinkBaseUrl(getEnvVariable func(key string) string) string {
+	if baseUrl := getEnvVariable("ACTION_LINK_BASE_URL"); baseUrl != "" {
+		return baseUrl
+	}
+
+	host := getEnvVariable("VERIFICATION_MAIL_RETURN_HOST")
+	port := getEnvVariable("VERIFICATION_MAIL_RETURN_PORT")
+
+	if host == "" || port == "" {
+		return host
+	}
+
+	return host + ":" + port
+}
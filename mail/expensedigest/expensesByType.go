@@ -0,0 +1,88 @@
+package expensedigest
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ExpensesByType is the one document ExpensesByTypePipeline returns, nothing is returned when there are no expenses
+type ExpensesByType struct {
+	TotalExpenseTypes int           `bson:"totalExpenseTypes"`
+	Data              []ExpenseType `bson:"data"`
+}
+
+// ExpenseType is one expense type of the aggregation, biggest spend first
+type ExpenseType struct {
+	Expense_type        string    `bson:"_id"`
+	ExpenseAmountByType int64     `bson:"expenseAmountByType"`
+	ExpenseCountByType  int64     `bson:"expenseCountByType"`
+	ExpenseTypeData     []Expense `bson:"expenseTypeData"`
+}
+
+// Expense only maps the fields of the expense documents the digest reads, the server owns the full document
+type Expense struct {
+	Expense_id     string    `json:"expense_id" bson:"expense_id"`
+	Expense_label  string    `json:"expense_label" bson:"expense_label"`
+	Expense_type   string    `json:"expense_type" bson:"expense_type"`
+	Expense_amount int64     `json:"expense_amount" bson:"expense_amount"`
+	Expense_time   time.Time `json:"expense_time" bson:"expense_time"`
+}
+
+// ExpensesByTypePipeline groups the expenses of the user between both dates by type, biggest type first.
+// The expenses by type endpoint of the server and the weekly digest of the cron module both run it.
+func ExpensesByTypePipeline(userId string, fromDate time.Time, toDate time.Time) []bson.D {
+	var andQuery []bson.M
+	andQuery = append(andQuery, bson.M{"user_id": userId})
+	andQuery = append(andQuery, bson.M{"expense_time": bson.M{"$gte": fromDate}})
+	andQuery = append(andQuery, bson.M{"expense_time": bson.M{"$lte": toDate}})
+
+	matchStage := bson.D{
+		{Key: "$match", Value: bson.M{
+			"$and": andQuery,
+		}},
+	}
+
+	groupStageByExpenseType := bson.D{
+		{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$expense_type"},
+			{Key: "expenseAmountByType", Value: bson.M{
+				"$sum": "$expense_amount",
+			}},
+			{Key: "expenseCountByType", Value: bson.M{
+				"$count": bson.M{},
+			}},
+			{Key: "expenseTypeData", Value: bson.M{
+				"$push": "$$ROOT",
+			}},
+		}},
+	}
+
+	sortStage := bson.D{
+		{Key: "$sort", Value: bson.D{
+			{Key: "expenseAmountByType", Value: -1},
+		}},
+	}
+
+	groupStage2 := bson.D{
+		{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "null"},
+			{Key: "totalExpenseTypes", Value: bson.M{
+				"$count": bson.M{},
+			}},
+			{Key: "data", Value: bson.M{
+				"$push": "$$ROOT",
+			}},
+		}},
+	}
+
+	projectStage := bson.D{
+		{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "totalExpenseTypes", Value: 1},
+			{Key: "data", Value: 1},
+		}},
+	}
+
+	return []bson.D{matchStage, groupStageByExpenseType, sortStage, groupStage2, projectStage}
+}
@@ -0,0 +1,5 @@
+module nft-raffle-mail
+
+go 1.19
+
+require go.mongodb.org/mongo-driver v1.11.3
@@ -0,0 +1,253 @@
+package mailtemplate
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+const (
+	// every mail type must exist in the default locale, other locales fall back to it
+	DefaultMailLocale string = "en"
+
+	mailTemplateDir string = "mailTemplates"
+)
+
+var (
+	// mailTemplates/<locale>/<MailType>.txt defines the "subject" and the plain text body,
+	// mailTemplates/<locale>/<MailType>.html defines the "content" placed into layout.html
+	//go:embed mailTemplates
+	mailTemplateFiles embed.FS
+)
+
+type RenderedMail struct {
+	Locale   string
+	Subject  string
+	TextBody string
+	HtmlBody string
+	// variables the templates use that were not in the template data, they render as empty text
+	MissingVariables []string
+}
+
+type localizedMailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+	// every .Field the text and html templates read, sorted
+	variables []string
+}
+
+// MailTemplateRenderer renders the templates embedded in this package, the server and the cron module queue
+// the same rendered mails
+type MailTemplateRenderer struct {
+	templates map[string]map[string]*localizedMailTemplate
+}
+
+func NewMailTemplateRenderer() (*MailTemplateRenderer, error) {
+	templates, err := parseMailTemplates(mailTemplateFiles)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &MailTemplateRenderer{templates: templates}, nil
+}
+
+func parseMailTemplates(fsys fs.FS) (map[string]map[string]*localizedMailTemplate, error) {
+	localeDirs, err := fs.ReadDir(fsys, mailTemplateDir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	layoutPath := path.Join(mailTemplateDir, "layout.html")
+	templates := map[string]map[string]*localizedMailTemplate{}
+
+	for _, localeDir := range localeDirs {
+		if !localeDir.IsDir() {
+			continue
+		}
+
+		locale := localeDir.Name()
+		templates[locale] = map[string]*localizedMailTemplate{}
+
+		textPaths, err := fs.Glob(fsys, path.Join(mailTemplateDir, locale, "*.txt"))
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, textPath := range textPaths {
+			mailType := strings.TrimSuffix(path.Base(textPath), ".txt")
+
+			textTemplate, err := texttemplate.New(path.Base(textPath)).Option("missingkey=zero").ParseFS(fsys, textPath)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if textTemplate.Lookup("subject") == nil {
+				return nil, fmt.Errorf("%s does not define a subject", textPath)
+			}
+
+			htmlTemplate, err := htmltemplate.New(path.Base(layoutPath)).Option("missingkey=zero").ParseFS(fsys, layoutPath, strings.TrimSuffix(textPath, ".txt")+".html")
+
+			if err != nil {
+				return nil, err
+			}
+
+			variables := map[string]bool{}
+
+			for _, t := range textTemplate.Templates() {
+				collectTemplateVariables(t.Tree.Root, variables)
+			}
+
+			for _, t := range htmlTemplate.Templates() {
+				collectTemplateVariables(t.Tree.Root, variables)
+			}
+
+			templates[locale][mailType] = &localizedMailTemplate{text: textTemplate, html: htmlTemplate, variables: sortedVariables(variables)}
+		}
+	}
+
+	if _, ok := templates[DefaultMailLocale]; !ok {
+		return nil, fmt.Errorf("no mail templates for the default locale %s", DefaultMailLocale)
+	}
+
+	return templates, nil
+}
+
+// ResolveLocale maps a BCP 47 tag of the user to a template locale, "es-MX" uses "es" and unknown tags use the default
+func (m *MailTemplateRenderer) ResolveLocale(locale string) string {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+
+	for locale != "" {
+		if _, ok := m.templates[locale]; ok {
+			return locale
+		}
+
+		index := strings.LastIndex(locale, "-")
+
+		if index < 0 {
+			break
+		}
+
+		locale = locale[:index]
+	}
+
+	return DefaultMailLocale
+}
+
+func (m *MailTemplateRenderer) Render(mailType string, locale string, templateData map[string]string) (*RenderedMail, error) {
+	locale = m.ResolveLocale(locale)
+	localizedTemplate, ok := m.templates[locale][mailType]
+
+	// a locale may not translate every mail type yet
+	if !ok {
+		locale = DefaultMailLocale
+		localizedTemplate, ok = m.templates[locale][mailType]
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("no mail template for %s", mailType)
+	}
+
+	data := map[string]string{}
+
+	for key, value := range templateData {
+		data[key] = value
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+
+	if err := localizedTemplate.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+
+	if err := localizedTemplate.text.Execute(&textBody, data); err != nil {
+		return nil, err
+	}
+
+	data["Mail_Subject"] = strings.TrimSpace(subject.String())
+
+	var missingVariables []string
+
+	for _, variable := range localizedTemplate.variables {
+		if _, ok := data[variable]; !ok {
+			missingVariables = append(missingVariables, variable)
+		}
+	}
+
+	if err := localizedTemplate.html.Execute(&htmlBody, data); err != nil {
+		return nil, err
+	}
+
+	return &RenderedMail{
+		Locale:   locale,
+		Subject:  data["Mail_Subject"],
+		TextBody: textBody.String(),
+		HtmlBody: htmlBody.String(),
+
+		MissingVariables: missingVariables,
+	}, nil
+}
+
+// collectTemplateVariables walks the parse tree for the fields read from the template data
+func collectTemplateVariables(node parse.Node, variables map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+
+		for _, child := range n.Nodes {
+			collectTemplateVariables(child, variables)
+		}
+	case *parse.ActionNode:
+		collectTemplateVariables(n.Pipe, variables)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+
+		for _, command := range n.Cmds {
+			collectTemplateVariables(command, variables)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectTemplateVariables(arg, variables)
+		}
+	case *parse.FieldNode:
+		variables[n.Ident[0]] = true
+	case *parse.IfNode:
+		collectTemplateVariables(&n.BranchNode, variables)
+	case *parse.RangeNode:
+		collectTemplateVariables(&n.BranchNode, variables)
+	case *parse.WithNode:
+		collectTemplateVariables(&n.BranchNode, variables)
+	case *parse.BranchNode:
+		collectTemplateVariables(n.Pipe, variables)
+		collectTemplateVariables(n.List, variables)
+		collectTemplateVariables(n.ElseList, variables)
+	case *parse.TemplateNode:
+		collectTemplateVariables(n.Pipe, variables)
+	}
+}
+
+func sortedVariables(variables map[string]bool) []string {
+	sorted := make([]string, 0, len(variables))
+
+	for variable := range variables {
+		sorted = append(sorted, variable)
+	}
+
+	sort.Strings(sorted)
+
+	return sorted
+}
@@ -0,0 +1,44 @@
+package mailtransport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileTransport writes every mail as an .eml file, so signup and reset flows work without network
+type FileTransport struct {
+	Dir string
+}
+
+func (t *FileTransport) Send(message Message) error {
+	mimeMessage, err := BuildMimeMessage(message)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(t.Dir, 0700); err != nil {
+		return err
+	}
+
+	id := message.Id
+
+	// mails sent outside the outbox have no id
+	if id == "" {
+		suffix := make([]byte, 4)
+
+		if _, err := rand.Read(suffix); err != nil {
+			return err
+		}
+
+		id = hex.EncodeToString(suffix)
+	}
+
+	fileName := fmt.Sprintf("%s-%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), message.MailType, id)
+
+	return os.WriteFile(filepath.Join(t.Dir, fileName), mimeMessage, 0600)
+}
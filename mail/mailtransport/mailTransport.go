@@ -0,0 +1,102 @@
+package mailtransport
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// This package is the only place mails leave the system, the cron module delivers the outbox with it
+// and the server uses it for the admin test sends only.
+
+const (
+	SendGridMailTransport string = "sendgrid"
+	SmtpMailTransport     string = "smtp"
+	FileMailTransport     string = "file"
+
+	// mails are rendered from the templates in the repo
+	LocalMailTemplateSource string = "local"
+	// mails use the dynamic templates hosted by sendgrid
+	SendGridMailTemplateSource string = "sendgrid"
+)
+
+var (
+	// variable holding the sendgrid dynamic template of every mail type, every mail type must be listed
+	SendGridTemplateIdVariables = map[string]string{
+		"MailVerification":    "SENDGRID_MAIL_VERIFICATION_DYNAMIC_TEMPLATE_ID",
+		"PasswordReset":       "SENDGRID_MAIL_PASSWORD_RESET_DYNAMIC_TEMPLATE_ID",
+		"AccountLocked":       "SENDGRID_MAIL_ACCOUNT_LOCKED_DYNAMIC_TEMPLATE_ID",
+		"EmailChange":         "SENDGRID_MAIL_EMAIL_CHANGE_DYNAMIC_TEMPLATE_ID",
+		"EmailChangeNotice":   "SENDGRID_MAIL_EMAIL_CHANGE_NOTICE_DYNAMIC_TEMPLATE_ID",
+		"MagicLinkLogin":      "SENDGRID_MAIL_MAGIC_LINK_LOGIN_DYNAMIC_TEMPLATE_ID",
+		"WeeklyExpenseDigest": "SENDGRID_MAIL_WEEKLY_EXPENSE_DIGEST_DYNAMIC_TEMPLATE_ID",
+	}
+)
+
+type Address struct {
+	Name    string
+	Address string
+}
+
+// Message is one mail ready to be sent, the bodies are rendered unless the sendgrid dynamic templates are used
+type Message struct {
+	// outbox id echoed back by the sendgrid event webhook, empty for mails sent outside the outbox
+	Id           string
+	MailType     string
+	FromName     string
+	FromEmail    string
+	Tos          []Address
+	Locale       string
+	TemplateData map[string]string
+	Subject      string
+	TextBody     string
+	HtmlBody     string
+	Headers      map[string]string
+}
+
+type MailTransport interface {
+	Send(message Message) error
+}
+
+// NewMailTransport picks the transport from MAIL_TRANSPORT, sendgrid stays the default.
+// The server and the cron module both read their variables through it, so they share one configuration.
+func NewMailTransport(getEnvVariable func(key string) string) MailTransport {
+	switch strings.ToLower(getEnvVariable("MAIL_TRANSPORT")) {
+	case SmtpMailTransport:
+		port := getEnvVariable("SMTP_PORT")
+
+		if port == "" {
+			port = "587"
+		}
+
+		return &SmtpTransport{
+			Host:     getEnvVariable("SMTP_HOST"),
+			Port:     port,
+			Username: getEnvVariable("SMTP_USERNAME"),
+			Password: getEnvVariable("SMTP_PASSWORD"),
+		}
+	case FileMailTransport:
+		dir := getEnvVariable("MAIL_FILE_OUTPUT_DIR")
+
+		if dir == "" {
+			dir = "mailbox"
+		}
+
+		return &FileTransport{Dir: dir}
+	}
+
+	templateIds := map[string]string{}
+
+	for mailType, variable := range SendGridTemplateIdVariables {
+		templateIds[mailType] = getEnvVariable(variable)
+	}
+
+	return &SendGridTransport{
+		ApiKey:      getEnvVariable("SENDGRID_API_KEY"),
+		Url:         getEnvVariable("SENDGRID_API_HOST") + getEnvVariable("SENDGRID_API_ENDPOINT"),
+		TemplateIds: templateIds,
+		HttpClient:  &http.Client{Timeout: 30 * time.Second},
+
+		UseDynamicTemplates: strings.EqualFold(getEnvVariable("MAIL_TEMPLATE_SOURCE"), SendGridMailTemplateSource),
+	}
+}
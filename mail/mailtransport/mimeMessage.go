@@ -0,0 +1,124 @@
+package mailtransport
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BuildMimeMessage writes the rendered subject and bodies for the transports without hosted templates
+func BuildMimeMessage(message Message) ([]byte, error) {
+	if len(message.Tos) < 1 {
+		return nil, fmt.Errorf("%s mail has no recipient", message.MailType)
+	}
+
+	messageId := make([]byte, 16)
+
+	if _, err := rand.Read(messageId); err != nil {
+		return nil, err
+	}
+
+	from := mail.Address{Name: message.FromName, Address: message.FromEmail}
+	tos := make([]string, 0, len(message.Tos))
+
+	for _, to := range message.Tos {
+		tos = append(tos, (&mail.Address{Name: to.Name, Address: to.Address}).String())
+	}
+
+	domain := "localhost"
+
+	if at := strings.LastIndex(message.FromEmail, "@"); at > -1 {
+		domain = message.FromEmail[at+1:]
+	}
+
+	var mimeMessage bytes.Buffer
+
+	fmt.Fprintf(&mimeMessage, "From: %s\r\n", from.String())
+	fmt.Fprintf(&mimeMessage, "To: %s\r\n", strings.Join(tos, ", "))
+	fmt.Fprintf(&mimeMessage, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&mimeMessage, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&mimeMessage, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(messageId), domain)
+	fmt.Fprintf(&mimeMessage, "X-Mail-Type: %s\r\n", message.MailType)
+
+	if message.Locale != "" {
+		fmt.Fprintf(&mimeMessage, "Content-Language: %s\r\n", message.Locale)
+	}
+
+	// sorted so the header order is stable between runs
+	headerKeys := make([]string, 0, len(message.Headers))
+
+	for key := range message.Headers {
+		headerKeys = append(headerKeys, key)
+	}
+
+	sort.Strings(headerKeys)
+
+	for _, key := range headerKeys {
+		fmt.Fprintf(&mimeMessage, "%s: %s\r\n", textproto.CanonicalMIMEHeaderKey(key), message.Headers[key])
+	}
+
+	mimeMessage.WriteString("MIME-Version: 1.0\r\n")
+
+	// mails queued before the html templates only carry the plain text body
+	if message.HtmlBody == "" {
+		mimeMessage.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		mimeMessage.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+		if err := writeQuotedPrintable(&mimeMessage, message.TextBody); err != nil {
+			return nil, err
+		}
+
+		return mimeMessage.Bytes(), nil
+	}
+
+	// plain text first, clients show the last alternative they support
+	parts := multipart.NewWriter(&mimeMessage)
+	fmt.Fprintf(&mimeMessage, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", message.TextBody},
+		{"text/html; charset=utf-8", message.HtmlBody},
+	} {
+		partWriter, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := writeQuotedPrintable(partWriter, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	return mimeMessage.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	writer := quotedprintable.NewWriter(w)
+
+	if _, err := writer.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
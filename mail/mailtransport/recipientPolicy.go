@@ -0,0 +1,84 @@
+package mailtransport
+
+import (
+	"strings"
+)
+
+const (
+	// every mail goes to the address of the user
+	DeliverRecipientPolicy string = "deliver"
+	// for staging, only allowlisted domains get their mail, everything else goes to the catch-all address
+	RedirectRecipientPolicy string = "redirect"
+
+	OriginalRecipientHeader string = "X-Original-To"
+)
+
+// RecipientPolicy decides who really receives a mail, it is applied when a mail is queued
+type RecipientPolicy struct {
+	isRedirect      bool
+	redirectAddress string
+	allowedDomains  map[string]bool
+}
+
+// NewRecipientPolicyFromEnv reads MAIL_RECIPIENT_POLICY, MAIL_REDIRECT_ADDRESS and MAIL_REDIRECT_ALLOWED_DOMAINS
+func NewRecipientPolicyFromEnv(getEnvVariable func(key string) string) *RecipientPolicy {
+	return NewRecipientPolicy(
+		getEnvVariable("MAIL_RECIPIENT_POLICY"),
+		getEnvVariable("MAIL_REDIRECT_ADDRESS"),
+		strings.Split(getEnvVariable("MAIL_REDIRECT_ALLOWED_DOMAINS"), ","),
+	)
+}
+
+func NewRecipientPolicy(policy string, redirectAddress string, allowedDomains []string) *RecipientPolicy {
+	domains := map[string]bool{}
+
+	for _, domain := range allowedDomains {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			domains[domain] = true
+		}
+	}
+
+	return &RecipientPolicy{
+		isRedirect:      strings.EqualFold(policy, RedirectRecipientPolicy),
+		redirectAddress: strings.TrimSpace(redirectAddress),
+		allowedDomains:  domains,
+	}
+}
+
+// Apply returns the recipients and the original recipients they replaced, so they can be noted in a header.
+// A redirected recipient is dropped when no catch-all address is set, a staging mail is never sent to a real user by mistake.
+func (r *RecipientPolicy) Apply(tos []Address) ([]Address, []string) {
+	if !r.isRedirect {
+		return tos, nil
+	}
+
+	var recipients []Address
+	var originalTos []string
+	isRedirectAdded := false
+
+	for _, to := range tos {
+		if r.isAllowed(to.Address) {
+			recipients = append(recipients, to)
+			continue
+		}
+
+		originalTos = append(originalTos, to.Address)
+
+		if r.redirectAddress != "" && !isRedirectAdded {
+			recipients = append(recipients, Address{Name: to.Name, Address: r.redirectAddress})
+			isRedirectAdded = true
+		}
+	}
+
+	return recipients, originalTos
+}
+
+func (r *RecipientPolicy) isAllowed(address string) bool {
+	at := strings.LastIndex(address, "@")
+
+	if at < 0 {
+		return false
+	}
+
+	return r.allowedDomains[strings.ToLower(address[at+1:])]
+}
@@ -0,0 +1,112 @@
+package mailtransport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// SendGridTransport posts to the v3 mail send api, with the rendered mail or the dynamic template of the mail type
+type SendGridTransport struct {
+	ApiKey      string
+	Url         string
+	TemplateIds map[string]string
+	HttpClient  *http.Client
+
+	UseDynamicTemplates bool
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridPersonalization struct {
+	To                  []sendGridAddress `json:"to"`
+	DynamicTemplateData map[string]string `json:"dynamic_template_data,omitempty"`
+	CustomArgs          map[string]string `json:"custom_args,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridMailBody struct {
+	From             sendGridAddress           `json:"from"`
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	TemplateId       string                    `json:"template_id,omitempty"`
+	Subject          string                    `json:"subject,omitempty"`
+	Content          []sendGridContent         `json:"content,omitempty"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+}
+
+func (t *SendGridTransport) Send(message Message) error {
+	// echoed back by the event webhook of the server to find the mail record
+	personalization := sendGridPersonalization{CustomArgs: map[string]string{"mail_type": message.MailType}}
+
+	if message.Id != "" {
+		personalization.CustomArgs["mail_outbox_id"] = message.Id
+	}
+
+	for _, to := range message.Tos {
+		personalization.To = append(personalization.To, sendGridAddress{Email: to.Address, Name: to.Name})
+	}
+
+	mailBody := sendGridMailBody{
+		From:    sendGridAddress{Email: message.FromEmail, Name: message.FromName},
+		Headers: message.Headers,
+	}
+
+	if t.UseDynamicTemplates {
+		personalization.DynamicTemplateData = message.TemplateData
+		mailBody.TemplateId = t.TemplateIds[message.MailType]
+	} else {
+		mailBody.Subject = message.Subject
+		mailBody.Content = []sendGridContent{{Type: "text/plain", Value: message.TextBody}}
+
+		if message.HtmlBody != "" {
+			mailBody.Content = append(mailBody.Content, sendGridContent{Type: "text/html", Value: message.HtmlBody})
+		}
+	}
+
+	mailBody.Personalizations = []sendGridPersonalization{personalization}
+
+	body, err := json.Marshal(mailBody)
+
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, t.Url, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+t.ApiKey)
+	request.Header.Set("Content-Type", "application/json")
+
+	httpClient := t.HttpClient
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	response, err := httpClient.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1<<16))
+		return fmt.Errorf("sendgrid responded %d: %s", response.StatusCode, string(responseBody))
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package mailtransport
+
+import (
+	"net"
+	"net/smtp"
+)
+
+// SmtpTransport upgrades to STARTTLS whenever the server offers it, credentials are optional for local relays
+type SmtpTransport struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+func (t *SmtpTransport) Send(message Message) error {
+	mimeMessage, err := BuildMimeMessage(message)
+
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+
+	if t.Username != "" {
+		auth = smtp.PlainAuth("", t.Username, t.Password, t.Host)
+	}
+
+	tos := make([]string, 0, len(message.Tos))
+
+	for _, to := range message.Tos {
+		tos = append(tos, to.Address)
+	}
+
+	return smtp.SendMail(net.JoinHostPort(t.Host, t.Port), auth, message.FromEmail, tos, mimeMessage)
+}
@@ -0,0 +1,169 @@
+package tests_mailtransport
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"nft-raffle-mail/mailtransport"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestMessage() mailtransport.Message {
+	return mailtransport.Message{
+		Id:        "outbox-1",
+		MailType:  "MailVerification",
+		FromName:  "Expense Tracker",
+		FromEmail: "no-reply@example.com",
+		Tos:       []mailtransport.Address{{Name: "Jane Doe", Address: "jane@example.com"}},
+		Locale:    "en-US",
+		Subject:   "Verify your email address",
+		TextBody:  "Hi Jane Doe,\nhttp://localhost:3000/verify?code=abc",
+		Headers:   map[string]string{"list-unsubscribe": "<http://localhost:3000/unsubscribe>"},
+	}
+}
+
+func TestBuildMimeMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		htmlBody   string
+		expected   []string
+		unexpected []string
+	}{
+		{
+			"plain text only",
+			"",
+			[]string{"Content-Type: text/plain; charset=utf-8\r\n", "Hi Jane Doe,\r\n", "code=3Dabc"},
+			[]string{"multipart/alternative"},
+		},
+		{
+			"plain text and html",
+			"<p>Hi Jane Doe,</p>",
+			[]string{"Content-Type: multipart/alternative;", "Content-Type: text/html; charset=utf-8", "<p>Hi Jane Doe,</p>"},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		message := newTestMessage()
+		message.HtmlBody = test.htmlBody
+
+		mimeMessage, err := mailtransport.BuildMimeMessage(message)
+
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err.Error())
+		}
+
+		expected := append([]string{
+			"To: \"Jane Doe\" <jane@example.com>\r\n",
+			"Subject: Verify your email address\r\n",
+			"X-Mail-Type: MailVerification\r\n",
+			"Content-Language: en-US\r\n",
+			"List-Unsubscribe: <http://localhost:3000/unsubscribe>\r\n",
+		}, test.expected...)
+
+		for _, value := range expected {
+			if !strings.Contains(string(mimeMessage), value) {
+				t.Errorf("%s: expected message to contain %q, got:\n%s", test.name, value, string(mimeMessage))
+			}
+		}
+
+		for _, value := range test.unexpected {
+			if strings.Contains(string(mimeMessage), value) {
+				t.Errorf("%s: expected message not to contain %q", test.name, value)
+			}
+		}
+	}
+}
+
+func TestBuildMimeMessageWithoutRecipient(t *testing.T) {
+	message := newTestMessage()
+	message.Tos = nil
+
+	if _, err := mailtransport.BuildMimeMessage(message); err == nil {
+		t.Error("expected an error for a mail without recipient")
+	}
+}
+
+func TestFileTransport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mailbox")
+
+	if err := (&mailtransport.FileTransport{Dir: dir}).Send(newTestMessage()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	files, err := os.ReadDir(dir)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), "-MailVerification-outbox-1.eml") {
+		t.Fatalf("expected one .eml file named after the outbox id, got %v", files)
+	}
+}
+
+func TestSendGridTransport(t *testing.T) {
+	var body struct {
+		TemplateId       string `json:"template_id"`
+		Subject          string `json:"subject"`
+		Personalizations []struct {
+			DynamicTemplateData map[string]string `json:"dynamic_template_data"`
+			CustomArgs          map[string]string `json:"custom_args"`
+		} `json:"personalizations"`
+	}
+	var status int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Error(err.Error())
+		}
+
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	transport := &mailtransport.SendGridTransport{
+		ApiKey:      "SG.test",
+		Url:         server.URL + "/v3/mail/send",
+		TemplateIds: map[string]string{"MailVerification": "d-verification"},
+		HttpClient:  server.Client(),
+
+		UseDynamicTemplates: true,
+	}
+
+	message := newTestMessage()
+	message.TemplateData = map[string]string{"Full_Name": "Jane Doe"}
+
+	status = http.StatusAccepted
+
+	if err := transport.Send(message); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if body.TemplateId != "d-verification" || body.Subject != "" || len(body.Personalizations) != 1 {
+		t.Fatalf("unexpected body %+v", body)
+	}
+
+	// the event webhook of the server finds the outbox mail with these
+	customArgs := body.Personalizations[0].CustomArgs
+
+	if customArgs["mail_type"] != "MailVerification" || customArgs["mail_outbox_id"] != "outbox-1" {
+		t.Errorf("unexpected custom args %v", customArgs)
+	}
+
+	if body.Personalizations[0].DynamicTemplateData["Full_Name"] != "Jane Doe" {
+		t.Errorf("unexpected dynamic template data %v", body.Personalizations[0].DynamicTemplateData)
+	}
+
+	status = http.StatusBadRequest
+
+	if err := transport.Send(message); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("expected the sendgrid error to be returned, got %v", err)
+	}
+}
@@ -0,0 +1,535 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"nft-raffle/dto"
+	"nft-raffle/enums"
+	"nft-raffle/helpers"
+	"nft-raffle/logger"
+	"nft-raffle/models"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultAdminPageSize int64 = 20
+	maxAdminPageSize     int64 = 100
+)
+
+var (
+	AdminController IAdminController = NewAdminController()
+
+	adminActionLogCollection *mongo.Collection = nftRaffleDb.OpenCollection(nftRaffleDbClient, "adminActionLog")
+	mailOutboxCollection     *mongo.Collection = nftRaffleDb.OpenCollection(nftRaffleDbClient, "mailOutbox")
+
+	userStatusHelper helpers.IUserStatusHelper = helpers.UserStatusHelper
+)
+
+type IAdminController interface {
+	ListUsers(c *gin.Context)
+	GetUser(c *gin.Context)
+	ChangeUserRole(c *gin.Context)
+	VerifyUserEmail(c *gin.Context)
+	DisableUser(c *gin.Context)
+	EnableUser(c *gin.Context)
+	ForceLogoutUser(c *gin.Context)
+	ListActionLogs(c *gin.Context)
+	ListOutboxMails(c *gin.Context)
+	RetryOutboxMail(c *gin.Context)
+	PreviewMail(c *gin.Context)
+	TestSendMail(c *gin.Context)
+}
+
+type adminControllerStruct struct{}
+
+func NewAdminController() IAdminController {
+	return &adminControllerStruct{}
+}
+
+// ListUsers supports ?search= on email, names and user id, and ?page= / ?page_size= pagination
+func (a *adminControllerStruct) ListUsers(c *gin.Context) {
+	page, pageSize := getAdminPagination(c)
+
+	filter := bson.M{}
+
+	if search := c.Query("search"); search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+		filter["$or"] = bson.A{
+			bson.M{"email": pattern},
+			bson.M{"first_name": pattern},
+			bson.M{"last_name": pattern},
+			bson.M{"user_id": search},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	total, err := userCollection.CountDocuments(ctx, filter)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip((page - 1) * pageSize).
+		SetLimit(pageSize)
+
+	cursor, err := userCollection.Find(ctx, filter, opts)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var users []models.User
+
+	if err := cursor.All(ctx, &users); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	userResponses := make([]dto.UserResponseDto, 0, len(users))
+
+	for _, user := range users {
+		userResponses = append(userResponses, dto.NewUserResponseDto(user, false))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":     userResponses,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func (a *adminControllerStruct) GetUser(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	user, ok := findAdminTargetUser(ctx, c)
+
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewUserResponseDto(user, false))
+}
+
+func (a *adminControllerStruct) ChangeUserRole(c *gin.Context) {
+	var request dto.AdminChangeUserRoleRequestDto
+
+	if err := c.BindJSON(&request); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if validationErr := validate.Struct(request); validationErr != nil {
+		logger.Logger.Error(validationErr.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		return
+	}
+
+	if c.Param("user_id") == c.GetString("uid") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "admins cannot change their own role"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	user, ok := findAdminTargetUser(ctx, c)
+
+	if !ok {
+		return
+	}
+
+	if err := updateUserFields(ctx, user.User_id, bson.D{{Key: "user_role", Value: request.UserRole}}); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the role is a claim of the access token, outstanding tokens must not keep the old role
+	if err := tokenHelper.SetBlacklistAccessAndRefreshTokenUserId(user.User_id); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeAdminActionLog(ctx, c, enums.ChangeUserRole, user.User_id, map[string]string{
+		"from": user.User_role,
+		"to":   request.UserRole,
+	})
+
+	user.User_role = request.UserRole
+
+	c.JSON(http.StatusOK, dto.NewUserResponseDto(user, false))
+}
+
+func (a *adminControllerStruct) VerifyUserEmail(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	user, ok := findAdminTargetUser(ctx, c)
+
+	if !ok {
+		return
+	}
+
+	if err := updateUserFields(ctx, user.User_id, bson.D{{Key: "is_email_verified", Value: true}}); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the pending verification link is of no use anymore
+	_, err := mailCollection.DeleteMany(ctx, bson.D{
+		{Key: "email", Value: user.Email},
+		{Key: "type", Value: enums.MailVerification.String()},
+	})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+	}
+
+	writeAdminActionLog(ctx, c, enums.ForceVerifyEmail, user.User_id, nil)
+
+	user.Is_email_verified = true
+
+	c.JSON(http.StatusOK, dto.NewUserResponseDto(user, false))
+}
+
+func (a *adminControllerStruct) DisableUser(c *gin.Context) {
+	var request dto.AdminDisableUserRequestDto
+
+	// the reason is optional, an empty body is fine
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if validationErr := validate.Struct(request); validationErr != nil {
+		logger.Logger.Error(validationErr.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		return
+	}
+
+	if c.Param("user_id") == c.GetString("uid") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "admins cannot disable their own account"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	user, ok := findAdminTargetUser(ctx, c)
+
+	if !ok {
+		return
+	}
+
+	if err := updateUserFields(ctx, user.User_id, bson.D{{Key: "is_disabled", Value: true}}); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := userStatusHelper.SetUserDisabled(user.User_id, true); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tokenHelper.SetBlacklistAccessAndRefreshTokenUserId(user.User_id); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeAdminActionLog(ctx, c, enums.DisableUser, user.User_id, map[string]string{"reason": request.Reason})
+
+	user.Is_disabled = true
+
+	c.JSON(http.StatusOK, dto.NewUserResponseDto(user, false))
+}
+
+func (a *adminControllerStruct) EnableUser(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	user, ok := findAdminTargetUser(ctx, c)
+
+	if !ok {
+		return
+	}
+
+	if err := updateUserFields(ctx, user.User_id, bson.D{{Key: "is_disabled", Value: false}}); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := userStatusHelper.SetUserDisabled(user.User_id, false); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeAdminActionLog(ctx, c, enums.EnableUser, user.User_id, nil)
+
+	user.Is_disabled = false
+
+	c.JSON(http.StatusOK, dto.NewUserResponseDto(user, false))
+}
+
+func (a *adminControllerStruct) ForceLogoutUser(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	user, ok := findAdminTargetUser(ctx, c)
+
+	if !ok {
+		return
+	}
+
+	if err := tokenHelper.SetBlacklistAccessAndRefreshTokenUserId(user.User_id); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeAdminActionLog(ctx, c, enums.ForceLogoutUser, user.User_id, nil)
+
+	c.Status(http.StatusOK)
+}
+
+// ListActionLogs can be narrowed with ?target_user_id= and ?admin_id=
+func (a *adminControllerStruct) ListActionLogs(c *gin.Context) {
+	page, pageSize := getAdminPagination(c)
+
+	filter := bson.M{}
+
+	if targetUserId := c.Query("target_user_id"); targetUserId != "" {
+		filter["target_user_id"] = targetUserId
+	}
+
+	if adminId := c.Query("admin_id"); adminId != "" {
+		filter["admin_id"] = adminId
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	total, err := adminActionLogCollection.CountDocuments(ctx, filter)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip((page - 1) * pageSize).
+		SetLimit(pageSize)
+
+	cursor, err := adminActionLogCollection.Find(ctx, filter, opts)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	actionLogs := []models.AdminActionLog{}
+
+	if err := cursor.All(ctx, &actionLogs); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"action_logs": actionLogs,
+		"total":       total,
+		"page":        page,
+		"page_size":   pageSize,
+	})
+}
+
+// ListOutboxMails shows the dead-lettered mails by default, ?status= selects another status and ?email= a recipient
+func (a *adminControllerStruct) ListOutboxMails(c *gin.Context) {
+	page, pageSize := getAdminPagination(c)
+
+	status := c.DefaultQuery("status", enums.MailOutboxDead.String())
+	filter := bson.M{"status": status}
+
+	if email := c.Query("email"); email != "" {
+		filter["tos.address"] = email
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	total, err := mailOutboxCollection.CountDocuments(ctx, filter)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}}).
+		SetSkip((page - 1) * pageSize).
+		SetLimit(pageSize)
+
+	cursor, err := mailOutboxCollection.Find(ctx, filter, opts)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	outboxMails := []models.MailOutbox{}
+
+	if err := cursor.All(ctx, &outboxMails); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mails":     outboxMails,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// RetryOutboxMail queues a dead-lettered mail again with a fresh attempt budget
+func (a *adminControllerStruct) RetryOutboxMail(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	now, err := timeHelper.GetCurrentLocationTime()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	mailOutboxId := c.Param("mail_outbox_id")
+
+	result, err := mailOutboxCollection.UpdateOne(
+		ctx,
+		bson.M{"mail_outbox_id": mailOutboxId, "status": enums.MailOutboxDead.String()},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: enums.MailOutboxPending.String()},
+				{Key: "attempts", Value: 0},
+				{Key: "next_attempt_at", Value: now},
+				{Key: "updated_at", Value: now},
+			}},
+			{Key: "$unset", Value: bson.D{{Key: "locked_until", Value: ""}}},
+		},
+	)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if result.MatchedCount < 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "dead-lettered mail not found"})
+		return
+	}
+
+	writeAdminActionLog(ctx, c, enums.RetryOutboxMail, "", map[string]string{"mail_outbox_id": mailOutboxId})
+
+	c.Status(http.StatusAccepted)
+}
+
+// findAdminTargetUser loads the user of the :user_id path param, responding 404 when it does not exist
+func findAdminTargetUser(ctx context.Context, c *gin.Context) (models.User, bool) {
+	var user models.User
+
+	err := userCollection.FindOne(ctx, bson.M{"user_id": c.Param("user_id")}).Decode(&user)
+
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return user, false
+	} else if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return user, false
+	}
+
+	return user, true
+}
+
+// writeAdminActionLog records who did what to whom, a failed write is logged but does not undo the action
+func writeAdminActionLog(ctx context.Context, c *gin.Context, action enums.AdminAction, targetUserId string, details map[string]string) {
+	var actionLog models.AdminActionLog
+	actionLog.ID = primitive.NewObjectID()
+	actionLog.Admin_action_log_id = actionLog.ID.Hex()
+	actionLog.Admin_id = c.GetString("uid")
+	actionLog.Target_user_id = targetUserId
+	actionLog.Action = action.String()
+	actionLog.Details = details
+	actionLog.Ip_address = c.ClientIP()
+
+	created_at, err := timeHelper.GetCurrentLocationTime()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		created_at = time.Now()
+	}
+
+	actionLog.Created_at = created_at
+
+	if _, err := adminActionLogCollection.InsertOne(ctx, actionLog); err != nil {
+		logger.Logger.Error("error occured while writing admin action log " + err.Error())
+	}
+}
+
+func getAdminPagination(c *gin.Context) (page int64, pageSize int64) {
+	page, err := strconv.ParseInt(c.Query("page"), 10, 64)
+
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err = strconv.ParseInt(c.Query("page_size"), 10, 64)
+
+	if err != nil || pageSize < 1 {
+		pageSize = defaultAdminPageSize
+	}
+
+	if pageSize > maxAdminPageSize {
+		pageSize = maxAdminPageSize
+	}
+
+	return page, pageSize
+}
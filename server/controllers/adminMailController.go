@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"nft-raffle/dto"
+	"nft-raffle/enums"
+	"nft-raffle/logger"
+	"nft-raffle/services"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const missingTemplateVariablesHeader string = "X-Missing-Template-Variables"
+
+var (
+	mailTemplateRenderer services.IMailTemplateRenderer = services.MailTemplateRenderer
+	mailSender           services.IMailSender           = services.MailSender
+)
+
+// PreviewMail renders a mail type with sample data, ?locale= picks the language and ?format=html or ?format=text
+// returns the body alone for viewing in a browser
+func (a *adminControllerStruct) PreviewMail(c *gin.Context) {
+	mailRequest, ok := newSampleMailRequest(c, c.Param("type"), c.Query("locale"), nil)
+
+	if !ok {
+		return
+	}
+
+	renderedMail, err := mailTemplateRenderer.Render(mailRequest)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(renderedMail.MissingVariables) > 0 {
+		c.Header(missingTemplateVariablesHeader, strings.Join(renderedMail.MissingVariables, ", "))
+	}
+
+	switch c.Query("format") {
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(renderedMail.HtmlBody))
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(renderedMail.TextBody))
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"mail_type":         mailRequest.MailType.String(),
+			"locale":            renderedMail.Locale,
+			"subject":           renderedMail.Subject,
+			"text_body":         renderedMail.TextBody,
+			"html_body":         renderedMail.HtmlBody,
+			"template_data":     mailRequest.DynamicTemplateData,
+			"missing_variables": missingVariablesOrEmpty(renderedMail.MissingVariables),
+		})
+	}
+}
+
+// TestSendMail sends the mail type to the signed in admin right away, bypassing the outbox, preferences and
+// recipient policy so a transport problem shows up in the response
+func (a *adminControllerStruct) TestSendMail(c *gin.Context) {
+	var request dto.TestSendMailRequestDto
+
+	if err := c.BindJSON(&request); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if validationErr := validate.Struct(request); validationErr != nil {
+		logger.Logger.Error(validationErr.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		return
+	}
+
+	mailRequest, ok := newSampleMailRequest(c, request.Mail_type, request.Locale, request.Template_data)
+
+	if !ok {
+		return
+	}
+
+	mailRequest.Tos = []dto.MailAddress{{
+		Name:    strings.TrimSpace(fmt.Sprintf("%s %s", c.GetString("first_name"), c.GetString("last_name"))),
+		Address: c.GetString("email"),
+	}}
+	mailRequest.Headers = map[string]string{"X-Mail-Test": "true"}
+
+	renderedMail, err := mailTemplateRenderer.Render(mailRequest)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := mailSender.Send(mailRequest); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("error occured while sending test mail: %s", err.Error())})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	writeAdminActionLog(ctx, c, enums.TestSendMail, "", map[string]string{
+		"mail_type": mailRequest.MailType.String(),
+		"locale":    renderedMail.Locale,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           fmt.Sprintf("%s test mail sent to %s", mailRequest.MailType.String(), c.GetString("email")),
+		"subject":           renderedMail.Subject,
+		"locale":            renderedMail.Locale,
+		"missing_variables": missingVariablesOrEmpty(renderedMail.MissingVariables),
+	})
+}
+
+// newSampleMailRequest responds 404 for mail types without a template, such as the sms only phone verification
+func newSampleMailRequest(c *gin.Context, mailTypeValue string, locale string, templateData map[string]string) (*dto.MailRequest, bool) {
+	mailType := enums.MailType(mailTypeValue)
+	sampleData, ok := services.MailTemplateSampleData[mailType]
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no mail template for %s", mailTypeValue)})
+		return nil, false
+	}
+
+	data := map[string]string{}
+
+	for key, value := range sampleData {
+		data[key] = value
+	}
+
+	for key, value := range templateData {
+		data[key] = value
+	}
+
+	return &dto.MailRequest{
+		FromName:            fromName,
+		FromEmail:           fromEmail,
+		MailType:            mailType,
+		Locale:              locale,
+		DynamicTemplateData: data,
+	}, true
+}
+
+func missingVariablesOrEmpty(missingVariables []string) []string {
+	if missingVariables == nil {
+		return []string{}
+	}
+
+	return missingVariables
+}
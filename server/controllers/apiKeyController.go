@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"nft-raffle/dto"
+	"nft-raffle/helpers"
+	"nft-raffle/logger"
+	"nft-raffle/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxApiKeysPerUser int64 = 20
+)
+
+var (
+	ApiKeyController IApiKeyController = NewApiKeyController()
+
+	apiKeyCollection *mongo.Collection = nftRaffleDb.OpenCollection(nftRaffleDbClient, "apiKey")
+
+	apiKeyHelper helpers.IApiKeyHelper = helpers.ApiKeyHelper
+
+	apiKeyDefaultExpirationDays string = dotEnvHelper.GetEnvVariable("API_KEY_DEFAULT_EXPIRATION_DAYS")
+)
+
+type IApiKeyController interface {
+	CreateApiKey(c *gin.Context)
+	ListApiKeys(c *gin.Context)
+	RevokeApiKey(c *gin.Context)
+}
+
+type apiKeyControllerStruct struct{}
+
+func NewApiKeyController() IApiKeyController {
+	return &apiKeyControllerStruct{}
+}
+
+// CreateApiKey returns the plain key in this response only, afterwards just the prefix is known
+func (a *apiKeyControllerStruct) CreateApiKey(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	var request dto.CreateApiKeyRequestDto
+
+	if err := c.BindJSON(&request); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if validationErr := validate.Struct(request); validationErr != nil {
+		logger.Logger.Error(validationErr.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	count, err := apiKeyCollection.CountDocuments(ctx, bson.M{"user_id": userId})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if count >= maxApiKeysPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("at most %d api keys are allowed, revoke an unused one first", maxApiKeysPerUser)})
+		return
+	}
+
+	expiresInDays := request.Expires_in_days
+
+	if expiresInDays == 0 {
+		expiresInDays, err = strconv.Atoi(apiKeyDefaultExpirationDays)
+
+		if err != nil || expiresInDays < 1 {
+			expiresInDays = 90
+		}
+	}
+
+	plainApiKey, prefix, keyHash, err := apiKeyHelper.GenerateApiKey()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var apiKey models.ApiKey
+	apiKey.ID = primitive.NewObjectID()
+	apiKey.Api_key_id = apiKey.ID.Hex()
+	apiKey.User_id = userId
+	apiKey.Name = request.Name
+	apiKey.Prefix = prefix
+	apiKey.Key_hash = keyHash
+	apiKey.Scopes = request.Scopes
+
+	apiKey.Created_at, err = timeHelper.GetCurrentLocationTime()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while parsing created_at"})
+		return
+	}
+
+	apiKey.Expires_at = apiKey.Created_at.Add(24 * time.Hour * time.Duration(expiresInDays))
+
+	if _, err := apiKeyCollection.InsertOne(ctx, apiKey); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Logger.Info(fmt.Sprintf("api key %s created for user %s", apiKey.Api_key_id, userId))
+	c.JSON(http.StatusCreated, gin.H{"api_key": plainApiKey, "key": apiKey})
+}
+
+func (a *apiKeyControllerStruct) ListApiKeys(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cursor, err := apiKeyCollection.Find(ctx, bson.M{"user_id": userId}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	apiKeys := []models.ApiKey{}
+
+	if err := cursor.All(ctx, &apiKeys); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keys": apiKeys})
+}
+
+// RevokeApiKey deletes the key, the next request using it is rejected
+func (a *apiKeyControllerStruct) RevokeApiKey(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	result, err := apiKeyCollection.DeleteOne(ctx, bson.M{"api_key_id": c.Param("api_key_id"), "user_id": userId})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if result.DeletedCount < 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+
+	logger.Logger.Info(fmt.Sprintf("api key %s revoked by user %s", c.Param("api_key_id"), userId))
+	c.Status(http.StatusNoContent)
+}
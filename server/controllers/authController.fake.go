@@ -3,6 +3,7 @@ package controllers
 import (
 	"fmt"
 	"net/http"
+	"nft-raffle/dto"
 	"nft-raffle/logger"
 	"nft-raffle/models"
 	"sync"
@@ -77,5 +78,5 @@ func (a *fakeAuthControllerStruct) FakeLogin(c *gin.Context) {
 
 	// loc, _ := time.LoadLocation("Asia/Singapore")
 	// logger.Logger.Debug(fmt.Sprintf("local date time %v", foundUser.Updated_at.In(loc)))
-	c.JSON(http.StatusOK, foundUser)
+	c.JSON(http.StatusOK, dto.NewUserResponseDto(foundUser, true))
 }
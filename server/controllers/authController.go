@@ -3,6 +3,7 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,7 +19,6 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"github.com/sendgrid/sendgrid-go/helpers/mail"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -35,29 +35,37 @@ var (
 
 	tokenHelper          helpers.ITokenHelper          = helpers.TokenHelper
 	aesEncryptionHelper  helpers.IAesEncrptionHelper   = helpers.AesEncryptionHelper
+	actionLinkHelper     helpers.IActionLinkHelper     = helpers.ActionLinkHelper
 	randomCodeGenerator  helpers.IRandomCodeGenerator  = helpers.RandomCodeGenerator
 	dataValidationHelper helpers.IDataValidationHelper = helpers.DataValidationHelper
 	passwordHelper       helpers.IPasswordHelper       = helpers.PasswordHelper
 	dotEnvHelper         helpers.IDotEnvHelper         = helpers.DotEnvHelper
 	timeHelper           helpers.ITimeHelper           = helpers.TimeHelper
+	attemptLimitHelper   helpers.IAttemptLimitHelper   = helpers.AttemptLimitHelper
+	passwordPolicyHelper helpers.IPasswordPolicyHelper = helpers.PasswordPolicyHelper
+	sessionCookieHelper  helpers.ISessionCookieHelper  = helpers.SessionCookieHelper
 
-	sendGridMailService services.ISendGridMailService = services.SendGridMailService
+	mailService services.IMailService = services.MailService
 
 	verifcationCodeExpiration string = dotEnvHelper.GetEnvVariable("VERIFICATION_MAIL_CODE_EXPIRATION")
 	fromName                  string = dotEnvHelper.GetEnvVariable("SENDGRID_FROM_NAME")
 	fromEmail                 string = dotEnvHelper.GetEnvVariable("SENDGRID_FROM_EMAIL")
-	verifcationMailReturnHost string = dotEnvHelper.GetEnvVariable("VERIFICATION_MAIL_RETURN_HOST")
-	verifcationMailReturnPort string = dotEnvHelper.GetEnvVariable("VERIFICATION_MAIL_RETURN_PORT")
 	refreshTokenTTL                  = dotEnvHelper.GetEnvVariable("REFRESH_TOKEN_TTL")
+	emailChangeCodeExpiration string = dotEnvHelper.GetEnvVariable("EMAIL_CHANGE_MAIL_CODE_EXPIRATION")
 
 	validate = validator.New()
+
+	errAccountDisabled = errors.New("account is disabled")
 )
 
 type IAuthController interface {
 	SignUp(c *gin.Context)
 	Login(c *gin.Context)
 	RefreshToken(c *gin.Context)
+	Logout(c *gin.Context)
 	ResetUserPassword(c *gin.Context)
+	ChangePassword(c *gin.Context)
+	ChangeEmail(c *gin.Context)
 	TestRedis(c *gin.Context)
 }
 
@@ -89,6 +97,20 @@ func (a *authControllerStruct) SignUp(c *gin.Context) {
 		return
 	}
 
+	normalizedPhone, phoneValidationErr := dataValidationHelper.NormalizePhoneNumber(user.Phone)
+
+	if phoneValidationErr != nil {
+		logger.Logger.Error(phoneValidationErr.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": phoneValidationErr.Error()})
+		return
+	}
+
+	user.Phone = normalizedPhone
+
+	if !checkPasswordPolicy(c, user.Password, user.Email, user.First_name, user.Last_name) {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
@@ -132,6 +154,10 @@ func (a *authControllerStruct) SignUp(c *gin.Context) {
 	}
 
 	user.Is_email_verified = false
+	user.Is_phone_verified = false
+	user.Is_mfa_enabled = false
+	user.Is_mfa_required = false
+	user.Is_disabled = false
 
 	user.ID = primitive.NewObjectID()
 	user.User_id = user.ID.Hex()
@@ -148,17 +174,14 @@ func (a *authControllerStruct) SignUp(c *gin.Context) {
 	user.Access_token = signedToken
 	user.Refresh_token = signedRefreshToken
 
-	resultInsertionNumber, insertError := userCollection.InsertOne(ctx, user)
-
-	if insertError != nil {
-		logger.Logger.Error(insertError.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": insertError})
-		return
-	}
+	// the user and the verification mail are committed together, a failed mail never leaves an account behind
+	err = runInTransaction(ctx, func(sessionContext mongo.SessionContext) error {
+		if _, err := userCollection.InsertOne(sessionContext, user); err != nil {
+			return err
+		}
 
-	// mail verification
-	randomSixDigits := randomCodeGenerator.GenerateRandomDigits(6)
-	verifcationCodeExpirationInt, err := strconv.ParseInt(verifcationCodeExpiration, 10, 64)
+		return sendVerificationMail(sessionContext, user)
+	})
 
 	if err != nil {
 		logger.Logger.Error(err.Error())
@@ -166,94 +189,7 @@ func (a *authControllerStruct) SignUp(c *gin.Context) {
 		return
 	}
 
-	expires_at, err := timeHelper.GetCurrentLocationTimeWithAdditionalDuration(time.Hour * time.Duration(verifcationCodeExpirationInt))
-
-	if err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while parsing mail expires_at"})
-		return
-	}
-
-	// send email
-	tos := []*mail.Email{
-		// hardcoded for testing
-		mail.NewEmail("yyhyap98", "yyhyap98@gmail.com"),
-	}
-
-	dynamicTemplateData := map[string]string{}
-	dynamicTemplateData["Full_Name"] = fmt.Sprintf("%s %s", user.First_name, user.Last_name)
-	encryptedEmailValue, err := aesEncryptionHelper.AesGCMEncrypt(user.Email)
-
-	if err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while encrypting user email"})
-		return
-	}
-
-	encryptedRandomSixDigits, err := aesEncryptionHelper.AesGCMEncrypt(randomSixDigits)
-
-	if err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while encrypting random six digits"})
-		return
-	}
-
-	dynamicTemplateData["Verify_Mail_Link"] = fmt.Sprintf(
-		"%s:%s/api/test?email=%s&code=%s",
-		verifcationMailReturnHost, verifcationMailReturnPort, encryptedEmailValue, encryptedRandomSixDigits,
-	)
-
-	mailReq := &dto.MailRequest{
-		FromName:            fromName,
-		FromEmail:           fromEmail,
-		MailType:            enums.MailVerification,
-		Tos:                 tos,
-		DynamicTemplateData: dynamicTemplateData,
-	}
-
-	go sendGridMailService.SendMail(mailReq)
-
-	mailCount, err := mailCollection.CountDocuments(
-		ctx,
-		bson.D{
-			{Key: "email", Value: user.Email},
-			{Key: "type", Value: enums.MailVerification.String()},
-		},
-	)
-
-	if err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while counting mail from mail collection in db"})
-		return
-	}
-
-	if mailCount > 0 {
-		// update current verification mail
-		// update mail in db
-		mailUpdateError := sendGridMailService.UpdateEmail(enums.MailVerification, user.Email, randomSixDigits, expires_at)
-
-		if mailUpdateError != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":         "error occured while updating verification email in db",
-				"error_details": mailUpdateError.Error(),
-			})
-			return
-		}
-	} else {
-		// create new verification mail
-		// insert mail into db
-		mailInsertError := sendGridMailService.CreateNewMail(enums.MailVerification, user.Email, randomSixDigits, expires_at)
-
-		if mailInsertError != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":         "error occured while inserting new verification email into db",
-				"error_details": mailInsertError.Error(),
-			})
-			return
-		}
-	}
-
-	c.JSON(http.StatusOK, resultInsertionNumber)
+	respondUserWithTokens(c, user)
 }
 
 func (a *authControllerStruct) Login(c *gin.Context) {
@@ -266,6 +202,10 @@ func (a *authControllerStruct) Login(c *gin.Context) {
 		return
 	}
 
+	if !checkLoginRetryAfter(c, user.Email) {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
@@ -273,6 +213,7 @@ func (a *authControllerStruct) Login(c *gin.Context) {
 
 	if err != nil {
 		logger.Logger.Error(err.Error())
+		recordFailedLogin(c, models.User{Email: user.Email})
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "email or password is incorrect"})
 		return
 	}
@@ -281,6 +222,7 @@ func (a *authControllerStruct) Login(c *gin.Context) {
 
 	if passwordValidationError != nil {
 		logger.Logger.Error(passwordValidationError.Error())
+		recordFailedLogin(c, foundUser)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "email or password is incorrect"})
 		return
 	}
@@ -290,56 +232,50 @@ func (a *authControllerStruct) Login(c *gin.Context) {
 		return
 	}
 
-	signedToken, signedRefreshToken, err := tokenHelper.GenerateAllTokens(
-		foundUser.Email, foundUser.First_name, foundUser.Last_name, foundUser.User_id, foundUser.User_role, foundUser.Is_email_verified)
-
-	if err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	err = tokenHelper.UpdateAllTokens(signedToken, signedRefreshToken, foundUser.User_id)
-
-	if err != nil {
+	if err := attemptLimitHelper.ResetFailedLogins(foundUser.Email); err != nil {
 		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
 	}
 
-	err = userCollection.FindOne(ctx, bson.M{"user_id": foundUser.User_id}).Decode(&foundUser)
-
-	if err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	// the plain password is only known here, so this is where hashes with outdated parameters get upgraded
+	if passwordHelper.NeedsRehash(foundUser.Password) {
+		rehashPassword(ctx, foundUser, user.Password)
 	}
 
-	// loc, _ := time.LoadLocation("Asia/Singapore")
-	// logger.Logger.Debug(fmt.Sprintf("local date time %v", foundUser.Updated_at.In(loc)))
-	c.JSON(http.StatusOK, foundUser)
+	respondLoginSuccess(ctx, c, foundUser)
 }
 
 func (a *authControllerStruct) RefreshToken(c *gin.Context) {
 	var requestBody map[string]interface{}
+	var signedRefreshToken string
 
-	jsonData, err := io.ReadAll(c.Request.Body)
+	if sessionCookieHelper.IsCookieMode(c) {
+		// cookie session, the refresh token never reaches javascript
+		if !sessionCookieHelper.ValidateCsrfToken(c) {
+			logger.Logger.Error("csrf token is missing or does not match")
+			c.JSON(http.StatusForbidden, gin.H{"error": "csrf token is missing or does not match"})
+			return
+		}
 
-	if err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+		signedRefreshToken = sessionCookieHelper.GetRefreshToken(c)
+	} else {
+		jsonData, err := io.ReadAll(c.Request.Body)
 
-	if err := json.Unmarshal(jsonData, &requestBody); err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+		if err != nil {
+			logger.Logger.Error(err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
-	signedRefreshToken, ok := requestBody["refresh_token"].(string)
+		if err := json.Unmarshal(jsonData, &requestBody); err != nil {
+			logger.Logger.Error(err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
-	if !ok {
+		signedRefreshToken, _ = requestBody["refresh_token"].(string)
+	}
+
+	if signedRefreshToken == "" {
 		logger.Logger.Error("invalid refresh token")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid refresh token"})
 		return
@@ -431,6 +367,11 @@ func (a *authControllerStruct) RefreshToken(c *gin.Context) {
 		return
 	}
 
+	if foundUser.Is_disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": errAccountDisabled.Error()})
+		return
+	}
+
 	signedToken, signedRefreshToken, err := tokenHelper.GenerateAllTokens(
 		foundUser.Email, foundUser.First_name, foundUser.Last_name, foundUser.User_id, foundUser.User_role, foundUser.Is_email_verified)
 
@@ -456,7 +397,13 @@ func (a *authControllerStruct) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, foundUser)
+	respondUserWithTokens(c, foundUser)
+}
+
+// Logout clears the session cookies, javascript cannot remove HttpOnly cookies itself
+func (a *authControllerStruct) Logout(c *gin.Context) {
+	sessionCookieHelper.ClearSessionCookies(c)
+	c.Status(http.StatusOK)
 }
 
 func (a *authControllerStruct) ResetUserPassword(c *gin.Context) {
@@ -490,9 +437,6 @@ func (a *authControllerStruct) ResetUserPassword(c *gin.Context) {
 	userCh := make(chan models.User)
 	userErrCh := make(chan error)
 
-	mailCh := make(chan models.Mail)
-	mailErrCh := make(chan error)
-
 	go func(email string) {
 		var u models.User
 		userErr := userCollection.FindOne(ctx, bson.M{"email": email}).Decode(&u)
@@ -500,16 +444,6 @@ func (a *authControllerStruct) ResetUserPassword(c *gin.Context) {
 		userCh <- u
 	}(passwordResetRequest.Email)
 
-	go func(email, emailType string) {
-		var m models.Mail
-		mailErr := mailCollection.FindOne(ctx, bson.D{
-			{Key: "email", Value: passwordResetRequest.Email},
-			{Key: "type", Value: enums.PasswordReset.String()},
-		}).Decode(&m)
-		mailErrCh <- mailErr
-		mailCh <- m
-	}(passwordResetRequest.Email, enums.PasswordReset.String())
-
 	err = <-userErrCh
 
 	if err != nil {
@@ -529,20 +463,13 @@ func (a *authControllerStruct) ResetUserPassword(c *gin.Context) {
 		return
 	}
 
-	err = <-mailErrCh
+	passwordResetMail, ok := verifyMailCode(ctx, c, enums.PasswordReset, passwordResetRequest.Email, passwordResetRequest.Code, "password reset mail code not match", "password reset mail has expired")
 
-	if err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if !ok {
 		return
 	}
 
-	passwordResetMail := <-mailCh
-
-	// check code
-	if passwordResetRequest.Code != passwordResetMail.Code {
-		logger.Logger.Error("password reset mail code not match")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "password reset mail code not match"})
+	if !checkPasswordPolicy(c, passwordResetRequest.Password, user.Email, user.First_name, user.Last_name) {
 		return
 	}
 
@@ -602,11 +529,8 @@ func (a *authControllerStruct) ResetUserPassword(c *gin.Context) {
 			return err
 		}
 
-		// now remove password reset mail from mailCollection
-		_, err = mailCollection.DeleteOne(sessionContext, bson.D{
-			{Key: "email", Value: passwordResetMail.Email},
-			{Key: "type", Value: enums.PasswordReset.String()},
-		})
+		// the code is kept until it expires, but cannot be used again
+		err = mailService.ConsumeMailCode(sessionContext, passwordResetMail)
 
 		if err != nil {
 			logger.Logger.Error(err.Error())
@@ -628,12 +552,268 @@ func (a *authControllerStruct) ResetUserPassword(c *gin.Context) {
 		return nil
 	})
 
+	if errors.Is(err, services.ErrMailCodeConsumed) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := attemptLimitHelper.ResetMailCodeAttempts(enums.PasswordReset, passwordResetMail.Email); err != nil {
+		logger.Logger.Error(err.Error())
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (a *authControllerStruct) ChangePassword(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	var changePasswordRequest dto.ChangePasswordRequestDto
+
+	if err := c.BindJSON(&changePasswordRequest); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if validationError := validate.Struct(changePasswordRequest); validationError != nil {
+		logger.Logger.Error(validationError.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+		return
+	}
+
+	if changePasswordRequest.Password != changePasswordRequest.ConfirmPassword {
+		logger.Logger.Error("password and confirm password not matching")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password and confirm password not matching"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		return
+	}
+
+	if !checkLoginRetryAfter(c, user.Email) {
+		return
+	}
+
+	if err := passwordHelper.VerifyPassword(user.Password, changePasswordRequest.CurrentPassword); err != nil {
+		logger.Logger.Error(err.Error())
+		recordFailedLogin(c, user)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "current password is incorrect"})
+		return
+	}
+
+	if err := passwordHelper.VerifyPassword(user.Password, changePasswordRequest.Password); err == nil {
+		logger.Logger.Error("new password is same as old password")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "new password is same as old password"})
+		return
+	}
+
+	if !checkPasswordPolicy(c, changePasswordRequest.Password, user.Email, user.First_name, user.Last_name) {
+		return
+	}
+
+	hashedPassword, err := passwordHelper.HashPassword(changePasswordRequest.Password)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = updateUserFields(ctx, user.User_id, bson.D{{Key: "password", Value: hashedPassword}})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// revoke every other session, then hand this one a fresh token pair
+	err = tokenHelper.SetBlacklistAccessAndRefreshTokenUserId(user.User_id)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err = generateAndUpdateAllTokens(ctx, user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(tokenErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	respondUserWithTokens(c, user)
+}
+
+func (a *authControllerStruct) ChangeEmail(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	var changeEmailRequest dto.ChangeEmailRequestDto
+
+	if err := c.BindJSON(&changeEmailRequest); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if validationError := validate.Struct(changeEmailRequest); validationError != nil {
+		logger.Logger.Error(validationError.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+		return
+	}
+
+	if emailValidationErr := dataValidationHelper.IsEmailValid(changeEmailRequest.NewEmail); emailValidationErr != nil {
+		logger.Logger.Error(emailValidationErr.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": emailValidationErr.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		return
+	}
+
+	if !checkLoginRetryAfter(c, user.Email) {
+		return
+	}
+
+	if err := passwordHelper.VerifyPassword(user.Password, changeEmailRequest.CurrentPassword); err != nil {
+		logger.Logger.Error(err.Error())
+		recordFailedLogin(c, user)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "current password is incorrect"})
+		return
+	}
+
+	if changeEmailRequest.NewEmail == user.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "new email is same as current email"})
+		return
+	}
+
+	emailCount, err := userCollection.CountDocuments(ctx, bson.M{"email": changeEmailRequest.NewEmail})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if emailCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user email already exists"})
+		return
+	}
+
+	randomSixDigits := randomCodeGenerator.GenerateRandomDigits(6)
+	emailChangeCodeExpirationInt, err := strconv.ParseInt(emailChangeCodeExpiration, 10, 64)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	expires_at, err := timeHelper.GetCurrentLocationTimeWithAdditionalDuration(time.Hour * time.Duration(emailChangeCodeExpirationInt))
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while parsing mail expires_at"})
+		return
+	}
+
+	emailChangeMailLink, err := actionLinkHelper.GenerateLink(enums.EmailChange, changeEmailRequest.NewEmail, randomSixDigits, expires_at, nil)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the email change link"})
+		return
+	}
+
+	fullName := fmt.Sprintf("%s %s", user.First_name, user.Last_name)
+
+	// confirmation to the new address
+	confirmationTos := []dto.MailAddress{
+		{Name: fullName, Address: changeEmailRequest.NewEmail},
+	}
+
+	confirmationTemplateData := map[string]string{}
+	confirmationTemplateData["Full_Name"] = fullName
+	confirmationTemplateData["New_Email"] = changeEmailRequest.NewEmail
+	confirmationTemplateData["Email_Change_Mail_Link"] = emailChangeMailLink
+
+	// notice to the old address
+	noticeTos := []dto.MailAddress{userMailAddress(user)}
+
+	noticeTemplateData := map[string]string{}
+	noticeTemplateData["Full_Name"] = fullName
+	noticeTemplateData["New_Email"] = changeEmailRequest.NewEmail
+
+	err = runInTransaction(ctx, func(sessionContext mongo.SessionContext) error {
+		_, err := mailService.IssueMailCode(sessionContext, enums.EmailChange, user.User_id, changeEmailRequest.NewEmail, randomSixDigits, expires_at)
+
+		if err != nil {
+			return fmt.Errorf("error occured while inserting new email change mail into db: %w", err)
+		}
+
+		err = mailService.EnqueueMail(sessionContext, &dto.MailRequest{
+			FromName:            fromName,
+			FromEmail:           fromEmail,
+			MailType:            enums.EmailChange,
+			Locale:              user.Locale,
+			UserId:              user.User_id,
+			Tos:                 confirmationTos,
+			DynamicTemplateData: confirmationTemplateData,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return mailService.EnqueueMail(sessionContext, &dto.MailRequest{
+			FromName:            fromName,
+			FromEmail:           fromEmail,
+			MailType:            enums.EmailChangeNotice,
+			Locale:              user.Locale,
+			UserId:              user.User_id,
+			Tos:                 noticeTos,
+			DynamicTemplateData: noticeTemplateData,
+		})
+	})
+
 	if err != nil {
 		logger.Logger.Error(err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := attemptLimitHelper.ResetMailCodeAttempts(enums.EmailChange, changeEmailRequest.NewEmail); err != nil {
+		logger.Logger.Error(err.Error())
+	}
+
 	c.Status(http.StatusOK)
 }
 
@@ -685,3 +865,232 @@ func (a *authControllerStruct) TestRedis(c *gin.Context) {
 		"blacklist_refresh_token_expiration": blacklistRefreshTokenExpirationStr,
 	})
 }
+
+// generateAndUpdateAllTokens issues a new token pair for the user, stores it and returns the updated user
+func generateAndUpdateAllTokens(ctx context.Context, user models.User) (models.User, error) {
+	if user.Is_disabled {
+		return user, errAccountDisabled
+	}
+
+	signedToken, signedRefreshToken, err := tokenHelper.GenerateAllTokens(
+		user.Email, user.First_name, user.Last_name, user.User_id, user.User_role, user.Is_email_verified)
+
+	if err != nil {
+		return user, err
+	}
+
+	err = tokenHelper.UpdateAllTokens(signedToken, signedRefreshToken, user.User_id)
+
+	if err != nil {
+		return user, err
+	}
+
+	var updatedUser models.User
+
+	err = userCollection.FindOne(ctx, bson.M{"user_id": user.User_id}).Decode(&updatedUser)
+
+	if err != nil {
+		return user, err
+	}
+
+	return updatedUser, nil
+}
+
+// checkPasswordPolicy responds 400 with the field-level policy violations of a new password
+func checkPasswordPolicy(c *gin.Context, password, email, firstName, lastName string) bool {
+	violations := passwordPolicyHelper.Validate(password, email, firstName, lastName)
+
+	if len(violations) > 0 {
+		logger.Logger.Error("password does not meet the password policy")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "password does not meet the password policy",
+			"fields": violations,
+		})
+		return false
+	}
+
+	return true
+}
+
+// rehashPassword stores the password hashed with the current parameters, a failure only costs the upgrade
+func rehashPassword(ctx context.Context, user models.User, password string) {
+	hashedPassword, err := passwordHelper.HashPassword(password)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		return
+	}
+
+	_, err = userCollection.UpdateOne(
+		ctx,
+		bson.M{"user_id": user.User_id, "password": user.Password},
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "password", Value: hashedPassword}}},
+		},
+	)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		return
+	}
+
+	logger.Logger.Info(fmt.Sprintf("password hash of %s has been upgraded", user.User_id))
+}
+
+// newTokenResponse hands the tokens of the user to the client, in HttpOnly cookies for cookie sessions
+// and in the response body otherwise
+func newTokenResponse(c *gin.Context, user models.User) (dto.UserResponseDto, error) {
+	if !sessionCookieHelper.IsCookieMode(c) {
+		return dto.NewUserResponseDto(user, true), nil
+	}
+
+	if err := sessionCookieHelper.SetSessionCookies(c, user.Access_token, user.Refresh_token); err != nil {
+		return dto.UserResponseDto{}, err
+	}
+
+	return dto.NewUserResponseDto(user, false), nil
+}
+
+func respondUserWithTokens(c *gin.Context, user models.User) {
+	userResponse, err := newTokenResponse(c, user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, userResponse)
+}
+
+// runInTransaction commits every write of fn together, fn must pass the session context to each of them
+func runInTransaction(ctx context.Context, fn func(sessionContext mongo.SessionContext) error) error {
+	return nftRaffleDbClient.UseSession(ctx, func(sessionContext mongo.SessionContext) error {
+		if err := sessionContext.StartTransaction(); err != nil {
+			return err
+		}
+
+		if err := fn(sessionContext); err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		return sessionContext.CommitTransaction(sessionContext)
+	})
+}
+
+// tokenErrorStatus maps the error of generateAndUpdateAllTokens to the response status
+func tokenErrorStatus(err error) int {
+	if errors.Is(err, errAccountDisabled) {
+		return http.StatusForbidden
+	}
+
+	return http.StatusInternalServerError
+}
+
+// respondLoginSuccess answers an authenticated login, either with a fresh token pair
+// or with an mfa token when the second step is still pending
+func respondLoginSuccess(ctx context.Context, c *gin.Context, user models.User) {
+	if user.Is_disabled {
+		logger.Logger.Error(fmt.Sprintf("login to disabled account %s", user.User_id))
+		c.JSON(http.StatusForbidden, gin.H{"error": errAccountDisabled.Error()})
+		return
+	}
+
+	// two-step login, real tokens are only issued by the mfa verify endpoint
+	if user.Is_mfa_enabled || isMfaRequired(user) {
+		mfaToken, err := tokenHelper.GenerateMfaToken(user.User_id, !user.Is_mfa_enabled)
+
+		if err != nil {
+			logger.Logger.Error(err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required":            true,
+			"mfa_enrollment_required": !user.Is_mfa_enabled,
+			"mfa_token":               mfaToken,
+		})
+		return
+	}
+
+	user, err := generateAndUpdateAllTokens(ctx, user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(tokenErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// loc, _ := time.LoadLocation("Asia/Singapore")
+	// logger.Logger.Debug(fmt.Sprintf("local date time %v", user.Updated_at.In(loc)))
+	respondUserWithTokens(c, user)
+}
+
+// checkLoginRetryAfter rejects the request with 429 while the account or the client ip is locked out or delayed
+func checkLoginRetryAfter(c *gin.Context, email string) bool {
+	retryAfter, err := attemptLimitHelper.GetLoginRetryAfter(email, c.ClientIP())
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if retryAfter > 0 {
+		retryAfterSeconds := int64(retryAfter.Seconds())
+
+		if retryAfterSeconds < 1 {
+			retryAfterSeconds = 1
+		}
+
+		logger.Logger.Warn(fmt.Sprintf("login for %s from %s is throttled for %v seconds", email, c.ClientIP(), retryAfterSeconds))
+		c.Header("Retry-After", strconv.FormatInt(retryAfterSeconds, 10))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts, please try again later"})
+		return false
+	}
+
+	return true
+}
+
+// recordFailedLogin counts the failure and notifies the owner when the account gets locked
+func recordFailedLogin(c *gin.Context, user models.User) {
+	isAccountLocked, err := attemptLimitHelper.RecordFailedLogin(user.Email, c.ClientIP())
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		return
+	}
+
+	if !isAccountLocked || user.User_id == "" {
+		return
+	}
+
+	logger.Logger.Warn(fmt.Sprintf("account %s has been locked after too many failed attempts", user.User_id))
+
+	// send email
+	tos := []dto.MailAddress{userMailAddress(user)}
+
+	dynamicTemplateData := map[string]string{}
+	dynamicTemplateData["Full_Name"] = fmt.Sprintf("%s %s", user.First_name, user.Last_name)
+	dynamicTemplateData["Lockout_Minutes"] = strconv.Itoa(int(attemptLimitHelper.GetLoginLockoutDuration().Minutes()))
+	dynamicTemplateData["Ip_Address"] = c.ClientIP()
+
+	mailReq := &dto.MailRequest{
+		FromName:            fromName,
+		FromEmail:           fromEmail,
+		MailType:            enums.AccountLocked,
+		Locale:              user.Locale,
+		UserId:              user.User_id,
+		Tos:                 tos,
+		DynamicTemplateData: dynamicTemplateData,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if err := mailService.EnqueueMail(ctx, mailReq); err != nil {
+		logger.Logger.Error(err.Error())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"nft-raffle/logger"
 	"nft-raffle/models"
+	"nft-raffle/services"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -18,6 +19,8 @@ var (
 	ExpenseController IExpenseController = NewExpenseController()
 
 	expenseCollection *mongo.Collection = nftRaffleDb.OpenCollection(nftRaffleDbClient, "expense")
+
+	expenseService services.IExpenseService = services.ExpenseService
 )
 
 type NewExpenseRequest struct {
@@ -291,72 +294,12 @@ func (e expenseControllerStruct) GetExpensesByType(c *gin.Context) {
 		return
 	}
 
-	var andQuery []bson.M
-	andQuery = append(andQuery, bson.M{"user_id": userId})
-	andQuery = append(andQuery, bson.M{"expense_time": bson.M{"$gte": fromDate}})
-	andQuery = append(andQuery, bson.M{"expense_time": bson.M{"$lte": toDate}})
-
-	matchStage := bson.D{
-		{Key: "$match", Value: bson.M{
-			"$and": andQuery,
-		}},
-	}
-
-	groupStageByExpenseType := bson.D{
-		{Key: "$group", Value: bson.D{
-			{Key: "_id", Value: "$expense_type"},
-			{Key: "expenseAmountByType", Value: bson.M{
-				"$sum": "$expense_amount",
-			}},
-			{Key: "expenseCountByType", Value: bson.M{
-				"$count": bson.M{},
-			}},
-			{Key: "expenseTypeData", Value: bson.M{
-				"$push": "$$ROOT",
-			}},
-		}},
-	}
-
-	sortStage := bson.D{
-		{Key: "$sort", Value: bson.D{
-			{Key: "expenseAmountByType", Value: -1},
-		}},
-	}
-
-	groupStage2 := bson.D{
-		{Key: "$group", Value: bson.D{
-			{Key: "_id", Value: "null"},
-			{Key: "totalExpenseTypes", Value: bson.M{
-				"$count": bson.M{},
-			}},
-			{Key: "data", Value: bson.M{
-				"$push": "$$ROOT",
-			}},
-		}},
-	}
-
-	projectStage := bson.D{
-		{Key: "$project", Value: bson.D{
-			{Key: "_id", Value: 0},
-			{Key: "totalExpenseTypes", Value: 1},
-			{Key: "data", Value: 1},
-		}},
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	result, err := expenseCollection.Aggregate(ctx, mongo.Pipeline{matchStage, groupStageByExpenseType, sortStage, groupStage2, projectStage})
-
-	if err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
 	var data []bson.M
 
-	err = result.All(ctx, &data)
+	err = expenseService.AggregateExpensesByType(ctx, userId, fromDate, toDate, &data)
 
 	if err != nil {
 		logger.Logger.Error(err.Error())
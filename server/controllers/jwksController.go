@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http"
+	"nft-raffle/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	JwksController IJwksController = NewJwksController()
+
+	jwtKeySetHelper helpers.IJwtKeySetHelper = helpers.JwtKeySetHelper
+)
+
+type IJwksController interface {
+	GetJwks(c *gin.Context)
+}
+
+type jwksControllerStruct struct{}
+
+func NewJwksController() IJwksController {
+	return &jwksControllerStruct{}
+}
+
+func (j *jwksControllerStruct) GetJwks(c *gin.Context) {
+	keySet := jwtKeySetHelper.GetKeySet()
+
+	if keySet == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "asymmetric jwt signing is not configured"})
+		return
+	}
+
+	// verifiers cache the key set, keep it short so a rotated key is picked up quickly
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, keySet.Jwks())
+}
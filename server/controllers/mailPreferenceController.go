@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"nft-raffle/dto"
+	"nft-raffle/enums"
+	"nft-raffle/helpers"
+	"nft-raffle/logger"
+	"nft-raffle/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	MailPreferenceController IMailPreferenceController = NewMailPreferenceController()
+
+	mailPreferenceHelper helpers.IMailPreferenceHelper = helpers.MailPreferenceHelper
+
+	// GET only shows a confirmation, link scanners of mail providers must not unsubscribe anyone
+	unsubscribePageTemplate = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Unsubscribe</title></head>
+<body style="font-family:Arial,Helvetica,sans-serif;padding:24px;">
+<p>Stop receiving {{.Category}} mails?</p>
+<form method="post" action="?token={{.Token}}">
+<input type="hidden" name="List-Unsubscribe" value="One-Click">
+<button type="submit">Unsubscribe</button>
+</form>
+</body>
+</html>
+`))
+)
+
+type IMailPreferenceController interface {
+	GetMailPreferences(c *gin.Context)
+	UpdateMailPreferences(c *gin.Context)
+	ShowUnsubscribe(c *gin.Context)
+	Unsubscribe(c *gin.Context)
+}
+
+type mailPreferenceControllerStruct struct{}
+
+func NewMailPreferenceController() IMailPreferenceController {
+	return &mailPreferenceControllerStruct{}
+}
+
+func (m *mailPreferenceControllerStruct) GetMailPreferences(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mail_preferences": mailPreferenceHelper.GetPreferences(user)})
+}
+
+func (m *mailPreferenceControllerStruct) UpdateMailPreferences(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	var request dto.UpdateMailPreferencesRequestDto
+
+	if err := c.BindJSON(&request); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var updateObj bson.D
+
+	for category, isEnabled := range map[enums.MailCategory]*bool{
+		enums.RaffleResultsMail: request.Raffle_results,
+		enums.MarketingMail:     request.Marketing,
+		enums.DigestMail:        request.Digest,
+	} {
+		if isEnabled != nil {
+			updateObj = append(updateObj, bson.E{Key: "mail_preferences." + category.String(), Value: *isEnabled})
+		}
+	}
+
+	if len(updateObj) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no mail preference to update"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if err := updateUserFields(ctx, userId, updateObj); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mail_preferences": mailPreferenceHelper.GetPreferences(user)})
+}
+
+func (m *mailPreferenceControllerStruct) ShowUnsubscribe(c *gin.Context) {
+	token := c.Query(helpers.ActionLinkTokenQuery)
+
+	_, category, err := mailPreferenceHelper.ParseUnsubscribeToken(token)
+
+	if err != nil {
+		respondInvalidUnsubscribe(c, err)
+		return
+	}
+
+	var page bytes.Buffer
+
+	if err := unsubscribePageTemplate.Execute(&page, gin.H{"Category": category.String(), "Token": token}); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
+// Unsubscribe is the RFC 8058 one-click endpoint of the List-Unsubscribe header, the signed token is the only
+// credential so it works from the mail client without a session
+func (m *mailPreferenceControllerStruct) Unsubscribe(c *gin.Context) {
+	userId, category, err := mailPreferenceHelper.ParseUnsubscribeToken(c.Query(helpers.ActionLinkTokenQuery))
+
+	if err != nil {
+		respondInvalidUnsubscribe(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	err = updateUserFields(ctx, userId, bson.D{{Key: "mail_preferences." + category.String(), Value: false}})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Logger.Info(fmt.Sprintf("user %s unsubscribed from %s mails", userId, category.String()))
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("unsubscribed from %s mails", category.String())})
+}
+
+func respondInvalidUnsubscribe(c *gin.Context, err error) {
+	if errors.Is(err, helpers.ErrInvalidUnsubscribeToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Logger.Error(err.Error())
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "unsubscribe is not available"})
+}
@@ -0,0 +1,544 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"nft-raffle/dto"
+	"nft-raffle/enums"
+	"nft-raffle/helpers"
+	"nft-raffle/logger"
+	"nft-raffle/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	MfaController IMfaController = NewMfaController()
+
+	totpHelper helpers.ITotpHelper = helpers.TotpHelper
+
+	// comma separated user roles that must use 2FA, e.g. "ADMIN"
+	mfaRequiredUserRoles string = dotEnvHelper.GetEnvVariable("MFA_REQUIRED_USER_ROLES")
+
+	errMfaCodeInvalid = errors.New("mfa code is invalid")
+	errMfaCodeUsed    = errors.New("mfa code has already been used")
+)
+
+type IMfaController interface {
+	Enroll(c *gin.Context)
+	ConfirmEnrollment(c *gin.Context)
+	VerifyLogin(c *gin.Context)
+	Disable(c *gin.Context)
+	RegenerateRecoveryCodes(c *gin.Context)
+	SetMfaRequired(c *gin.Context)
+}
+
+type mfaControllerStruct struct{}
+
+func NewMfaController() IMfaController {
+	return &mfaControllerStruct{}
+}
+
+func (m *mfaControllerStruct) Enroll(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		return
+	}
+
+	if user.Is_mfa_enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa is already enabled"})
+		return
+	}
+
+	secret, err := totpHelper.GenerateSecret()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating mfa secret"})
+		return
+	}
+
+	encryptedSecret, err := aesEncryptionHelper.AesGCMEncrypt(secret)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while encrypting mfa secret"})
+		return
+	}
+
+	err = updateUserFields(ctx, user.User_id, bson.D{{Key: "mfa_pending_secret", Value: encryptedSecret}})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": totpHelper.GenerateOtpAuthUri(secret, user.Email),
+	})
+}
+
+func (m *mfaControllerStruct) ConfirmEnrollment(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	var request dto.MfaCodeRequestDto
+
+	if err := c.BindJSON(&request); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		return
+	}
+
+	if user.Mfa_pending_secret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa enrollment has not been started"})
+		return
+	}
+
+	secret, err := aesEncryptionHelper.AesGCMDecrypt(user.Mfa_pending_secret)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while decrypting mfa secret"})
+		return
+	}
+
+	step, ok := totpHelper.ValidateCode(secret, request.Code, time.Now())
+
+	if !ok {
+		logger.Logger.Error(errMfaCodeInvalid.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMfaCodeInvalid.Error()})
+		return
+	}
+
+	recoveryCodes, recoveryCodeHashes, err := generateRecoveryCodes()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating recovery codes"})
+		return
+	}
+
+	err = updateUserFields(ctx, user.User_id, bson.D{
+		{Key: "is_mfa_enabled", Value: true},
+		{Key: "mfa_secret", Value: user.Mfa_pending_secret},
+		{Key: "mfa_pending_secret", Value: ""},
+		{Key: "mfa_last_used_step", Value: step},
+		{Key: "mfa_recovery_code_hashes", Value: recoveryCodeHashes},
+	})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// forced enrollment during login, finish the login now
+	if c.GetBool("is_mfa_enrollment") {
+		user, err = generateAndUpdateAllTokens(ctx, user)
+
+		if err != nil {
+			logger.Logger.Error(err.Error())
+			c.JSON(tokenErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		userResponse, err := newTokenResponse(c, user)
+
+		if err != nil {
+			logger.Logger.Error(err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"recovery_codes": recoveryCodes,
+			"user":           userResponse,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+func (m *mfaControllerStruct) VerifyLogin(c *gin.Context) {
+	var request dto.MfaLoginRequestDto
+
+	if err := c.BindJSON(&request); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if validationErr := validate.Struct(request); validationErr != nil {
+		logger.Logger.Error(validationErr.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		return
+	}
+
+	claims, err := tokenHelper.ValidateMfaToken(request.MfaToken)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if claims.Is_enrollment {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa enrollment is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err = userCollection.FindOne(ctx, bson.M{"user_id": claims.Uid}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		return
+	}
+
+	if !user.Is_mfa_enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa is not enabled"})
+		return
+	}
+
+	if !checkLoginRetryAfter(c, user.Email) {
+		return
+	}
+
+	err = verifyMfaCode(ctx, user, request.Code, request.RecoveryCode)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		recordFailedLogin(c, user)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := attemptLimitHelper.ResetFailedLogins(user.Email); err != nil {
+		logger.Logger.Error(err.Error())
+	}
+
+	user, err = generateAndUpdateAllTokens(ctx, user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(tokenErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	respondUserWithTokens(c, user)
+}
+
+func (m *mfaControllerStruct) Disable(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	var request dto.MfaCodeRequestDto
+
+	if err := c.BindJSON(&request); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		return
+	}
+
+	if !user.Is_mfa_enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa is not enabled"})
+		return
+	}
+
+	if isMfaRequired(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "mfa is required for this account"})
+		return
+	}
+
+	err = verifyMfaCode(ctx, user, request.Code, request.RecoveryCode)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = updateUserFields(ctx, user.User_id, bson.D{
+		{Key: "is_mfa_enabled", Value: false},
+		{Key: "mfa_secret", Value: ""},
+		{Key: "mfa_pending_secret", Value: ""},
+		{Key: "mfa_last_used_step", Value: 0},
+		{Key: "mfa_recovery_code_hashes", Value: []string{}},
+	})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (m *mfaControllerStruct) RegenerateRecoveryCodes(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	var request dto.MfaCodeRequestDto
+
+	if err := c.BindJSON(&request); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		return
+	}
+
+	if !user.Is_mfa_enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa is not enabled"})
+		return
+	}
+
+	err = verifyMfaCode(ctx, user, request.Code, "")
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	recoveryCodes, recoveryCodeHashes, err := generateRecoveryCodes()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating recovery codes"})
+		return
+	}
+
+	err = updateUserFields(ctx, user.User_id, bson.D{{Key: "mfa_recovery_code_hashes", Value: recoveryCodeHashes}})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+func (m *mfaControllerStruct) SetMfaRequired(c *gin.Context) {
+	var request dto.MfaRequirementRequestDto
+
+	if err := c.BindJSON(&request); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if validationErr := validate.Struct(request); validationErr != nil {
+		logger.Logger.Error(validationErr.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	userCount, err := userCollection.CountDocuments(ctx, bson.M{"user_id": request.UserId})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if userCount < 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	err = updateUserFields(ctx, request.UserId, bson.D{{Key: "is_mfa_required", Value: *request.IsMfaRequired}})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeAdminActionLog(ctx, c, enums.SetUserMfaRequired, request.UserId, map[string]string{
+		"is_mfa_required": strconv.FormatBool(*request.IsMfaRequired),
+	})
+
+	c.Status(http.StatusOK)
+}
+
+func isMfaRequired(user models.User) bool {
+	if user.Is_mfa_required {
+		return true
+	}
+
+	for _, role := range strings.Split(mfaRequiredUserRoles, ",") {
+		if strings.TrimSpace(role) != "" && strings.TrimSpace(role) == user.User_role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyMfaCode checks a TOTP code or, when no code is given, a one-time recovery code.
+// Both are consumed atomically so the same code cannot be replayed.
+func verifyMfaCode(ctx context.Context, user models.User, code, recoveryCode string) error {
+	if code != "" {
+		secret, err := aesEncryptionHelper.AesGCMDecrypt(user.Mfa_secret)
+
+		if err != nil {
+			return err
+		}
+
+		step, ok := totpHelper.ValidateCode(secret, code, time.Now())
+
+		if !ok {
+			return errMfaCodeInvalid
+		}
+
+		result, err := userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": user.User_id, "mfa_last_used_step": bson.M{"$lt": step}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "mfa_last_used_step", Value: step}}}},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		if result.MatchedCount < 1 {
+			return errMfaCodeUsed
+		}
+
+		return nil
+	}
+
+	if recoveryCode != "" {
+		recoveryCodeHash := totpHelper.HashRecoveryCode(recoveryCode)
+
+		result, err := userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": user.User_id, "mfa_recovery_code_hashes": recoveryCodeHash},
+			bson.D{{Key: "$pull", Value: bson.D{{Key: "mfa_recovery_code_hashes", Value: recoveryCodeHash}}}},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		if result.MatchedCount < 1 {
+			return errMfaCodeInvalid
+		}
+
+		return nil
+	}
+
+	return errors.New("code or recovery code is required")
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	recoveryCodes, err := totpHelper.GenerateRecoveryCodes()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recoveryCodeHashes := make([]string, 0, len(recoveryCodes))
+
+	for _, recoveryCode := range recoveryCodes {
+		recoveryCodeHashes = append(recoveryCodeHashes, totpHelper.HashRecoveryCode(recoveryCode))
+	}
+
+	return recoveryCodes, recoveryCodeHashes, nil
+}
+
+func updateUserFields(ctx context.Context, userId string, updateObj bson.D) error {
+	Updated_at, err := timeHelper.GetCurrentLocationTime()
+
+	if err != nil {
+		return err
+	}
+
+	updateObj = append(updateObj, bson.E{Key: "updated_at", Value: Updated_at})
+
+	upsert := false
+	opt := options.UpdateOptions{
+		Upsert: &upsert,
+	}
+
+	_, err = userCollection.UpdateOne(
+		ctx,
+		bson.M{"user_id": userId},
+		bson.D{
+			{Key: "$set", Value: updateObj},
+		},
+		&opt,
+	)
+
+	return err
+}
@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"nft-raffle/enums"
+	"nft-raffle/helpers"
+	"nft-raffle/logger"
+	"nft-raffle/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// the redis session of the state expires after the same time
+	oidcStateCookieMaxAge time.Duration = 10 * time.Minute
+)
+
+var (
+	OidcController IOidcController = NewOidcController()
+
+	oidcHelper helpers.IOidcHelper = helpers.OidcHelper
+)
+
+type IOidcController interface {
+	Login(c *gin.Context)
+	Callback(c *gin.Context)
+}
+
+type oidcControllerStruct struct{}
+
+func NewOidcController() IOidcController {
+	return &oidcControllerStruct{}
+}
+
+// Login redirects the browser to the provider with a PKCE challenge, the verifier stays server side
+func (o *oidcControllerStruct) Login(c *gin.Context) {
+	provider, ok := oidcHelper.GetProvider(c.Param("provider"))
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "oidc provider is not configured"})
+		return
+	}
+
+	discovery, err := oidcHelper.Discover(provider)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadGateway, gin.H{"error": "error occured while contacting oidc provider"})
+		return
+	}
+
+	state, err := oidcHelper.GenerateRandomToken()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	nonce, err := oidcHelper.GenerateRandomToken()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	codeVerifier, err := oidcHelper.GenerateRandomToken()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = oidcHelper.SaveAuthorizationSession(state, helpers.OidcAuthorizationSession{
+		Provider:       provider.Name,
+		Nonce:          nonce,
+		CodeVerifier:   codeVerifier,
+		Is_cookie_mode: sessionCookieHelper.IsCookieMode(c),
+	})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionCookieHelper.SetFlowCookie(c, helpers.OidcStateCookie, oidcHelper.HashState(state), oidcCallbackPath(provider.Name), int(oidcStateCookieMaxAge/time.Second))
+
+	authorizationUrl := oidcHelper.BuildAuthorizationUrl(provider, discovery, state, nonce, oidcHelper.GeneratePkceCodeChallenge(codeVerifier))
+
+	c.Redirect(http.StatusFound, authorizationUrl)
+}
+
+// Callback only completes the login in the browser that started it, the state cookie is cleared whatever the outcome
+func (o *oidcControllerStruct) Callback(c *gin.Context) {
+	stateCookie, _ := c.Cookie(helpers.OidcStateCookie)
+	sessionCookieHelper.ClearFlowCookie(c, helpers.OidcStateCookie, oidcCallbackPath(c.Param("provider")))
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("oidc provider returned %s", providerError)})
+		return
+	}
+
+	state := c.Query("state")
+	code := c.Query("code")
+
+	if state == "" || code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state and code are required"})
+		return
+	}
+
+	if !oidcHelper.IsStateCookieValid(stateCookie, state) {
+		logger.Logger.Error("oidc state does not match the state cookie")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "oidc state is invalid or has expired"})
+		return
+	}
+
+	provider, ok := oidcHelper.GetProvider(c.Param("provider"))
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "oidc provider is not configured"})
+		return
+	}
+
+	session, err := oidcHelper.ConsumeAuthorizationSession(state)
+
+	if err != nil || session.Provider != provider.Name {
+		if err != nil {
+			logger.Logger.Error(err.Error())
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "oidc state is invalid or has expired"})
+		return
+	}
+
+	discovery, err := oidcHelper.Discover(provider)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadGateway, gin.H{"error": "error occured while contacting oidc provider"})
+		return
+	}
+
+	idToken, err := oidcHelper.ExchangeCode(provider, discovery, code, session.CodeVerifier)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "error occured while exchanging oidc code"})
+		return
+	}
+
+	identity, err := oidcHelper.VerifyIdToken(provider, discovery, idToken, session.Nonce)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "oidc id token is invalid"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	user, status, err := findOrCreateOidcUser(ctx, provider.Name, identity)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Set(helpers.IsCookieSessionKey, session.Is_cookie_mode)
+
+	respondLoginSuccess(ctx, c, user)
+}
+
+// oidcCallbackPath scopes the state cookie to the callback route of the provider
+func oidcCallbackPath(providerName string) string {
+	return "/api/auth/oidc/" + providerName + "/callback"
+}
+
+// findOrCreateOidcUser resolves the user by linked identity first, then links an existing account by verified email,
+// otherwise creates a new account. An unverified provider email never links to an existing account.
+func findOrCreateOidcUser(ctx context.Context, providerName string, identity *helpers.OidcIdentityClaims) (models.User, int, error) {
+	var user models.User
+
+	err := userCollection.FindOne(ctx, bson.M{
+		"oidc_identities": bson.M{"$elemMatch": bson.M{"provider": providerName, "subject": identity.Subject}},
+	}).Decode(&user)
+
+	if err == nil {
+		return user, http.StatusOK, nil
+	} else if err != mongo.ErrNoDocuments {
+		return user, http.StatusInternalServerError, err
+	}
+
+	if identity.Email == "" || !identity.Email_verified {
+		return user, http.StatusForbidden, fmt.Errorf("%s account has no verified email", providerName)
+	}
+
+	oidcIdentity := models.OidcIdentity{Provider: providerName, Subject: identity.Subject}
+
+	err = userCollection.FindOne(ctx, bson.M{"email": identity.Email}).Decode(&user)
+
+	if err == nil {
+		Updated_at, err := timeHelper.GetCurrentLocationTime()
+
+		if err != nil {
+			return user, http.StatusInternalServerError, err
+		}
+
+		// the provider vouches for the mailbox, so the address counts as verified from now on
+		_, err = userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": user.User_id},
+			bson.D{
+				{Key: "$addToSet", Value: bson.D{{Key: "oidc_identities", Value: oidcIdentity}}},
+				{Key: "$set", Value: bson.D{
+					{Key: "is_email_verified", Value: true},
+					{Key: "updated_at", Value: Updated_at},
+				}},
+			},
+		)
+
+		if err != nil {
+			return user, http.StatusInternalServerError, err
+		}
+
+		user.Is_email_verified = true
+		user.Oidc_identities = append(user.Oidc_identities, oidcIdentity)
+
+		return user, http.StatusOK, nil
+	} else if err != mongo.ErrNoDocuments {
+		return user, http.StatusInternalServerError, err
+	}
+
+	user = models.User{
+		First_name:        identity.Given_name,
+		Last_name:         identity.Family_name,
+		Email:             identity.Email,
+		User_role:         enums.User.String(),
+		Is_email_verified: true,
+		Oidc_identities:   []models.OidcIdentity{oidcIdentity},
+	}
+
+	if user.First_name == "" {
+		user.First_name = strings.TrimSpace(strings.Split(identity.Name+" ", " ")[0])
+	}
+
+	user.Created_at, err = timeHelper.GetCurrentLocationTime()
+
+	if err != nil {
+		return user, http.StatusInternalServerError, err
+	}
+
+	user.Updated_at = user.Created_at
+	user.ID = primitive.NewObjectID()
+	user.User_id = user.ID.Hex()
+
+	// no password is set, the account can only sign in through the provider until a password reset
+	_, err = userCollection.InsertOne(ctx, user)
+
+	if err != nil {
+		return user, http.StatusInternalServerError, err
+	}
+
+	return user, http.StatusOK, nil
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"nft-raffle/dto"
+	"nft-raffle/enums"
+	"nft-raffle/helpers"
+	"nft-raffle/logger"
+	"nft-raffle/models"
+	"nft-raffle/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	PhoneVerificationController IPhoneVerificationController = NewPhoneVerificationController()
+
+	phoneVerificationHelper helpers.IPhoneVerificationHelper = helpers.PhoneVerificationHelper
+
+	smsSender services.ISmsSender = services.SmsSender
+
+	phoneVerificationCodeExpirationMinutes string = dotEnvHelper.GetEnvVariable("PHONE_VERIFICATION_CODE_EXPIRATION_MINUTES")
+	phoneVerificationResendCooldownSeconds string = dotEnvHelper.GetEnvVariable("PHONE_VERIFICATION_RESEND_COOLDOWN_SECONDS")
+	phoneVerificationResendDailyCap        string = dotEnvHelper.GetEnvVariable("PHONE_VERIFICATION_RESEND_DAILY_CAP")
+)
+
+type IPhoneVerificationController interface {
+	SendVerificationCode(c *gin.Context)
+	VerifyPhone(c *gin.Context)
+}
+
+type phoneVerificationControllerStruct struct{}
+
+func NewPhoneVerificationController() IPhoneVerificationController {
+	return &phoneVerificationControllerStruct{}
+}
+
+// SendVerificationCode texts a 6 digit code to the phone number on the profile
+func (p *phoneVerificationControllerStruct) SendVerificationCode(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if user.Is_phone_verified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "phone is already verified"})
+		return
+	}
+
+	// numbers saved before normalization was introduced are normalized here
+	phone, err := dataValidationHelper.NormalizePhoneNumber(user.Phone)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cooldownSeconds, err := strconv.Atoi(phoneVerificationResendCooldownSeconds)
+
+	if err != nil || cooldownSeconds < 0 {
+		cooldownSeconds = 60
+	}
+
+	dailyCap, err := strconv.Atoi(phoneVerificationResendDailyCap)
+
+	if err != nil || dailyCap <= 0 {
+		dailyCap = 5
+	}
+
+	// limited per number as well, every text costs money
+	retryAfter, err := attemptLimitHelper.ReserveMailSend(enums.PhoneVerificationSms, phone, time.Second*time.Duration(cooldownSeconds), dailyCap)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if retryAfter > 0 {
+		c.Header("Retry-After", strconv.FormatInt(int64(retryAfter.Seconds())+1, 10))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "please wait before requesting another verification code"})
+		return
+	}
+
+	expirationMinutes, err := strconv.Atoi(phoneVerificationCodeExpirationMinutes)
+
+	if err != nil || expirationMinutes <= 0 {
+		expirationMinutes = 10
+	}
+
+	code := randomCodeGenerator.GenerateRandomDigits(6)
+
+	if err := phoneVerificationHelper.SaveCode(userId, phone, code, time.Minute*time.Duration(expirationMinutes)); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := attemptLimitHelper.ResetMailCodeAttempts(enums.PhoneVerificationSms, userId); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := fmt.Sprintf("Your verification code is %s. It expires in %d minutes.", code, expirationMinutes)
+
+	if err := smsSender.SendSms(phone, message); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadGateway, gin.H{"error": "error occured while sending the verification sms"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"phone": phone, "expires_in_minutes": expirationMinutes})
+}
+
+func (p *phoneVerificationControllerStruct) VerifyPhone(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	var request dto.VerifyPhoneRequestDto
+
+	if err := c.BindJSON(&request); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if validationErr := validate.Struct(request); validationErr != nil {
+		logger.Logger.Error(validationErr.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		return
+	}
+
+	phone, ok, err := phoneVerificationHelper.VerifyCode(userId, request.Code)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !ok {
+		respondFailedPhoneCode(c, userId)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err = userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if currentPhone, err := dataValidationHelper.NormalizePhoneNumber(user.Phone); err != nil || currentPhone != phone {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "phone number has changed, please request a new code"})
+		return
+	}
+
+	err = updateUserFields(ctx, userId, bson.D{
+		{Key: "phone", Value: phone},
+		{Key: "is_phone_verified", Value: true},
+	})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := attemptLimitHelper.ResetMailCodeAttempts(enums.PhoneVerificationSms, userId); err != nil {
+		logger.Logger.Error(err.Error())
+	}
+
+	user.Phone = phone
+	user.Is_phone_verified = true
+
+	c.JSON(http.StatusOK, dto.NewUserResponseDto(user, false))
+}
+
+// respondFailedPhoneCode mirrors respondFailedMailCode, the pending code is dropped once the attempt cap is reached
+func respondFailedPhoneCode(c *gin.Context, userId string) {
+	isCodeInvalidated, err := attemptLimitHelper.RecordFailedMailCodeAttempt(enums.PhoneVerificationSms, userId)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !isCodeInvalidated {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "verification code is invalid or has expired"})
+		return
+	}
+
+	if err := phoneVerificationHelper.DeleteCode(userId); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Logger.Warn(fmt.Sprintf("phone verification code of user %s has been invalidated after too many failed attempts", userId))
+	c.JSON(http.StatusBadRequest, gin.H{"error": "too many failed attempts, the code has been invalidated, please request a new one"})
+}
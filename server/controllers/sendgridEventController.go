@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"nft-raffle/dto"
+	"nft-raffle/enums"
+	"nft-raffle/helpers"
+	"nft-raffle/logger"
+	"nft-raffle/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// sendgrid batches events, a batch stays far below this
+	maxSendGridEventBatchBytes int64 = 5 << 20
+	// delivery events kept on a mail record, opens could otherwise grow it without bound
+	maxMailDeliveryEvents int = 20
+)
+
+var (
+	SendGridEventController ISendGridEventController = NewSendGridEventController()
+
+	sendGridEventWebhookHelper helpers.ISendGridEventWebhookHelper = helpers.SendGridEventWebhookHelper
+)
+
+type ISendGridEventController interface {
+	ReceiveEvents(c *gin.Context)
+}
+
+type sendGridEventControllerStruct struct{}
+
+func NewSendGridEventController() ISendGridEventController {
+	return &sendGridEventControllerStruct{}
+}
+
+// ReceiveEvents records the tracked events of a signed batch, any storage error answers 500 so sendgrid retries the
+// whole batch, events already recorded are recognised by sg_event_id
+func (s *sendGridEventControllerStruct) ReceiveEvents(c *gin.Context) {
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSendGridEventBatchBytes))
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "event batch is too large"})
+		return
+	}
+
+	err = sendGridEventWebhookHelper.VerifySignature(
+		payload,
+		c.GetHeader(helpers.SendGridEventSignatureHeader),
+		c.GetHeader(helpers.SendGridEventTimestampHeader),
+	)
+
+	if errors.Is(err, helpers.ErrInvalidEventWebhookSignature) || errors.Is(err, helpers.ErrEventWebhookTimestampExpired) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "event webhook is not available"})
+		return
+	}
+
+	var events []dto.SendGridEvent
+
+	if err := json.Unmarshal(payload, &events); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "event batch is not a json array of events"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	recorded := 0
+
+	for _, event := range events {
+		deliveryEvent := enums.MailDeliveryEvent(event.Event)
+
+		if !deliveryEvent.IsTracked() || event.Email == "" || event.Sg_event_id == "" {
+			continue
+		}
+
+		if err := recordMailDeliveryEvent(ctx, event, deliveryEvent); err != nil {
+			logger.Logger.Error(err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while recording mail events"})
+			return
+		}
+
+		recorded++
+	}
+
+	logger.Logger.Info(fmt.Sprintf("%d of %d mail events recorded", recorded, len(events)))
+	c.Status(http.StatusOK)
+}
+
+// recordMailDeliveryEvent records the event on the outbox mail named by the mail_outbox_id custom arg, mails queued
+// before the outbox only carry mail_type and fall back to the code mails of the address with that type
+func recordMailDeliveryEvent(ctx context.Context, event dto.SendGridEvent, deliveryEvent enums.MailDeliveryEvent) error {
+	set := bson.D{{Key: "updated_at", Value: time.Now()}}
+
+	// an open does not change whether the mail was delivered
+	if deliveryEvent != enums.MailOpen {
+		set = append(set, bson.E{Key: "delivery_status", Value: deliveryEvent.String()})
+	}
+
+	update := bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$push", Value: bson.M{"delivery_events": bson.M{
+			"$each": bson.A{models.MailDeliveryEvent{
+				Sg_event_id: event.Sg_event_id,
+				Event:       deliveryEvent.String(),
+				Reason:      event.Reason,
+				Occurred_at: time.Unix(event.Timestamp, 0),
+			}},
+			"$slice": -maxMailDeliveryEvents,
+		}}},
+	}
+
+	var err error
+
+	if event.Mail_outbox_id != "" {
+		_, err = mailOutboxCollection.UpdateOne(ctx, bson.D{
+			{Key: "mail_outbox_id", Value: event.Mail_outbox_id},
+			{Key: "delivery_events.sg_event_id", Value: bson.M{"$ne": event.Sg_event_id}},
+		}, update)
+	} else if event.Mail_type != "" {
+		_, err = mailCollection.UpdateMany(ctx, bson.D{
+			{Key: "email", Value: event.Email},
+			{Key: "type", Value: event.Mail_type},
+			{Key: "delivery_events.sg_event_id", Value: bson.M{"$ne": event.Sg_event_id}},
+		}, update)
+	}
+
+	// without either custom arg the mail cannot be told apart, only the suppression below applies
+	if err != nil {
+		return err
+	}
+
+	if isHardBounce(event, deliveryEvent) || deliveryEvent == enums.MailSpamReport {
+		return mailService.SuppressEmail(ctx, event.Email, deliveryEvent, event.Reason)
+	}
+
+	return nil
+}
+
+// isHardBounce leaves out blocked mails, sendgrid reports those as bounces too but they are temporary
+func isHardBounce(event dto.SendGridEvent, deliveryEvent enums.MailDeliveryEvent) bool {
+	return deliveryEvent == enums.MailBounce && !strings.EqualFold(event.Type, "blocked")
+}
@@ -2,17 +2,25 @@ package controllers
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"nft-raffle/dto"
 	"nft-raffle/enums"
+	"nft-raffle/helpers"
 	"nft-raffle/logger"
 	"nft-raffle/models"
+	"nft-raffle/services"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sendgrid/sendgrid-go/helpers/mail"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -24,14 +32,26 @@ var (
 	mailCollection *mongo.Collection = nftRaffleDb.OpenCollection(nftRaffleDbClient, "mail")
 
 	passwordResetMailCodeExpiration string = dotEnvHelper.GetEnvVariable("PASSWORD_RESET_MAIL_CODE_EXPIRATION")
-	passwordResetMailReturnHost     string = dotEnvHelper.GetEnvVariable("PASSWORD_RESET_MAIL_RETURN_HOST")
-	passwordResetMailReturnPort     string = dotEnvHelper.GetEnvVariable("PASSWORD_RESET_MAIL_RETURN_PORT")
+
+	verificationMailResendCooldownSeconds string = dotEnvHelper.GetEnvVariable("VERIFICATION_MAIL_RESEND_COOLDOWN_SECONDS")
+	verificationMailResendDailyCap        string = dotEnvHelper.GetEnvVariable("VERIFICATION_MAIL_RESEND_DAILY_CAP")
+
+	magicLinkMailCodeExpirationMinutes string = dotEnvHelper.GetEnvVariable("MAGIC_LINK_MAIL_CODE_EXPIRATION_MINUTES")
+	magicLinkMailResendCooldownSeconds string = dotEnvHelper.GetEnvVariable("MAGIC_LINK_MAIL_RESEND_COOLDOWN_SECONDS")
+	magicLinkMailResendDailyCap        string = dotEnvHelper.GetEnvVariable("MAGIC_LINK_MAIL_RESEND_DAILY_CAP")
 )
 
+// the magic link only works in the browser holding this cookie, a forwarded link is useless on its own
+const magicLinkNonceCookie string = "magic_link_nonce"
+
 type ISendGridController interface {
 	VerifyVerificationMail(c *gin.Context)
+	ResendVerificationMail(c *gin.Context)
 	SendPasswordResetMail(c *gin.Context)
 	VerifyPasswordResetMail(c *gin.Context)
+	VerifyEmailChangeMail(c *gin.Context)
+	SendMagicLinkMail(c *gin.Context)
+	VerifyMagicLinkMail(c *gin.Context)
 }
 
 type sendGridControllerStruct struct{}
@@ -41,62 +61,20 @@ func NewSendGridController() ISendGridController {
 }
 
 func (s *sendGridControllerStruct) VerifyVerificationMail(c *gin.Context) {
-	encryptedEmail := c.Query("email")
-
-	if encryptedEmail == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email query param is not existing in the URL"})
-		return
-	}
-
-	encryptedCode := c.Query("code")
+	claims, ok := parseActionLink(c, enums.MailVerification)
 
-	if encryptedCode == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "code query param is not existing in the URL"})
-		return
-	}
-
-	email, err := aesEncryptionHelper.AesGCMDecrypt(encryptedEmail)
-
-	if err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if !ok {
 		return
 	}
 
-	code, err := aesEncryptionHelper.AesGCMDecrypt(encryptedCode)
-
-	if err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+	email, code := claims.Subject, claims.Code
 
-	var verificationMail models.Mail
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	err = mailCollection.FindOne(ctx, bson.D{
-		{Key: "email", Value: email},
-		{Key: "type", Value: enums.MailVerification.String()},
-	}).Decode(&verificationMail)
-
-	if err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "email is not valid"})
-		return
-	}
-
-	// check for the code
-	if verificationMail.Code != code {
-		logger.Logger.Error("verification code does not match")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "verification code does not match"})
-		return
-	}
+	verificationMail, ok := verifyMailCode(ctx, c, enums.MailVerification, email, code, "verification code does not match", "verification mail has expired")
 
-	// check for expires_at
-	if verificationMail.Expires_at.Unix() < time.Now().Local().Unix() {
-		logger.Logger.Error("verification mail has expired")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "verification mail has expired"})
+	if !ok {
 		return
 	}
 
@@ -141,11 +119,8 @@ func (s *sendGridControllerStruct) VerifyVerificationMail(c *gin.Context) {
 			return err
 		}
 
-		// now remove verifcation mail from mailCollection
-		_, err = mailCollection.DeleteOne(sessionContext, bson.D{
-			{Key: "email", Value: email},
-			{Key: "type", Value: enums.MailVerification.String()},
-		})
+		// the code is kept until it expires, but cannot be used again
+		err = mailService.ConsumeMailCode(sessionContext, verificationMail)
 
 		if err != nil {
 			sessionContext.AbortTransaction(sessionContext)
@@ -159,13 +134,21 @@ func (s *sendGridControllerStruct) VerifyVerificationMail(c *gin.Context) {
 		return nil
 	})
 
+	if errors.Is(err, services.ErrMailCodeConsumed) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err != nil {
 		logger.Logger.Error(err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// generate token and return user
+	if err := attemptLimitHelper.ResetMailCodeAttempts(enums.MailVerification, email); err != nil {
+		logger.Logger.Error(err.Error())
+	}
+
 	var user models.User
 
 	err = userCollection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
@@ -176,16 +159,41 @@ func (s *sendGridControllerStruct) VerifyVerificationMail(c *gin.Context) {
 		return
 	}
 
-	signedToken, signedRefreshToken, err := tokenHelper.GenerateAllTokens(
-		user.Email, user.First_name, user.Last_name, user.User_id, user.User_role, user.Is_email_verified)
+	// the link signs the user in, through the mfa step when it is enabled or required
+	respondLoginSuccess(ctx, c, user)
+}
 
-	if err != nil {
+// ResendVerificationMail answers the same way whether or not the email belongs to an unverified user,
+// so it cannot be used to find out which emails are registered.
+func (s *sendGridControllerStruct) ResendVerificationMail(c *gin.Context) {
+	var mailDto dto.ResendVerificationMailRequestDto
+
+	if err := c.BindJSON(&mailDto); err != nil {
 		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	err = tokenHelper.UpdateAllTokens(signedToken, signedRefreshToken, user.User_id)
+	if emailValidationErr := dataValidationHelper.IsEmailValid(mailDto.Email); emailValidationErr != nil {
+		logger.Logger.Error(emailValidationErr.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": emailValidationErr.Error()})
+		return
+	}
+
+	// limits are keyed by the submitted email, before knowing whether it exists
+	cooldownSeconds, err := strconv.Atoi(verificationMailResendCooldownSeconds)
+
+	if err != nil {
+		cooldownSeconds = 60
+	}
+
+	dailyCap, err := strconv.Atoi(verificationMailResendDailyCap)
+
+	if err != nil {
+		dailyCap = 5
+	}
+
+	retryAfter, err := attemptLimitHelper.ReserveMailSend(enums.MailVerification, mailDto.Email, time.Second*time.Duration(cooldownSeconds), dailyCap)
 
 	if err != nil {
 		logger.Logger.Error(err.Error())
@@ -193,15 +201,40 @@ func (s *sendGridControllerStruct) VerifyVerificationMail(c *gin.Context) {
 		return
 	}
 
-	err = userCollection.FindOne(ctx, bson.M{"user_id": user.User_id}).Decode(&user)
+	if retryAfter > 0 {
+		c.Header("Retry-After", strconv.FormatInt(int64(retryAfter.Seconds())+1, 10))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "please wait before requesting another verification mail"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err = userCollection.FindOne(ctx, bson.M{"email": mailDto.Email}).Decode(&user)
 
 	if err != nil {
-		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err != mongo.ErrNoDocuments {
+			logger.Logger.Error(err.Error())
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "if the email belongs to an unverified account, a verification mail has been sent"})
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	if !user.Is_email_verified {
+		err := runInTransaction(ctx, func(sessionContext mongo.SessionContext) error {
+			return sendVerificationMail(sessionContext, user)
+		})
+
+		if err != nil {
+			// logged only, the response must not differ
+			logger.Logger.Error(err.Error())
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "if the email belongs to an unverified account, a verification mail has been sent"})
 }
 
 func (s *sendGridControllerStruct) SendPasswordResetMail(c *gin.Context) {
@@ -249,104 +282,230 @@ func (s *sendGridControllerStruct) SendPasswordResetMail(c *gin.Context) {
 	}
 
 	// send email
-	tos := []*mail.Email{
-		// hardcoded for testing
-		mail.NewEmail("yyhyap98", "yyhyap98@gmail.com"),
-	}
+	tos := []dto.MailAddress{userMailAddress(user)}
 
 	dynamicTemplateData := map[string]string{}
 	dynamicTemplateData["Full_Name"] = fmt.Sprintf("%s %s", user.First_name, user.Last_name)
 
-	encryptedEmailValue, err := aesEncryptionHelper.AesGCMEncrypt(user.Email)
+	dynamicTemplateData["Password_Reset_Mail_Link"], err = actionLinkHelper.GenerateLink(enums.PasswordReset, user.Email, randomSixDigits, expires_at, nil)
 
 	if err != nil {
 		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while encrypting user email"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the password reset link"})
 		return
 	}
 
-	encryptedRandomSixDigits, err := aesEncryptionHelper.AesGCMEncrypt(randomSixDigits)
+	mailReq := &dto.MailRequest{
+		FromName:            fromName,
+		FromEmail:           fromEmail,
+		MailType:            enums.PasswordReset,
+		Locale:              user.Locale,
+		UserId:              user.User_id,
+		Tos:                 tos,
+		DynamicTemplateData: dynamicTemplateData,
+	}
+
+	err = runInTransaction(ctx, func(sessionContext mongo.SessionContext) error {
+		if _, err := mailService.IssueMailCode(sessionContext, enums.PasswordReset, user.User_id, user.Email, randomSixDigits, expires_at); err != nil {
+			return fmt.Errorf("error occured while inserting new password reset email into db: %w", err)
+		}
+
+		return mailService.EnqueueMail(sessionContext, mailReq)
+	})
 
 	if err != nil {
 		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while encrypting random six digits"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	dynamicTemplateData["Password_Reset_Mail_Link"] = fmt.Sprintf(
-		"%s:%s/api/send-grid/test?email=%s&code=%s",
-		passwordResetMailReturnHost, passwordResetMailReturnPort, encryptedEmailValue, encryptedRandomSixDigits,
-	)
+	// a new code gets a fresh attempt budget
+	if err := attemptLimitHelper.ResetMailCodeAttempts(enums.PasswordReset, user.Email); err != nil {
+		logger.Logger.Error(err.Error())
+	}
 
-	mailReq := &dto.MailRequest{
-		FromName:            fromName,
-		FromEmail:           fromEmail,
-		MailType:            enums.PasswordReset,
-		Tos:                 tos,
-		DynamicTemplateData: dynamicTemplateData,
+	c.Status(http.StatusOK)
+}
+
+func (s *sendGridControllerStruct) VerifyPasswordResetMail(c *gin.Context) {
+	claims, ok := parseActionLink(c, enums.PasswordReset)
+
+	if !ok {
+		return
 	}
 
-	go sendGridMailService.SendMail(mailReq)
+	email, code := claims.Subject, claims.Code
 
-	mailCount, err := mailCollection.CountDocuments(
-		ctx,
-		bson.D{
-			{Key: "email", Value: user.Email},
-			{Key: "type", Value: enums.PasswordReset.String()},
-		},
-	)
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	passwordResetMail, ok := verifyMailCode(ctx, c, enums.PasswordReset, email, code, "verification code does not match", "verification mail has expired")
+
+	if !ok {
+		return
+	}
+
+	// password reset mail verified, the code is used up by ResetUserPassword
+	c.JSON(http.StatusOK, gin.H{
+		"email": passwordResetMail.Email,
+		"code":  code,
+	})
+}
+
+func (s *sendGridControllerStruct) VerifyEmailChangeMail(c *gin.Context) {
+	claims, ok := parseActionLink(c, enums.EmailChange)
+
+	if !ok {
+		return
+	}
+
+	email, code := claims.Subject, claims.Code
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	emailChangeMail, ok := verifyMailCode(ctx, c, enums.EmailChange, email, code, "verification code does not match", "email change mail has expired")
+
+	if !ok {
+		return
+	}
+
+	// the address may have been taken since the change was requested
+	emailCount, err := userCollection.CountDocuments(ctx, bson.M{"email": email})
 
 	if err != nil {
 		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while counting mail from mail collection in db"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	if mailCount > 0 {
-		// update current password reset mail
-		// update mail in db
-		mailUpdateError := sendGridMailService.UpdateEmail(enums.PasswordReset, user.Email, randomSixDigits, expires_at)
+	if emailCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user email already exists"})
+		return
+	}
 
-		if mailUpdateError != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":         "error occured while updating verification email in db",
-				"error_details": mailUpdateError.Error(),
-			})
-			return
+	var updateObj bson.D
+
+	updateObj = append(updateObj, bson.E{Key: "email", Value: email})
+	updateObj = append(updateObj, bson.E{Key: "is_email_verified", Value: true})
+
+	Updated_at, err := timeHelper.GetCurrentLocationTime()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while parsing updated_at"})
+		return
+	}
+
+	updateObj = append(updateObj, bson.E{Key: "updated_at", Value: Updated_at})
+
+	upsert := false
+	filter := bson.M{"user_id": emailChangeMail.User_id}
+	opt := options.UpdateOptions{
+		Upsert: &upsert,
+	}
+
+	err = nftRaffleDbClient.UseSession(ctx, func(sessionContext mongo.SessionContext) error {
+		err := sessionContext.StartTransaction()
+		if err != nil {
+			return err
 		}
-	} else {
-		// create new password reset mail
-		// insert mail into db
-		mailInsertError := sendGridMailService.CreateNewMail(enums.PasswordReset, user.Email, randomSixDigits, expires_at)
-
-		if mailInsertError != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":         "error occured while inserting new verification email into db",
-				"error_details": mailInsertError.Error(),
-			})
-			return
+
+		_, err = userCollection.UpdateOne(
+			sessionContext,
+			filter,
+			bson.D{
+				{Key: "$set", Value: updateObj},
+			},
+			&opt,
+		)
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		// the code is kept until it expires, but cannot be used again
+		err = mailService.ConsumeMailCode(sessionContext, emailChangeMail)
+
+		if err != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return err
+		}
+
+		if err := sessionContext.CommitTransaction(sessionContext); err != nil {
+			return err
 		}
+
+		return nil
+	})
+
+	if errors.Is(err, services.ErrMailCodeConsumed) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.Status(http.StatusOK)
-}
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-func (s *sendGridControllerStruct) VerifyPasswordResetMail(c *gin.Context) {
-	encryptedEmail := c.Query("email")
+	// sessions still carry the old email in their claims, revoked once the change is committed
+	if err := tokenHelper.SetBlacklistAccessAndRefreshTokenUserId(emailChangeMail.User_id); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	if encryptedEmail == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email query param is not existing in the URL"})
+	if err := attemptLimitHelper.ResetMailCodeAttempts(enums.EmailChange, email); err != nil {
+		logger.Logger.Error(err.Error())
+	}
+
+	var user models.User
+
+	err = userCollection.FindOne(ctx, bson.M{"user_id": emailChangeMail.User_id}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	encryptedCode := c.Query("code")
+	// the revoked sessions are replaced through the mfa step when it is enabled or required
+	respondLoginSuccess(ctx, c, user)
+}
+
+// SendMagicLinkMail binds the link to the requesting browser through a nonce cookie and answers
+// the same way whether or not the email is registered.
+func (s *sendGridControllerStruct) SendMagicLinkMail(c *gin.Context) {
+	var mailDto dto.SendMagicLinkMailRequestDto
 
-	if encryptedCode == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "code query param is not existing in the URL"})
+	if err := c.BindJSON(&mailDto); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	email, err := aesEncryptionHelper.AesGCMDecrypt(encryptedEmail)
+	if emailValidationErr := dataValidationHelper.IsEmailValid(mailDto.Email); emailValidationErr != nil {
+		logger.Logger.Error(emailValidationErr.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": emailValidationErr.Error()})
+		return
+	}
+
+	cooldownSeconds, err := strconv.Atoi(magicLinkMailResendCooldownSeconds)
+
+	if err != nil {
+		cooldownSeconds = 60
+	}
+
+	dailyCap, err := strconv.Atoi(magicLinkMailResendDailyCap)
+
+	if err != nil {
+		dailyCap = 10
+	}
+
+	retryAfter, err := attemptLimitHelper.ReserveMailSend(enums.MagicLinkLogin, mailDto.Email, time.Second*time.Duration(cooldownSeconds), dailyCap)
 
 	if err != nil {
 		logger.Logger.Error(err.Error())
@@ -354,7 +513,19 @@ func (s *sendGridControllerStruct) VerifyPasswordResetMail(c *gin.Context) {
 		return
 	}
 
-	code, err := aesEncryptionHelper.AesGCMDecrypt(encryptedCode)
+	if retryAfter > 0 {
+		c.Header("Retry-After", strconv.FormatInt(int64(retryAfter.Seconds())+1, 10))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "please wait before requesting another login link"})
+		return
+	}
+
+	expirationMinutes, err := strconv.Atoi(magicLinkMailCodeExpirationMinutes)
+
+	if err != nil || expirationMinutes <= 0 {
+		expirationMinutes = 15
+	}
+
+	nonce, err := generateRandomUrlToken()
 
 	if err != nil {
 		logger.Logger.Error(err.Error())
@@ -362,38 +533,326 @@ func (s *sendGridControllerStruct) VerifyPasswordResetMail(c *gin.Context) {
 		return
 	}
 
-	var passwordResetMail models.Mail
+	// the cookie is set for unknown emails too, so the response does not reveal registered accounts
+	sessionCookieHelper.SetFlowCookie(c, magicLinkNonceCookie, nonce, "/", expirationMinutes*60)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	err = mailCollection.FindOne(ctx, bson.D{
-		{Key: "email", Value: email},
-		{Key: "type", Value: enums.PasswordReset.String()},
-	}).Decode(&passwordResetMail)
+	var user models.User
+
+	err = userCollection.FindOne(ctx, bson.M{"email": mailDto.Email}).Decode(&user)
 
 	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			logger.Logger.Error(err.Error())
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "if the email belongs to an account, a login link has been sent"})
+		return
+	}
+
+	err = runInTransaction(ctx, func(sessionContext mongo.SessionContext) error {
+		return sendMagicLinkMail(sessionContext, user, nonce, time.Minute*time.Duration(expirationMinutes), sessionCookieHelper.IsCookieMode(c))
+	})
+
+	if err != nil {
+		// logged only, the response must not differ
 		logger.Logger.Error(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "email is not valid"})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "if the email belongs to an account, a login link has been sent"})
+}
+
+func (s *sendGridControllerStruct) VerifyMagicLinkMail(c *gin.Context) {
+	claims, ok := parseActionLink(c, enums.MagicLinkLogin)
+
+	if !ok {
 		return
 	}
 
-	// check for the code
-	if passwordResetMail.Code != code {
-		logger.Logger.Error("verification code does not match")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "verification code does not match"})
+	email, code := claims.Subject, claims.Code
+
+	nonce, err := c.Cookie(magicLinkNonceCookie)
+
+	if err != nil || nonce == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "login link must be opened in the browser it was requested from"})
 		return
 	}
 
-	// check for expires_at
-	if passwordResetMail.Expires_at.Unix() < time.Now().Local().Unix() {
-		logger.Logger.Error("verification mail has expired")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "verification mail has expired"})
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	magicLinkMail, ok := verifyMailCode(ctx, c, enums.MagicLinkLogin, email, code, "login link is not valid", "login link has expired")
+
+	if !ok {
 		return
 	}
 
-	// password reset mail verified
-	c.JSON(http.StatusOK, gin.H{
-		"email": passwordResetMail.Email,
-		"code":  passwordResetMail.Code,
+	if subtle.ConstantTimeCompare([]byte(magicLinkMail.Nonce_hash), []byte(hashMagicLinkNonce(nonce))) != 1 {
+		logger.Logger.Warn(fmt.Sprintf("magic link for %s was opened in a different browser", email))
+		c.JSON(http.StatusForbidden, gin.H{"error": "login link must be opened in the browser it was requested from"})
+		return
+	}
+
+	// consuming by id makes the link single-use even when it is opened twice at the same time
+	err = mailService.ConsumeMailCode(ctx, magicLinkMail)
+
+	if errors.Is(err, services.ErrMailCodeConsumed) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "login link has already been used"})
+		return
+	}
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionCookieHelper.ClearFlowCookie(c, magicLinkNonceCookie, "/")
+
+	if err := attemptLimitHelper.ResetMailCodeAttempts(enums.MagicLinkLogin, email); err != nil {
+		logger.Logger.Error(err.Error())
+	}
+
+	var user models.User
+
+	err = userCollection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		return
+	}
+
+	// following the link proves ownership of the mailbox
+	if !user.Is_email_verified {
+		if err := updateUserFields(ctx, user.User_id, bson.D{{Key: "is_email_verified", Value: true}}); err != nil {
+			logger.Logger.Error(err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		user.Is_email_verified = true
+	}
+
+	respondLoginSuccess(ctx, c, user)
+}
+
+// sendVerificationMail stores a new verification code and queues the mail,
+// ctx should be the session context of a transaction so both are committed together
+func sendVerificationMail(ctx context.Context, user models.User) error {
+	randomSixDigits := randomCodeGenerator.GenerateRandomDigits(6)
+	verifcationCodeExpirationInt, err := strconv.ParseInt(verifcationCodeExpiration, 10, 64)
+
+	if err != nil {
+		return err
+	}
+
+	expires_at, err := timeHelper.GetCurrentLocationTimeWithAdditionalDuration(time.Hour * time.Duration(verifcationCodeExpirationInt))
+
+	if err != nil {
+		return fmt.Errorf("error occured while parsing mail expires_at: %w", err)
+	}
+
+	if _, err := mailService.IssueMailCode(ctx, enums.MailVerification, user.User_id, user.Email, randomSixDigits, expires_at); err != nil {
+		return fmt.Errorf("error occured while inserting new verification email into db: %w", err)
+	}
+
+	// a new code gets a fresh attempt budget
+	if err := attemptLimitHelper.ResetMailCodeAttempts(enums.MailVerification, user.Email); err != nil {
+		logger.Logger.Error(err.Error())
+	}
+
+	// send email
+	tos := []dto.MailAddress{userMailAddress(user)}
+
+	dynamicTemplateData := map[string]string{}
+	dynamicTemplateData["Full_Name"] = fmt.Sprintf("%s %s", user.First_name, user.Last_name)
+	dynamicTemplateData["Verify_Mail_Link"], err = actionLinkHelper.GenerateLink(enums.MailVerification, user.Email, randomSixDigits, expires_at, nil)
+
+	if err != nil {
+		return fmt.Errorf("error occured while generating the verification link: %w", err)
+	}
+
+	mailReq := &dto.MailRequest{
+		FromName:            fromName,
+		FromEmail:           fromEmail,
+		MailType:            enums.MailVerification,
+		Locale:              user.Locale,
+		UserId:              user.User_id,
+		Tos:                 tos,
+		DynamicTemplateData: dynamicTemplateData,
+	}
+
+	return mailService.EnqueueMail(ctx, mailReq)
+}
+
+// parseActionLink reads the signed token of an emailed link and answers the request itself when it is not valid
+func parseActionLink(c *gin.Context, purpose enums.MailType) (*helpers.ActionLinkClaims, bool) {
+	token := c.Query(helpers.ActionLinkTokenQuery)
+
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token query param is not existing in the URL"})
+		return nil, false
+	}
+
+	claims, err := actionLinkHelper.ParseToken(token, purpose)
+
+	if err == nil {
+		return claims, true
+	}
+
+	logger.Logger.Error(fmt.Sprintf("%s link rejected: %v", purpose.String(), err.Error()))
+
+	if errors.Is(err, helpers.ErrInvalidActionLink) || errors.Is(err, helpers.ErrActionLinkExpired) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	} else {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+
+	return nil, false
+}
+
+// verifyMailCode checks the code of the latest mail of the type and answers the request itself when it is not accepted
+func verifyMailCode(ctx context.Context, c *gin.Context, mailType enums.MailType, email string, code string, invalidMessage string, expiredMessage string) (*models.Mail, bool) {
+	mail, err := mailService.VerifyMailCode(ctx, mailType, email, code)
+
+	if err == nil {
+		return mail, true
+	}
+
+	logger.Logger.Error(fmt.Sprintf("%s mail code rejected: %v", mailType.String(), err.Error()))
+
+	switch {
+	case errors.Is(err, services.ErrMailCodeMismatch):
+		respondFailedMailCode(ctx, c, mailType, email, invalidMessage)
+	case errors.Is(err, services.ErrMailCodeNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidMessage})
+	case errors.Is(err, services.ErrMailCodeExpired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": expiredMessage})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+
+	return nil, false
+}
+
+// respondFailedMailCode counts the wrong code and invalidates the mail once the attempt cap is reached
+func respondFailedMailCode(ctx context.Context, c *gin.Context, mailType enums.MailType, email string, errorMessage string) {
+	isCodeInvalidated, err := attemptLimitHelper.RecordFailedMailCodeAttempt(mailType, email)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !isCodeInvalidated {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errorMessage})
+		return
+	}
+
+	_, err = mailCollection.DeleteMany(ctx, bson.D{
+		{Key: "email", Value: email},
+		{Key: "type", Value: mailType.String()},
 	})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Logger.Warn(fmt.Sprintf("%s mail code for %s has been invalidated after too many failed attempts", mailType.String(), email))
+	c.JSON(http.StatusBadRequest, gin.H{"error": "too many failed attempts, the code has been invalidated, please request a new one"})
+}
+
+// sendMagicLinkMail stores a single-use login code bound to the hash of the browser nonce and queues the link,
+// like sendVerificationMail it is meant to run inside a transaction
+func sendMagicLinkMail(ctx context.Context, user models.User, nonce string, expiration time.Duration, isCookieMode bool) error {
+	code, err := generateRandomUrlToken()
+
+	if err != nil {
+		return err
+	}
+
+	expires_at, err := timeHelper.GetCurrentLocationTimeWithAdditionalDuration(expiration)
+
+	if err != nil {
+		return fmt.Errorf("error occured while parsing mail expires_at: %w", err)
+	}
+
+	magicLinkMail, err := mailService.IssueMailCode(ctx, enums.MagicLinkLogin, user.User_id, user.Email, code, expires_at)
+
+	if err != nil {
+		return fmt.Errorf("error occured while inserting magic link mail into db: %w", err)
+	}
+
+	_, err = mailCollection.UpdateOne(
+		ctx,
+		bson.M{"mail_id": magicLinkMail.Mail_id},
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "nonce_hash", Value: hashMagicLinkNonce(nonce)}}},
+		},
+	)
+
+	if err != nil {
+		return fmt.Errorf("error occured while binding magic link mail to the browser: %w", err)
+	}
+
+	// a new code gets a fresh attempt budget
+	if err := attemptLimitHelper.ResetMailCodeAttempts(enums.MagicLinkLogin, user.Email); err != nil {
+		logger.Logger.Error(err.Error())
+	}
+
+	// send email
+	tos := []dto.MailAddress{userMailAddress(user)}
+
+	linkQuery := url.Values{}
+
+	if isCookieMode {
+		linkQuery.Set(helpers.SessionModeQuery, helpers.CookieSessionMode)
+	}
+
+	dynamicTemplateData := map[string]string{}
+	dynamicTemplateData["Full_Name"] = fmt.Sprintf("%s %s", user.First_name, user.Last_name)
+	dynamicTemplateData["Expiration_Minutes"] = strconv.Itoa(int(expiration.Minutes()))
+	dynamicTemplateData["Magic_Link"], err = actionLinkHelper.GenerateLink(enums.MagicLinkLogin, user.Email, code, expires_at, linkQuery)
+
+	if err != nil {
+		return fmt.Errorf("error occured while generating the magic link: %w", err)
+	}
+
+	mailReq := &dto.MailRequest{
+		FromName:            fromName,
+		FromEmail:           fromEmail,
+		MailType:            enums.MagicLinkLogin,
+		Locale:              user.Locale,
+		UserId:              user.User_id,
+		Tos:                 tos,
+		DynamicTemplateData: dynamicTemplateData,
+	}
+
+	return mailService.EnqueueMail(ctx, mailReq)
+}
+
+func hashMagicLinkNonce(nonce string) string {
+	sum := sha256.Sum256([]byte(nonce))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateRandomUrlToken() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// userMailAddress is the real address of the user, the recipient policy of the mail service may still redirect it
+func userMailAddress(user models.User) dto.MailAddress {
+	return dto.MailAddress{Name: fmt.Sprintf("%s %s", user.First_name, user.Last_name), Address: user.Email}
 }
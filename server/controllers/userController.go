@@ -0,0 +1,495 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"nft-raffle/dto"
+	"nft-raffle/logger"
+	"nft-raffle/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	UserController IUserController = NewUserController()
+
+	accountDeletionGraceDays string = dotEnvHelper.GetEnvVariable("ACCOUNT_DELETION_GRACE_DAYS")
+
+	expenseDigestCollection *mongo.Collection = nftRaffleDb.OpenCollection(nftRaffleDbClient, "expenseDigest")
+)
+
+type IUserController interface {
+	GetMe(c *gin.Context)
+	UpdateMe(c *gin.Context)
+	ExportMe(c *gin.Context)
+	DeleteMe(c *gin.Context)
+	CancelDeletion(c *gin.Context)
+}
+
+type userControllerStruct struct{}
+
+func NewUserController() IUserController {
+	return &userControllerStruct{}
+}
+
+func (u *userControllerStruct) GetMe(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewUserResponseDto(user, false))
+}
+
+func (u *userControllerStruct) UpdateMe(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	var request dto.UpdateUserProfileRequestDto
+
+	if err := c.BindJSON(&request); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if validationErr := validate.Struct(request); validationErr != nil {
+		logger.Logger.Error(validationErr.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		return
+	}
+
+	if request.Phone != nil {
+		normalizedPhone, err := dataValidationHelper.NormalizePhoneNumber(*request.Phone)
+
+		if err != nil {
+			logger.Logger.Error(err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		request.Phone = &normalizedPhone
+	}
+
+	var updateObj bson.D
+
+	fields := []struct {
+		key   string
+		value *string
+	}{
+		{"first_name", request.First_name},
+		{"last_name", request.Last_name},
+		{"phone", request.Phone},
+		{"avatar_url", request.Avatar_url},
+		{"locale", request.Locale},
+		{"timezone", request.Timezone},
+	}
+
+	for _, field := range fields {
+		if field.value != nil {
+			updateObj = append(updateObj, bson.E{Key: field.key, Value: strings.TrimSpace(*field.value)})
+		}
+	}
+
+	if len(updateObj) < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no profile field to update"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	// a new number has to be verified again
+	if request.Phone != nil && *request.Phone != user.Phone {
+		updateObj = append(updateObj, bson.E{Key: "is_phone_verified", Value: false})
+
+		if err := phoneVerificationHelper.DeleteCode(userId); err != nil {
+			logger.Logger.Error(err.Error())
+		}
+	}
+
+	if err := updateUserFields(ctx, userId, updateObj); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewUserResponseDto(user, false))
+}
+
+// ExportMe returns everything stored about the user as a zip of json files, or as one json document with ?format=json
+func (u *userControllerStruct) ExportMe(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	export := dto.UserDataExportDto{
+		Profile:          dto.NewUserResponseDto(user, false),
+		Oidc_providers:   []string{},
+		Mail_preferences: mailPreferenceHelper.GetPreferences(user),
+		Api_keys:         []dto.ApiKeyExportDto{},
+		Expenses:         []models.Expense{},
+		Mails:            []dto.MailExportDto{},
+		Outbox_mails:     []dto.OutboxMailExportDto{},
+		Expense_digests:  []dto.ExpenseDigestExportDto{},
+		Admin_actions:    []dto.AdminActionExportDto{},
+	}
+
+	export.Exported_at, err = timeHelper.GetCurrentLocationTime()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, identity := range user.Oidc_identities {
+		export.Oidc_providers = append(export.Oidc_providers, identity.Provider)
+	}
+
+	var apiKeys []models.ApiKey
+
+	apiKeyCursor, err := apiKeyCollection.Find(ctx, bson.M{"user_id": userId})
+
+	if err == nil {
+		err = apiKeyCursor.All(ctx, &apiKeys)
+	}
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, apiKey := range apiKeys {
+		export.Api_keys = append(export.Api_keys, dto.ApiKeyExportDto{
+			Name:         apiKey.Name,
+			Prefix:       apiKey.Prefix,
+			Scopes:       apiKey.Scopes,
+			Expires_at:   apiKey.Expires_at,
+			Last_used_at: apiKey.Last_used_at,
+			Created_at:   apiKey.Created_at,
+		})
+	}
+
+	expenseCursor, err := expenseCollection.Find(ctx, bson.M{"user_id": userId})
+
+	if err == nil {
+		err = expenseCursor.All(ctx, &export.Expenses)
+	}
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var mails []models.Mail
+
+	mailCursor, err := mailCollection.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"user_id": userId},
+		bson.M{"email": user.Email},
+	}})
+
+	if err == nil {
+		err = mailCursor.All(ctx, &mails)
+	}
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, mail := range mails {
+		export.Mails = append(export.Mails, dto.MailExportDto{
+			Type:       mail.Type,
+			Email:      mail.Email,
+			Created_at: mail.Created_at,
+			Expires_at: mail.Expires_at,
+		})
+	}
+
+	var outboxMails []models.MailOutbox
+
+	outboxMailCursor, err := mailOutboxCollection.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"user_id": userId},
+		bson.M{"tos.address": user.Email},
+	}})
+
+	if err == nil {
+		err = outboxMailCursor.All(ctx, &outboxMails)
+	}
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, outboxMail := range outboxMails {
+		export.Outbox_mails = append(export.Outbox_mails, dto.OutboxMailExportDto{
+			Mail_type:       outboxMail.Mail_type,
+			Tos:             outboxMail.Tos,
+			Subject:         outboxMail.Subject,
+			Status:          outboxMail.Status,
+			Delivery_status: outboxMail.Delivery_status,
+			Created_at:      outboxMail.Created_at,
+			Sent_at:         outboxMail.Sent_at,
+		})
+	}
+
+	var expenseDigests []models.ExpenseDigest
+
+	expenseDigestCursor, err := expenseDigestCollection.Find(ctx, bson.M{"user_id": userId})
+
+	if err == nil {
+		err = expenseDigestCursor.All(ctx, &expenseDigests)
+	}
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, expenseDigest := range expenseDigests {
+		export.Expense_digests = append(export.Expense_digests, dto.ExpenseDigestExportDto{
+			Week_start: expenseDigest.Week_start,
+			Is_sent:    expenseDigest.Is_sent,
+			Created_at: expenseDigest.Created_at,
+		})
+	}
+
+	var actionLogs []models.AdminActionLog
+
+	actionLogCursor, err := adminActionLogCollection.Find(ctx, bson.M{"target_user_id": userId})
+
+	if err == nil {
+		err = actionLogCursor.All(ctx, &actionLogs)
+	}
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, actionLog := range actionLogs {
+		export.Admin_actions = append(export.Admin_actions, dto.AdminActionExportDto{
+			Action:     actionLog.Action,
+			Created_at: actionLog.Created_at,
+		})
+	}
+
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	archive, err := buildUserDataExportZip(export)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"user-data-%s.zip\"", userId))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// DeleteMe schedules the deletion after a grace period, logging in again and cancelling is possible until then
+func (u *userControllerStruct) DeleteMe(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	var request dto.DeleteAccountRequestDto
+
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if user.Password != "" {
+		if err := passwordHelper.VerifyPassword(user.Password, request.CurrentPassword); err != nil {
+			logger.Logger.Error(err.Error())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "current password is incorrect"})
+			return
+		}
+	}
+
+	if user.Deletion_scheduled_at != nil {
+		c.JSON(http.StatusAccepted, gin.H{"deletion_scheduled_at": user.Deletion_scheduled_at})
+		return
+	}
+
+	graceDays, err := strconv.Atoi(accountDeletionGraceDays)
+
+	if err != nil || graceDays < 0 {
+		graceDays = 30
+	}
+
+	deletionScheduledAt, err := timeHelper.GetCurrentLocationTimeWithAdditionalDuration(24 * time.Hour * time.Duration(graceDays))
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while parsing deletion_scheduled_at"})
+		return
+	}
+
+	if err := updateUserFields(ctx, userId, bson.D{{Key: "deletion_scheduled_at", Value: deletionScheduledAt}}); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tokenHelper.SetBlacklistAccessAndRefreshTokenUserId(userId); err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Logger.Info(fmt.Sprintf("deletion of account %s is scheduled at %v", userId, deletionScheduledAt))
+	c.JSON(http.StatusAccepted, gin.H{"deletion_scheduled_at": deletionScheduledAt})
+}
+
+func (u *userControllerStruct) CancelDeletion(c *gin.Context) {
+	userId := c.GetString("uid")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	Updated_at, err := timeHelper.GetCurrentLocationTime()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while parsing updated_at"})
+		return
+	}
+
+	// removed rather than set to null, the field missing is what every pending deletion check looks for
+	_, err = userCollection.UpdateOne(
+		ctx,
+		bson.M{"user_id": userId},
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "updated_at", Value: Updated_at}}},
+			{Key: "$unset", Value: bson.D{{Key: "deletion_scheduled_at", Value: ""}}},
+		},
+	)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func buildUserDataExportZip(export dto.UserDataExportDto) ([]byte, error) {
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"oidc_providers.json", export.Oidc_providers},
+		{"mail_preferences.json", export.Mail_preferences},
+		{"api_keys.json", export.Api_keys},
+		{"expenses.json", export.Expenses},
+		{"mails.json", export.Mails},
+		{"outbox_mails.json", export.Outbox_mails},
+		{"expense_digests.json", export.Expense_digests},
+		{"admin_actions.json", export.Admin_actions},
+	}
+
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+
+	for _, file := range files {
+		fileWriter, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.Exported_at,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := json.NewEncoder(fileWriter)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(file.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
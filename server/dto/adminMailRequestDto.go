@@ -0,0 +1,8 @@
+package dto
+
+// TestSendMailRequestDto sends a mail type to the signed in admin, template_data overrides the sample values
+type TestSendMailRequestDto struct {
+	Mail_type     string            `json:"mail_type" validate:"required"`
+	Locale        string            `json:"locale" validate:"omitempty,bcp47_language_tag"`
+	Template_data map[string]string `json:"template_data"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+type AdminChangeUserRoleRequestDto struct {
+	UserRole string `json:"user_role" validate:"required,eq=ADMIN|eq=USER"`
+}
+
+type AdminDisableUserRequestDto struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
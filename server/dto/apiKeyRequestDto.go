@@ -0,0 +1,7 @@
+package dto
+
+type CreateApiKeyRequestDto struct {
+	Name            string   `json:"name" validate:"required,min=1,max=100"`
+	Scopes          []string `json:"scopes" validate:"required,min=1,unique,dive,oneof=expense:read expense:write profile:read"`
+	Expires_in_days int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
@@ -0,0 +1,6 @@
+package dto
+
+type ChangeEmailRequestDto struct {
+	NewEmail        string `validate:"required"`
+	CurrentPassword string `validate:"required"`
+}
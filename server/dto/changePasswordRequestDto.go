@@ -0,0 +1,7 @@
+package dto
+
+type ChangePasswordRequestDto struct {
+	CurrentPassword string `validate:"required"`
+	Password        string `validate:"required"`
+	ConfirmPassword string `validate:"required"`
+}
@@ -0,0 +1,6 @@
+package dto
+
+// DeleteAccountRequestDto asks for the current password, accounts created through social login have none
+type DeleteAccountRequestDto struct {
+	CurrentPassword string
+}
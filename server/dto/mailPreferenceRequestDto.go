@@ -0,0 +1,8 @@
+package dto
+
+// UpdateMailPreferencesRequestDto only lists the optional categories, security mails cannot be turned off
+type UpdateMailPreferencesRequestDto struct {
+	Raffle_results *bool `json:"raffle_results"`
+	Marketing      *bool `json:"marketing"`
+	Digest         *bool `json:"digest"`
+}
@@ -2,14 +2,23 @@ package dto
 
 import (
 	"nft-raffle/enums"
-
-	"github.com/sendgrid/sendgrid-go/helpers/mail"
 )
 
+type MailAddress struct {
+	Name    string
+	Address string
+}
+
 type MailRequest struct {
-	FromName            string
-	FromEmail           string
-	MailType            enums.MailType
-	Tos                 []*mail.Email
+	FromName  string
+	FromEmail string
+	MailType  enums.MailType
+	// BCP 47 tag of the recipient, picks the template language
+	Locale string
+	Tos    []MailAddress
+	// owner of the mail, needed for the preferences of optional categories
+	UserId string
+	// extra headers such as List-Unsubscribe
+	Headers             map[string]string
 	DynamicTemplateData map[string]string
 }
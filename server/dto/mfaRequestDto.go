@@ -0,0 +1,17 @@
+package dto
+
+type MfaCodeRequestDto struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MfaLoginRequestDto struct {
+	MfaToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MfaRequirementRequestDto struct {
+	UserId        string `json:"user_id" validate:"required"`
+	IsMfaRequired *bool  `json:"is_mfa_required" validate:"required"`
+}
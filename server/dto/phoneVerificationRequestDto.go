@@ -0,0 +1,5 @@
+package dto
+
+type VerifyPhoneRequestDto struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
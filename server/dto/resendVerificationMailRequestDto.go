@@ -0,0 +1,5 @@
+package dto
+
+type ResendVerificationMailRequestDto struct {
+	Email string `validate:"required"`
+}
@@ -0,0 +1,14 @@
+package dto
+
+// SendGridEvent is one entry of an event webhook batch, mail_type and mail_outbox_id are the custom args set on send
+type SendGridEvent struct {
+	Email          string `json:"email"`
+	Timestamp      int64  `json:"timestamp"`
+	Event          string `json:"event"`
+	Sg_event_id    string `json:"sg_event_id"`
+	Sg_message_id  string `json:"sg_message_id"`
+	Reason         string `json:"reason"`
+	Type           string `json:"type"`
+	Mail_type      string `json:"mail_type"`
+	Mail_outbox_id string `json:"mail_outbox_id"`
+}
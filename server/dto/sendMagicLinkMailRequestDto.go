@@ -0,0 +1,5 @@
+package dto
+
+type SendMagicLinkMailRequestDto struct {
+	Email string `validate:"required"`
+}
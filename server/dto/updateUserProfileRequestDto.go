@@ -0,0 +1,11 @@
+package dto
+
+// UpdateUserProfileRequestDto only changes the fields that are present in the request
+type UpdateUserProfileRequestDto struct {
+	First_name *string `json:"first_name" validate:"omitempty,min=2,max=30"`
+	Last_name  *string `json:"last_name" validate:"omitempty,min=2,max=30"`
+	Phone      *string `json:"phone" validate:"omitempty,max=20"`
+	Avatar_url *string `json:"avatar_url" validate:"omitempty,url,max=2048"`
+	Locale     *string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+	Timezone   *string `json:"timezone" validate:"omitempty,timezone"`
+}
@@ -0,0 +1,59 @@
+package dto
+
+import (
+	"nft-raffle/models"
+	"time"
+)
+
+type UserDataExportDto struct {
+	Exported_at      time.Time                `json:"exported_at"`
+	Profile          UserResponseDto          `json:"profile"`
+	Oidc_providers   []string                 `json:"oidc_providers"`
+	Mail_preferences map[string]bool          `json:"mail_preferences"`
+	Api_keys         []ApiKeyExportDto        `json:"api_keys"`
+	Expenses         []models.Expense         `json:"expenses"`
+	Mails            []MailExportDto          `json:"mails"`
+	Outbox_mails     []OutboxMailExportDto    `json:"outbox_mails"`
+	Expense_digests  []ExpenseDigestExportDto `json:"expense_digests"`
+	Admin_actions    []AdminActionExportDto   `json:"admin_actions"`
+}
+
+// ApiKeyExportDto leaves out the hash of the key
+type ApiKeyExportDto struct {
+	Name         string     `json:"name"`
+	Prefix       string     `json:"prefix"`
+	Scopes       []string   `json:"scopes"`
+	Expires_at   time.Time  `json:"expires_at"`
+	Last_used_at *time.Time `json:"last_used_at"`
+	Created_at   time.Time  `json:"created_at"`
+}
+
+// MailExportDto leaves out the code of pending mails
+type MailExportDto struct {
+	Type       string    `json:"type"`
+	Email      string    `json:"email"`
+	Created_at time.Time `json:"created_at"`
+	Expires_at time.Time `json:"expires_at"`
+}
+
+type AdminActionExportDto struct {
+	Action     string    `json:"action"`
+	Created_at time.Time `json:"created_at"`
+}
+
+// OutboxMailExportDto leaves out the template data and bodies, they carry one-time links
+type OutboxMailExportDto struct {
+	Mail_type       string               `json:"mail_type"`
+	Tos             []models.MailAddress `json:"tos"`
+	Subject         string               `json:"subject"`
+	Status          string               `json:"status"`
+	Delivery_status string               `json:"delivery_status,omitempty"`
+	Created_at      time.Time            `json:"created_at"`
+	Sent_at         *time.Time           `json:"sent_at,omitempty"`
+}
+
+type ExpenseDigestExportDto struct {
+	Week_start time.Time `json:"week_start"`
+	Is_sent    bool      `json:"is_sent"`
+	Created_at time.Time `json:"created_at"`
+}
@@ -0,0 +1,59 @@
+package dto
+
+import (
+	"nft-raffle/models"
+	"time"
+)
+
+// UserResponseDto is the only shape a user is returned in, the password hash and 2FA secrets never leave the server
+type UserResponseDto struct {
+	User_id               string     `json:"user_id"`
+	First_name            string     `json:"first_name"`
+	Last_name             string     `json:"last_name"`
+	Email                 string     `json:"email"`
+	Phone                 string     `json:"phone"`
+	Avatar_url            string     `json:"avatar_url"`
+	Locale                string     `json:"locale"`
+	Timezone              string     `json:"timezone"`
+	User_role             string     `json:"user_role"`
+	Is_email_verified     bool       `json:"is_email_verified"`
+	Is_phone_verified     bool       `json:"is_phone_verified"`
+	Is_mfa_enabled        bool       `json:"is_mfa_enabled"`
+	Is_mfa_required       bool       `json:"is_mfa_required"`
+	Is_disabled           bool       `json:"is_disabled"`
+	Deletion_scheduled_at *time.Time `json:"deletion_scheduled_at,omitempty"`
+	Created_at            time.Time  `json:"created_at"`
+	Updated_at            time.Time  `json:"updated_at"`
+	Access_token          string     `json:"access_token,omitempty"`
+	Refresh_token         string     `json:"refresh_token,omitempty"`
+}
+
+// NewUserResponseDto maps the profile of the user, tokens are only included when includeTokens is set
+func NewUserResponseDto(user models.User, includeTokens bool) UserResponseDto {
+	userResponse := UserResponseDto{
+		User_id:               user.User_id,
+		First_name:            user.First_name,
+		Last_name:             user.Last_name,
+		Email:                 user.Email,
+		Phone:                 user.Phone,
+		Avatar_url:            user.Avatar_url,
+		Locale:                user.Locale,
+		Timezone:              user.Timezone,
+		User_role:             user.User_role,
+		Is_email_verified:     user.Is_email_verified,
+		Is_phone_verified:     user.Is_phone_verified,
+		Is_mfa_enabled:        user.Is_mfa_enabled,
+		Is_mfa_required:       user.Is_mfa_required,
+		Is_disabled:           user.Is_disabled,
+		Deletion_scheduled_at: user.Deletion_scheduled_at,
+		Created_at:            user.Created_at,
+		Updated_at:            user.Updated_at,
+	}
+
+	if includeTokens {
+		userResponse.Access_token = user.Access_token
+		userResponse.Refresh_token = user.Refresh_token
+	}
+
+	return userResponse
+}
@@ -0,0 +1,36 @@
+package enums
+
+type AdminAction string
+
+const (
+	ChangeUserRole     AdminAction = "CHANGE_USER_ROLE"
+	ForceVerifyEmail   AdminAction = "FORCE_VERIFY_EMAIL"
+	DisableUser        AdminAction = "DISABLE_USER"
+	EnableUser         AdminAction = "ENABLE_USER"
+	ForceLogoutUser    AdminAction = "FORCE_LOGOUT_USER"
+	SetUserMfaRequired AdminAction = "SET_USER_MFA_REQUIRED"
+	RetryOutboxMail    AdminAction = "RETRY_OUTBOX_MAIL"
+	TestSendMail       AdminAction = "TEST_SEND_MAIL"
+)
+
+func (a AdminAction) String() string {
+	switch a {
+	case ChangeUserRole:
+		return "CHANGE_USER_ROLE"
+	case ForceVerifyEmail:
+		return "FORCE_VERIFY_EMAIL"
+	case DisableUser:
+		return "DISABLE_USER"
+	case EnableUser:
+		return "ENABLE_USER"
+	case ForceLogoutUser:
+		return "FORCE_LOGOUT_USER"
+	case SetUserMfaRequired:
+		return "SET_USER_MFA_REQUIRED"
+	case RetryOutboxMail:
+		return "RETRY_OUTBOX_MAIL"
+	case TestSendMail:
+		return "TEST_SEND_MAIL"
+	}
+	return "unknown"
+}
@@ -0,0 +1,21 @@
+package enums
+
+type ApiKeyScope string
+
+const (
+	ExpenseRead  ApiKeyScope = "expense:read"
+	ExpenseWrite ApiKeyScope = "expense:write"
+	ProfileRead  ApiKeyScope = "profile:read"
+)
+
+func (a ApiKeyScope) String() string {
+	switch a {
+	case ExpenseRead:
+		return "expense:read"
+	case ExpenseWrite:
+		return "expense:write"
+	case ProfileRead:
+		return "profile:read"
+	}
+	return "unknown"
+}
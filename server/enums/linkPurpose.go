@@ -0,0 +1,19 @@
+package enums
+
+import "nft-raffle-mail/actionlink"
+
+// LinkPurpose names the action links that are not sent for a single mail type
+type LinkPurpose string
+
+const (
+	// the cron module signs unsubscribe links too, with the purpose of the shared mail module
+	UnsubscribeLink LinkPurpose = LinkPurpose(actionlink.UnsubscribePurpose)
+)
+
+func (l LinkPurpose) String() string {
+	switch l {
+	case UnsubscribeLink:
+		return actionlink.UnsubscribePurpose
+	}
+	return "unknown"
+}
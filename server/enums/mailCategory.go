@@ -0,0 +1,38 @@
+package enums
+
+// MailCategory groups mail types for the notification preferences of the user
+type MailCategory string
+
+const (
+	// account and sign in mails, always sent and cannot be unsubscribed from
+	SecurityMail      MailCategory = "security"
+	RaffleResultsMail MailCategory = "raffle_results"
+	MarketingMail     MailCategory = "marketing"
+	DigestMail        MailCategory = "digest"
+)
+
+var MailCategories = []MailCategory{SecurityMail, RaffleResultsMail, MarketingMail, DigestMail}
+
+func (m MailCategory) String() string {
+	switch m {
+	case SecurityMail:
+		return "security"
+	case RaffleResultsMail:
+		return "raffle_results"
+	case MarketingMail:
+		return "marketing"
+	case DigestMail:
+		return "digest"
+	}
+	return "unknown"
+}
+
+// IsOptional tells whether the user may opt out of the category
+func (m MailCategory) IsOptional() bool {
+	return m == RaffleResultsMail || m == MarketingMail || m == DigestMail
+}
+
+// IsEnabledByDefault is used until the user saves a preference, marketing and the weekly digest need an explicit opt in
+func (m MailCategory) IsEnabledByDefault() bool {
+	return m != MarketingMail && m != DigestMail
+}
@@ -0,0 +1,32 @@
+package enums
+
+// MailDeliveryEvent is the event name sendgrid posts to the event webhook, only the tracked ones are listed
+type MailDeliveryEvent string
+
+const (
+	MailDelivered  MailDeliveryEvent = "delivered"
+	MailBounce     MailDeliveryEvent = "bounce"
+	MailDropped    MailDeliveryEvent = "dropped"
+	MailSpamReport MailDeliveryEvent = "spamreport"
+	MailOpen       MailDeliveryEvent = "open"
+)
+
+func (m MailDeliveryEvent) String() string {
+	switch m {
+	case MailDelivered:
+		return "delivered"
+	case MailBounce:
+		return "bounce"
+	case MailDropped:
+		return "dropped"
+	case MailSpamReport:
+		return "spamreport"
+	case MailOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+func (m MailDeliveryEvent) IsTracked() bool {
+	return m.String() != "unknown"
+}
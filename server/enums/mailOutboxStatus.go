@@ -0,0 +1,25 @@
+package enums
+
+type MailOutboxStatus string
+
+const (
+	MailOutboxPending MailOutboxStatus = "PENDING"
+	MailOutboxSending MailOutboxStatus = "SENDING"
+	MailOutboxSent    MailOutboxStatus = "SENT"
+	// delivery gave up after the last retry, an admin can queue it again
+	MailOutboxDead MailOutboxStatus = "DEAD"
+)
+
+func (m MailOutboxStatus) String() string {
+	switch m {
+	case MailOutboxPending:
+		return "PENDING"
+	case MailOutboxSending:
+		return "SENDING"
+	case MailOutboxSent:
+		return "SENT"
+	case MailOutboxDead:
+		return "DEAD"
+	}
+	return "unknown"
+}
@@ -3,16 +3,51 @@ package enums
 type MailType string
 
 const (
-	MailVerification MailType = "MailVerification"
-	PasswordReset    MailType = "PasswordReset"
+	MailVerification    MailType = "MailVerification"
+	PasswordReset       MailType = "PasswordReset"
+	AccountLocked       MailType = "AccountLocked"
+	EmailChange         MailType = "EmailChange"
+	EmailChangeNotice   MailType = "EmailChangeNotice"
+	MagicLinkLogin      MailType = "MagicLinkLogin"
+	WeeklyExpenseDigest MailType = "WeeklyExpenseDigest"
 )
 
+// every mail type, the tests check each one has templates and a sendgrid dynamic template
+var MailTypes = []MailType{
+	MailVerification,
+	PasswordReset,
+	AccountLocked,
+	EmailChange,
+	EmailChangeNotice,
+	MagicLinkLogin,
+	WeeklyExpenseDigest,
+}
+
 func (m MailType) String() string {
 	switch m {
 	case MailVerification:
 		return "MailVerification"
 	case PasswordReset:
 		return "PasswordReset"
+	case AccountLocked:
+		return "AccountLocked"
+	case EmailChange:
+		return "EmailChange"
+	case EmailChangeNotice:
+		return "EmailChangeNotice"
+	case MagicLinkLogin:
+		return "MagicLinkLogin"
+	case WeeklyExpenseDigest:
+		return "WeeklyExpenseDigest"
 	}
 	return "unknown"
 }
+
+// Category decides whether the preferences of the user are checked, mail types not listed are security mails
+func (m MailType) Category() MailCategory {
+	switch m {
+	case WeeklyExpenseDigest:
+		return DigestMail
+	}
+	return SecurityMail
+}
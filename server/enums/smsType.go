@@ -0,0 +1,16 @@
+package enums
+
+// SmsType names the codes sent by sms, they are limited like the mailed codes but are not mails
+type SmsType string
+
+const (
+	PhoneVerificationSms SmsType = "PhoneVerification"
+)
+
+func (s SmsType) String() string {
+	switch s {
+	case PhoneVerificationSms:
+		return "PhoneVerification"
+	}
+	return "unknown"
+}
@@ -0,0 +1,18 @@
+package enums
+
+type UserRole string
+
+const (
+	Admin UserRole = "ADMIN"
+	User  UserRole = "USER"
+)
+
+func (u UserRole) String() string {
+	switch u {
+	case Admin:
+		return "ADMIN"
+	case User:
+		return "USER"
+	}
+	return "unknown"
+}
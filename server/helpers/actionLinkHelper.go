@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"fmt"
+	"net/url"
+	"nft-raffle-mail/actionlink"
+	"nft-raffle/enums"
+	"time"
+)
+
+// the tokens are signed by the shared mail module, see the key rotation notes there
+
+const (
+	ActionLinkTokenQuery string = actionlink.ActionLinkTokenQuery
+)
+
+var (
+	ActionLinkHelper IActionLinkHelper = NewActionLinkHelperWithActionLink(actionlink.NewActionLinkFromEnv(DotEnvHelper.GetEnvVariable))
+
+	// routes the links open, relative to the base url, they must match the routes package
+	ActionLinkPaths = map[ActionLinkPurpose]string{
+		enums.MailVerification: "/api/send-grid/verify-verification-mail",
+		enums.PasswordReset:    "/api/send-grid/verify-password-reset-mail",
+		enums.EmailChange:      "/api/send-grid/verify-email-change-mail",
+		enums.MagicLinkLogin:   "/api/send-grid/verify-magic-link-mail",
+		enums.UnsubscribeLink:  actionlink.UnsubscribePath,
+	}
+
+	ErrInvalidActionLink       = actionlink.ErrInvalidActionLink
+	ErrActionLinkExpired       = actionlink.ErrActionLinkExpired
+	ErrActionLinkNotConfigured = actionlink.ErrActionLinkNotConfigured
+)
+
+// ActionLinkPurpose is a mail type for the links of a single mail, or a link purpose for the others
+type ActionLinkPurpose interface {
+	String() string
+}
+
+type ActionLinkClaims = actionlink.ActionLinkClaims
+
+type IActionLinkHelper interface {
+	GenerateLink(purpose ActionLinkPurpose, subject string, code string, expires_at time.Time, query url.Values) (string, error)
+	GenerateToken(purpose ActionLinkPurpose, subject string, code string, expires_at time.Time) (string, error)
+	ParseToken(token string, purpose ActionLinkPurpose) (*ActionLinkClaims, error)
+}
+
+type actionLinkHelperStruct struct {
+	actionLink *actionlink.ActionLink
+}
+
+func NewActionLinkHelper(baseUrl string, keys string, signingKeyId string) IActionLinkHelper {
+	return NewActionLinkHelperWithActionLink(actionlink.NewActionLink(baseUrl, keys, signingKeyId))
+}
+
+func NewActionLinkHelperWithActionLink(actionLink *actionlink.ActionLink) IActionLinkHelper {
+	return &actionLinkHelperStruct{actionLink: actionLink}
+}
+
+// GenerateLink builds the url of the route handling the purpose, with the token and the extra query params
+func (a *actionLinkHelperStruct) GenerateLink(purpose ActionLinkPurpose, subject string, code string, expires_at time.Time, query url.Values) (string, error) {
+	path, ok := ActionLinkPaths[purpose]
+
+	if !ok {
+		return "", fmt.Errorf("no action link route for %s", purpose.String())
+	}
+
+	return a.actionLink.GenerateLink(path, purpose.String(), subject, code, expires_at, query)
+}
+
+func (a *actionLinkHelperStruct) GenerateToken(purpose ActionLinkPurpose, subject string, code string, expires_at time.Time) (string, error) {
+	return a.actionLink.GenerateToken(purpose.String(), subject, code, expires_at)
+}
+
+// ParseToken accepts tokens signed by any configured key, for the given purpose only
+func (a *actionLinkHelperStruct) ParseToken(token string, purpose ActionLinkPurpose) (*ActionLinkClaims, error) {
+	return a.actionLink.ParseToken(token, purpose.String())
+}
@@ -0,0 +1,131 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"nft-raffle/models"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	apiKeyPrefix string = "nfr_"
+	// characters of the key kept in plain text so the owner can tell the keys apart
+	apiKeyDisplayPrefixLength int = 12
+	// last_used_at is written at most once per interval instead of on every request
+	apiKeyLastUsedInterval time.Duration = time.Minute
+)
+
+var (
+	ApiKeyHelper IApiKeyHelper = NewApiKeyHelper()
+
+	apiKeyCollection *mongo.Collection = nftRaffleDb.OpenCollection(nftRaffleDbClient, "apiKey")
+
+	ErrInvalidApiKey = errors.New("api key is invalid or has expired")
+)
+
+// ApiKeyDetails is what the auth middleware needs from a key, the owner is read fresh so role changes apply immediately
+type ApiKeyDetails struct {
+	Api_key_id string
+	Uid        string
+	Email      string
+	First_name string
+	Last_name  string
+	User_role  string
+	Scopes     []string
+}
+
+type IApiKeyHelper interface {
+	GenerateApiKey() (apiKey, displayPrefix, keyHash string, err error)
+	HashApiKey(apiKey string) string
+	ValidateApiKey(apiKey string) (*ApiKeyDetails, error)
+}
+
+type apiKeyHelperStruct struct{}
+
+func NewApiKeyHelper() IApiKeyHelper {
+	return &apiKeyHelperStruct{}
+}
+
+func (a *apiKeyHelperStruct) GenerateApiKey() (string, string, string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+
+	apiKey := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	return apiKey, apiKey[:apiKeyDisplayPrefixLength], a.HashApiKey(apiKey), nil
+}
+
+// HashApiKey uses a plain sha256, the key has 256 bits of entropy so a slow hash adds nothing
+func (a *apiKeyHelperStruct) HashApiKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *apiKeyHelperStruct) ValidateApiKey(apiKey string) (*ApiKeyDetails, error) {
+	if !strings.HasPrefix(apiKey, apiKeyPrefix) {
+		return nil, ErrInvalidApiKey
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var key models.ApiKey
+
+	err := apiKeyCollection.FindOne(ctx, bson.M{"key_hash": a.HashApiKey(apiKey)}).Decode(&key)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvalidApiKey
+	} else if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if !now.Before(key.Expires_at) {
+		return nil, ErrInvalidApiKey
+	}
+
+	var user models.User
+
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": key.User_id}).Decode(&user); err != nil {
+		return nil, ErrInvalidApiKey
+	}
+
+	// keys stop working while the account is disabled or waiting for deletion, and work again if that is undone
+	if user.Is_disabled || user.Deletion_scheduled_at != nil {
+		return nil, ErrInvalidApiKey
+	}
+
+	if key.Last_used_at == nil || now.Sub(*key.Last_used_at) >= apiKeyLastUsedInterval {
+		_, err := apiKeyCollection.UpdateOne(
+			ctx,
+			bson.M{"api_key_id": key.Api_key_id},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "last_used_at", Value: now}}}},
+		)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ApiKeyDetails{
+		Api_key_id: key.Api_key_id,
+		Uid:        user.User_id,
+		Email:      user.Email,
+		First_name: user.First_name,
+		Last_name:  user.Last_name,
+		User_role:  user.User_role,
+		Scopes:     key.Scopes,
+	}, nil
+}
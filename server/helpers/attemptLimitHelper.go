@@ -0,0 +1,300 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	failedLoginAccount    string = "failed_login:email"
+	failedLoginIp         string = "failed_login:ip"
+	loginLockoutAccount   string = "login_lockout:email"
+	loginLockoutIp        string = "login_lockout:ip"
+	loginDelayAccount     string = "login_delay:email"
+	failedMailCodeAttempt string = "failed_mail_code"
+	mailSendCooldown      string = "mail_send_cooldown"
+	mailSendDaily         string = "mail_send_daily"
+
+	// failures allowed before every further attempt is delayed
+	loginDelayFreeAttempts int64         = 2
+	maxLoginDelay          time.Duration = 30 * time.Second
+)
+
+var (
+	AttemptLimitHelper IAttemptLimitHelper = NewAttemptLimitHelper()
+
+	maxFailedLoginsPerAccount = DotEnvHelper.GetEnvVariable("LOGIN_MAX_FAILED_ATTEMPTS_PER_ACCOUNT")
+	maxFailedLoginsPerIp      = DotEnvHelper.GetEnvVariable("LOGIN_MAX_FAILED_ATTEMPTS_PER_IP")
+	failedLoginWindowMinutes  = DotEnvHelper.GetEnvVariable("LOGIN_FAILED_ATTEMPT_WINDOW_MINUTES")
+	loginLockoutMinutes       = DotEnvHelper.GetEnvVariable("LOGIN_LOCKOUT_MINUTES")
+	maxMailCodeAttempts       = DotEnvHelper.GetEnvVariable("MAIL_CODE_MAX_ATTEMPTS")
+)
+
+// AttemptScope keeps the counters of each kind of code apart, mail types and sms types are both scopes
+type AttemptScope interface {
+	String() string
+}
+
+type IAttemptLimitHelper interface {
+	GetLoginRetryAfter(email, ip string) (time.Duration, error)
+	RecordFailedLogin(email, ip string) (isAccountLocked bool, err error)
+	ResetFailedLogins(email string) error
+	GetLoginLockoutDuration() time.Duration
+	RecordFailedMailCodeAttempt(scope AttemptScope, email string) (isCodeInvalidated bool, err error)
+	ResetMailCodeAttempts(scope AttemptScope, email string) error
+	ReserveMailSend(scope AttemptScope, email string, cooldown time.Duration, dailyCap int) (retryAfter time.Duration, err error)
+}
+
+// AttemptLimitConfig holds the thresholds and windows of the login and mail code limits
+type AttemptLimitConfig struct {
+	MaxFailedLoginsPerAccount int
+	MaxFailedLoginsPerIp      int
+	FailedLoginWindow         time.Duration
+	LoginLockout              time.Duration
+	MaxMailCodeAttempts       int
+}
+
+type attemptLimitHelperStruct struct {
+	redisClient *redis.Client
+	config      AttemptLimitConfig
+}
+
+func NewAttemptLimitHelper() IAttemptLimitHelper {
+	return NewAttemptLimitHelperWithConfig(redisClient, AttemptLimitConfig{
+		MaxFailedLoginsPerAccount: getIntEnvVariable(maxFailedLoginsPerAccount, 5),
+		MaxFailedLoginsPerIp:      getIntEnvVariable(maxFailedLoginsPerIp, 20),
+		FailedLoginWindow:         time.Minute * time.Duration(getIntEnvVariable(failedLoginWindowMinutes, 15)),
+		LoginLockout:              time.Minute * time.Duration(getIntEnvVariable(loginLockoutMinutes, 15)),
+		MaxMailCodeAttempts:       getIntEnvVariable(maxMailCodeAttempts, 5),
+	})
+}
+
+func NewAttemptLimitHelperWithConfig(client *redis.Client, config AttemptLimitConfig) IAttemptLimitHelper {
+	return &attemptLimitHelperStruct{redisClient: client, config: config}
+}
+
+// GetLoginRetryAfter returns how long the caller has to wait before the next login attempt,
+// zero when the account and the ip are neither locked nor delayed.
+func (h *attemptLimitHelperStruct) GetLoginRetryAfter(email, ip string) (time.Duration, error) {
+	email = normalizeAttemptEmail(email)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	pipe := h.redisClient.Pipeline()
+
+	accountLockoutTTL := pipe.PTTL(ctx, fmt.Sprintf("%s:%s", loginLockoutAccount, email))
+	ipLockoutTTL := pipe.PTTL(ctx, fmt.Sprintf("%s:%s", loginLockoutIp, ip))
+	accountDelayTTL := pipe.PTTL(ctx, fmt.Sprintf("%s:%s", loginDelayAccount, email))
+
+	_, err := pipe.Exec(ctx)
+
+	if err != nil && err != redis.Nil {
+		return 0, err
+	}
+
+	var retryAfter time.Duration
+
+	for _, ttl := range []time.Duration{accountLockoutTTL.Val(), ipLockoutTTL.Val(), accountDelayTTL.Val()} {
+		if ttl > retryAfter {
+			retryAfter = ttl
+		}
+	}
+
+	return retryAfter, nil
+}
+
+func (h *attemptLimitHelperStruct) RecordFailedLogin(email, ip string) (bool, error) {
+	email = normalizeAttemptEmail(email)
+	window := h.config.FailedLoginWindow
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	accountFailures, err := h.recordSlidingWindowAttempt(ctx, fmt.Sprintf("%s:%s", failedLoginAccount, email), window)
+
+	if err != nil {
+		return false, err
+	}
+
+	ipFailures, err := h.recordSlidingWindowAttempt(ctx, fmt.Sprintf("%s:%s", failedLoginIp, ip), window)
+
+	if err != nil {
+		return false, err
+	}
+
+	lockoutDuration := h.GetLoginLockoutDuration()
+
+	if ipFailures >= int64(h.config.MaxFailedLoginsPerIp) {
+		err = h.redisClient.Set(ctx, fmt.Sprintf("%s:%s", loginLockoutIp, ip), ipFailures, lockoutDuration).Err()
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if accountFailures >= int64(h.config.MaxFailedLoginsPerAccount) {
+		pipe := h.redisClient.TxPipeline()
+
+		pipe.Set(ctx, fmt.Sprintf("%s:%s", loginLockoutAccount, email), accountFailures, lockoutDuration)
+		pipe.Del(ctx, fmt.Sprintf("%s:%s", failedLoginAccount, email), fmt.Sprintf("%s:%s", loginDelayAccount, email))
+
+		_, err = pipe.Exec(ctx)
+
+		if err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	// progressive delay: 1s, 2s, 4s ... capped at maxLoginDelay
+	if accountFailures > loginDelayFreeAttempts {
+		delay := time.Second << (accountFailures - loginDelayFreeAttempts - 1)
+
+		if delay > maxLoginDelay {
+			delay = maxLoginDelay
+		}
+
+		err = h.redisClient.Set(ctx, fmt.Sprintf("%s:%s", loginDelayAccount, email), accountFailures, delay).Err()
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+func (h *attemptLimitHelperStruct) ResetFailedLogins(email string) error {
+	email = normalizeAttemptEmail(email)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	return h.redisClient.Del(
+		ctx,
+		fmt.Sprintf("%s:%s", failedLoginAccount, email),
+		fmt.Sprintf("%s:%s", loginDelayAccount, email),
+	).Err()
+}
+
+func (h *attemptLimitHelperStruct) GetLoginLockoutDuration() time.Duration {
+	return h.config.LoginLockout
+}
+
+// RecordFailedMailCodeAttempt counts wrong guesses at a mailed code,
+// once the cap is reached the caller must invalidate the code.
+func (h *attemptLimitHelperStruct) RecordFailedMailCodeAttempt(scope AttemptScope, email string) (bool, error) {
+	key := fmt.Sprintf("%s:%s:%s", failedMailCodeAttempt, scope.String(), normalizeAttemptEmail(email))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	pipe := h.redisClient.TxPipeline()
+
+	attempts := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, 24*time.Hour)
+
+	_, err := pipe.Exec(ctx)
+
+	if err != nil {
+		return false, err
+	}
+
+	if attempts.Val() >= int64(h.config.MaxMailCodeAttempts) {
+		return true, h.redisClient.Del(ctx, key).Err()
+	}
+
+	return false, nil
+}
+
+func (h *attemptLimitHelperStruct) ResetMailCodeAttempts(scope AttemptScope, email string) error {
+	key := fmt.Sprintf("%s:%s:%s", failedMailCodeAttempt, scope.String(), normalizeAttemptEmail(email))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	return h.redisClient.Del(ctx, key).Err()
+}
+
+// ReserveMailSend claims a send slot for the email, returning a positive retryAfter
+// while the cooldown is running or the daily cap has been reached.
+func (h *attemptLimitHelperStruct) ReserveMailSend(scope AttemptScope, email string, cooldown time.Duration, dailyCap int) (time.Duration, error) {
+	email = normalizeAttemptEmail(email)
+	cooldownKey := fmt.Sprintf("%s:%s:%s", mailSendCooldown, scope.String(), email)
+	dailyKey := fmt.Sprintf("%s:%s:%s:%s", mailSendDaily, scope.String(), email, time.Now().UTC().Format("2006-01-02"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	isReserved, err := h.redisClient.SetNX(ctx, cooldownKey, time.Now().Unix(), cooldown).Result()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if !isReserved {
+		return h.redisClient.PTTL(ctx, cooldownKey).Result()
+	}
+
+	pipe := h.redisClient.TxPipeline()
+
+	sendCount := pipe.Incr(ctx, dailyKey)
+	pipe.Expire(ctx, dailyKey, 24*time.Hour)
+
+	_, err = pipe.Exec(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if sendCount.Val() > int64(dailyCap) {
+		return h.redisClient.PTTL(ctx, dailyKey).Result()
+	}
+
+	return 0, nil
+}
+
+// recordSlidingWindowAttempt stores the attempt in a sorted set scored by time and returns the attempts within the window
+func (h *attemptLimitHelperStruct) recordSlidingWindowAttempt(ctx context.Context, key string, window time.Duration) (int64, error) {
+	now := time.Now()
+
+	pipe := h.redisClient.TxPipeline()
+
+	pipe.ZRemRangeByScore(ctx, key, "0", strconv.FormatInt(now.Add(-window).UnixMilli(), 10))
+	pipe.ZAdd(ctx, key, &redis.Z{
+		Score:  float64(now.UnixMilli()),
+		Member: fmt.Sprintf("%d-%d", now.UnixNano(), rand.Int63()),
+	})
+	count := pipe.ZCard(ctx, key)
+	pipe.Expire(ctx, key, window)
+
+	_, err := pipe.Exec(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count.Val(), nil
+}
+
+func normalizeAttemptEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func getIntEnvVariable(value string, defaultValue int) int {
+	valueInt, err := strconv.Atoi(value)
+
+	if err != nil || valueInt <= 0 {
+		return defaultValue
+	}
+
+	return valueInt
+}
@@ -1,22 +1,75 @@
 package helpers
 
 import (
+	"errors"
 	"net/mail"
+	"strings"
 )
 
-var DataValidationHelper IDataValidationHelper = NewDataValidationHelper()
+var (
+	DataValidationHelper IDataValidationHelper = NewDataValidationHelper()
+
+	// country calling code without "+" used for national numbers written with a leading trunk "0"
+	phoneDefaultCountryCode = DotEnvHelper.GetEnvVariable("PHONE_DEFAULT_COUNTRY_CODE")
+
+	ErrInvalidPhoneNumber = errors.New("phone number must be in international format, for example +14155552671")
+)
 
 type IDataValidationHelper interface {
 	IsEmailValid(email string) error
+	NormalizePhoneNumber(phone string) (string, error)
 }
 
-type dataValidationHelperStruct struct{}
+type dataValidationHelperStruct struct {
+	defaultCountryCode string
+}
 
 func NewDataValidationHelper() IDataValidationHelper {
-	return &dataValidationHelperStruct{}
+	return NewDataValidationHelperWithCountryCode(phoneDefaultCountryCode)
+}
+
+func NewDataValidationHelperWithCountryCode(defaultCountryCode string) IDataValidationHelper {
+	return &dataValidationHelperStruct{defaultCountryCode: strings.TrimPrefix(strings.TrimSpace(defaultCountryCode), "+")}
 }
 
 func (d *dataValidationHelperStruct) IsEmailValid(email string) error {
 	_, err := mail.ParseAddress(email)
 	return err
 }
+
+// NormalizePhoneNumber returns the E.164 form, "+" followed by at most 15 digits without a leading zero.
+// Spaces, dashes, dots and brackets are dropped and the "00" international prefix is accepted for "+".
+func (d *dataValidationHelperStruct) NormalizePhoneNumber(phone string) (string, error) {
+	var sb strings.Builder
+
+	for i, r := range strings.TrimSpace(phone) {
+		switch {
+		case r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case r == '+' && i == 0:
+			sb.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidPhoneNumber
+		}
+	}
+
+	number := sb.String()
+
+	switch {
+	case strings.HasPrefix(number, "+"):
+		number = number[1:]
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case strings.HasPrefix(number, "0") && d.defaultCountryCode != "":
+		number = d.defaultCountryCode + number[1:]
+	default:
+		return "", ErrInvalidPhoneNumber
+	}
+
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", ErrInvalidPhoneNumber
+	}
+
+	return "+" + number, nil
+}
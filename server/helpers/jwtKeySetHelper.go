@@ -0,0 +1,245 @@
+package helpers
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"nft-raffle/logger"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Key rotation:
+//  1. drop the new private key into JWT_KEYS_DIR next to the current one, it is published in the JWKS right away
+//  2. once verifiers have refreshed their JWKS, point JWT_SIGNING_KEY_ID at the new key
+//  3. after the access token TTL has passed, replace the old private key file with its public key (or delete it)
+//
+// Each file in the directory is a PEM encoded key named <kid>.pem, private keys can sign and verify,
+// public keys can only verify.
+
+var (
+	JwtKeySetHelper IJwtKeySetHelper = NewJwtKeySetHelper()
+
+	jwtKeysDir      string = DotEnvHelper.GetEnvVariable("JWT_KEYS_DIR")
+	jwtSigningKeyId string = DotEnvHelper.GetEnvVariable("JWT_SIGNING_KEY_ID")
+)
+
+type IJwtKeySetHelper interface {
+	GetKeySet() *JwtKeySet
+}
+
+type jwtKeySetHelperStruct struct {
+	keySet *JwtKeySet
+}
+
+// NewJwtKeySetHelper loads the key set once at startup, access tokens stay HS256 when JWT_KEYS_DIR is not set
+func NewJwtKeySetHelper() IJwtKeySetHelper {
+	if jwtKeysDir == "" {
+		return &jwtKeySetHelperStruct{}
+	}
+
+	keySet, err := LoadJwtKeySet(jwtKeysDir, jwtSigningKeyId)
+
+	if err != nil {
+		logger.Logger.Fatal("Error loading jwt key set in jwtKeySetHelper.go " + err.Error())
+	}
+
+	return &jwtKeySetHelperStruct{keySet: keySet}
+}
+
+// GetKeySet returns nil when asymmetric signing is not configured
+func (h *jwtKeySetHelperStruct) GetKeySet() *JwtKeySet {
+	return h.keySet
+}
+
+type JwtKey struct {
+	Kid        string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+}
+
+type JwtKeySet struct {
+	SigningKey *JwtKey
+	Keys       map[string]*JwtKey
+}
+
+type Jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type Jwks struct {
+	Keys []Jwk `json:"keys"`
+}
+
+// LoadJwtKeySet reads every <kid>.pem file of the directory, signingKid must refer to a private key
+func LoadJwtKeySet(dir, signingKid string) (*JwtKeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	keySet := &JwtKeySet{Keys: map[string]*JwtKey{}}
+
+	for _, path := range paths {
+		pemBytes, err := os.ReadFile(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := parseJwtKey(kid, pemBytes)
+
+		if err != nil {
+			return nil, fmt.Errorf("error occured while parsing jwt key %s: %w", path, err)
+		}
+
+		keySet.Keys[kid] = key
+	}
+
+	if len(keySet.Keys) < 1 {
+		return nil, fmt.Errorf("no jwt keys found in %s", dir)
+	}
+
+	signingKey, ok := keySet.Keys[signingKid]
+
+	if !ok || signingKey.PrivateKey == nil {
+		return nil, fmt.Errorf("signing key %s is not a private key in %s", signingKid, dir)
+	}
+
+	keySet.SigningKey = signingKey
+
+	return keySet, nil
+}
+
+func (k *JwtKeySet) SignedString(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.SigningKey.Method, claims)
+	token.Header["kid"] = k.SigningKey.Kid
+
+	return token.SignedString(k.SigningKey.PrivateKey)
+}
+
+// Keyfunc picks the verification key by kid and refuses any algorithm other than the key's own
+func (k *JwtKeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.Keys[kid]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown jwt key id %q", kid)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected jwt signing method %s", token.Method.Alg())
+	}
+
+	return key.PublicKey, nil
+}
+
+func (k *JwtKeySet) Jwks() Jwks {
+	kids := make([]string, 0, len(k.Keys))
+
+	for kid := range k.Keys {
+		kids = append(kids, kid)
+	}
+
+	sort.Strings(kids)
+
+	jwks := Jwks{Keys: []Jwk{}}
+
+	for _, kid := range kids {
+		key := k.Keys[kid]
+		jwk := Jwk{Kid: kid, Use: "sig", Alg: key.Method.Alg()}
+
+		switch publicKey := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+func parseJwtKey(kid string, pemBytes []byte) (*JwtKey, error) {
+	block, _ := pem.Decode(pemBytes)
+
+	if block == nil {
+		return nil, errors.New("invalid pem")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return newJwtKey(kid, privateKey, nil)
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return newJwtKey(kid, privateKey, nil)
+	case "PUBLIC KEY":
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return newJwtKey(kid, nil, publicKey)
+	}
+
+	return nil, fmt.Errorf("unsupported pem block %s", block.Type)
+}
+
+func newJwtKey(kid string, privateKey crypto.PrivateKey, publicKey crypto.PublicKey) (*JwtKey, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		publicKey = &key.PublicKey
+	case ed25519.PrivateKey:
+		publicKey = key.Public()
+	case nil:
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		return &JwtKey{Kid: kid, Method: jwt.SigningMethodRS256, PrivateKey: privateKey, PublicKey: publicKey}, nil
+	case ed25519.PublicKey:
+		return &JwtKey{Kid: kid, Method: jwt.SigningMethodEdDSA, PrivateKey: privateKey, PublicKey: publicKey}, nil
+	}
+
+	return nil, errors.New("only RSA and Ed25519 keys are supported")
+}
@@ -0,0 +1,53 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"nft-raffle/enums"
+	"strings"
+)
+
+var (
+	MailCodeHelper IMailCodeHelper = NewMailCodeHelper(DotEnvHelper.GetEnvVariable("MAIL_CODE_HASH_SECRET"))
+
+	ErrMailCodeNotConfigured = errors.New("mail code hash secret is not configured")
+)
+
+// IMailCodeHelper hashes the codes sent by mail, only the hash is stored
+type IMailCodeHelper interface {
+	HashCode(mailType enums.MailType, email string, code string) (string, error)
+	IsCodeValid(codeHash string, mailType enums.MailType, email string, code string) bool
+}
+
+type mailCodeHelperStruct struct {
+	secret []byte
+}
+
+func NewMailCodeHelper(secret string) IMailCodeHelper {
+	return &mailCodeHelperStruct{secret: []byte(secret)}
+}
+
+// HashCode uses a keyed hash, a six digit code has too few values for a plain hash to hide it.
+// The mail type and address are part of the input so a hash cannot be reused for another mail.
+func (m *mailCodeHelperStruct) HashCode(mailType enums.MailType, email string, code string) (string, error) {
+	if len(m.secret) == 0 {
+		return "", ErrMailCodeNotConfigured
+	}
+
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(mailType.String() + "." + strings.ToLower(email) + "." + code))
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (m *mailCodeHelperStruct) IsCodeValid(codeHash string, mailType enums.MailType, email string, code string) bool {
+	hash, err := m.HashCode(mailType, email, code)
+
+	if err != nil || codeHash == "" {
+		return false
+	}
+
+	return hmac.Equal([]byte(hash), []byte(codeHash))
+}
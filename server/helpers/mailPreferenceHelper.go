@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"errors"
+	"nft-raffle/enums"
+	"nft-raffle/models"
+	"time"
+)
+
+const (
+	unsubscribeLinkExpiration time.Duration = 90 * 24 * time.Hour
+)
+
+var (
+	MailPreferenceHelper IMailPreferenceHelper = NewMailPreferenceHelper(ActionLinkHelper)
+
+	ErrInvalidUnsubscribeToken = errors.New("unsubscribe link is invalid")
+	ErrMailCategoryNotOptional = errors.New("mail category cannot be unsubscribed from")
+)
+
+type IMailPreferenceHelper interface {
+	IsCategoryEnabled(user models.User, category enums.MailCategory) bool
+	GetPreferences(user models.User) map[string]bool
+	GenerateUnsubscribeLink(userId string, category enums.MailCategory) (string, error)
+	ParseUnsubscribeToken(token string) (userId string, category enums.MailCategory, err error)
+}
+
+// unsubscribe links are action links, they are signed and rotated with the same keys as the other mailed links
+type mailPreferenceHelperStruct struct {
+	actionLinkHelper IActionLinkHelper
+}
+
+func NewMailPreferenceHelper(actionLinkHelper IActionLinkHelper) IMailPreferenceHelper {
+	return &mailPreferenceHelperStruct{actionLinkHelper: actionLinkHelper}
+}
+
+func (m *mailPreferenceHelperStruct) IsCategoryEnabled(user models.User, category enums.MailCategory) bool {
+	if !category.IsOptional() {
+		return true
+	}
+
+	if isEnabled, ok := user.Mail_preferences[category.String()]; ok {
+		return isEnabled
+	}
+
+	return category.IsEnabledByDefault()
+}
+
+// GetPreferences resolves every category, including the ones the user never changed
+func (m *mailPreferenceHelperStruct) GetPreferences(user models.User) map[string]bool {
+	preferences := map[string]bool{}
+
+	for _, category := range enums.MailCategories {
+		preferences[category.String()] = m.IsCategoryEnabled(user, category)
+	}
+
+	return preferences
+}
+
+// GenerateUnsubscribeLink signs the user and category into an action link so it works without signing in,
+// it lasts long because mail clients may call it long after the mail was sent
+func (m *mailPreferenceHelperStruct) GenerateUnsubscribeLink(userId string, category enums.MailCategory) (string, error) {
+	if !category.IsOptional() {
+		return "", ErrMailCategoryNotOptional
+	}
+
+	return m.actionLinkHelper.GenerateLink(enums.UnsubscribeLink, userId, category.String(), time.Now().Add(unsubscribeLinkExpiration), nil)
+}
+
+func (m *mailPreferenceHelperStruct) ParseUnsubscribeToken(token string) (string, enums.MailCategory, error) {
+	claims, err := m.actionLinkHelper.ParseToken(token, enums.UnsubscribeLink)
+
+	if errors.Is(err, ErrInvalidActionLink) || errors.Is(err, ErrActionLinkExpired) {
+		return "", "", ErrInvalidUnsubscribeToken
+	} else if err != nil {
+		return "", "", err
+	}
+
+	category := enums.MailCategory(claims.Code)
+
+	if !category.IsOptional() {
+		return "", "", ErrInvalidUnsubscribeToken
+	}
+
+	return claims.Subject, category, nil
+}
@@ -0,0 +1,534 @@
+package helpers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	// holds the hash of the state, the callback must come back to the browser that started the login
+	OidcStateCookie string = "oidc_state"
+
+	oidcAuthorizationState string        = "oidc_state"
+	oidcAuthorizationTTL   time.Duration = 10 * time.Minute
+	oidcCacheTTL           time.Duration = time.Hour
+)
+
+var (
+	OidcHelper IOidcHelper = NewOidcHelper()
+
+	// comma separated provider names, each configured through OIDC_<NAME>_* variables
+	oidcProviderNames string = DotEnvHelper.GetEnvVariable("OIDC_PROVIDERS")
+)
+
+type OidcProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+	Scopes       []string
+}
+
+type OidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// OidcAuthorizationSession is kept in redis between the redirect to the provider and the callback
+type OidcAuthorizationSession struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	// the callback is a browser redirect, so the session mode of the login request is remembered here
+	Is_cookie_mode bool `json:"is_cookie_mode"`
+}
+
+type OidcIdentityClaims struct {
+	Subject        string
+	Email          string
+	Email_verified bool
+	Given_name     string
+	Family_name    string
+	Name           string
+}
+
+type IOidcHelper interface {
+	GetProvider(name string) (OidcProviderConfig, bool)
+	Discover(provider OidcProviderConfig) (*OidcDiscovery, error)
+	GenerateRandomToken() (string, error)
+	GeneratePkceCodeChallenge(codeVerifier string) string
+	BuildAuthorizationUrl(provider OidcProviderConfig, discovery *OidcDiscovery, state, nonce, codeChallenge string) string
+	ExchangeCode(provider OidcProviderConfig, discovery *OidcDiscovery, code, codeVerifier string) (idToken string, err error)
+	VerifyIdToken(provider OidcProviderConfig, discovery *OidcDiscovery, idToken, nonce string) (*OidcIdentityClaims, error)
+	SaveAuthorizationSession(state string, session OidcAuthorizationSession) error
+	ConsumeAuthorizationSession(state string) (*OidcAuthorizationSession, error)
+	HashState(state string) string
+	IsStateCookieValid(stateCookie, state string) bool
+}
+
+type oidcCacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+type oidcHelperStruct struct {
+	httpClient *http.Client
+	providers  map[string]OidcProviderConfig
+
+	cacheMutex sync.Mutex
+	cache      map[string]oidcCacheEntry
+}
+
+func NewOidcHelper() IOidcHelper {
+	providers := map[string]OidcProviderConfig{}
+
+	for _, name := range strings.Split(oidcProviderNames, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+
+		if name == "" {
+			continue
+		}
+
+		envPrefix := "OIDC_" + strings.ToUpper(name) + "_"
+		scopes := strings.Fields(strings.ReplaceAll(DotEnvHelper.GetEnvVariable(envPrefix+"SCOPES"), ",", " "))
+
+		if len(scopes) < 1 {
+			scopes = []string{"openid", "email", "profile"}
+		}
+
+		providers[name] = OidcProviderConfig{
+			Name:         name,
+			Issuer:       strings.TrimSuffix(DotEnvHelper.GetEnvVariable(envPrefix+"ISSUER"), "/"),
+			ClientId:     DotEnvHelper.GetEnvVariable(envPrefix + "CLIENT_ID"),
+			ClientSecret: DotEnvHelper.GetEnvVariable(envPrefix + "CLIENT_SECRET"),
+			RedirectUrl:  DotEnvHelper.GetEnvVariable(envPrefix + "REDIRECT_URL"),
+			Scopes:       scopes,
+		}
+	}
+
+	return NewOidcHelperWithProviders(providers, &http.Client{Timeout: 10 * time.Second})
+}
+
+// NewOidcHelperWithProviders is used by tests to point the helper at a mock provider
+func NewOidcHelperWithProviders(providers map[string]OidcProviderConfig, httpClient *http.Client) IOidcHelper {
+	return &oidcHelperStruct{
+		httpClient: httpClient,
+		providers:  providers,
+		cache:      map[string]oidcCacheEntry{},
+	}
+}
+
+func (h *oidcHelperStruct) GetProvider(name string) (OidcProviderConfig, bool) {
+	provider, ok := h.providers[strings.ToLower(name)]
+	return provider, ok
+}
+
+func (h *oidcHelperStruct) Discover(provider OidcProviderConfig) (*OidcDiscovery, error) {
+	cacheKey := "discovery:" + provider.Issuer
+
+	if cached, ok := h.getCache(cacheKey); ok {
+		return cached.(*OidcDiscovery), nil
+	}
+
+	var discovery OidcDiscovery
+
+	if err := h.getJson(provider.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != provider.Issuer {
+		return nil, fmt.Errorf("discovery issuer %s does not match configured issuer %s", discovery.Issuer, provider.Issuer)
+	}
+
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JwksUri == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+
+	h.setCache(cacheKey, &discovery)
+
+	return &discovery, nil
+}
+
+func (h *oidcHelperStruct) GenerateRandomToken() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// RFC 7636 S256 code challenge
+func (h *oidcHelperStruct) GeneratePkceCodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (h *oidcHelperStruct) BuildAuthorizationUrl(provider OidcProviderConfig, discovery *OidcDiscovery, state, nonce, codeChallenge string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.ClientId)
+	query.Set("redirect_uri", provider.RedirectUrl)
+	query.Set("scope", strings.Join(provider.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.AuthorizationEndpoint + separator + query.Encode()
+}
+
+func (h *oidcHelperStruct) ExchangeCode(provider OidcProviderConfig, discovery *OidcDiscovery, code, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.RedirectUrl)
+	form.Set("client_id", provider.ClientId)
+	form.Set("code_verifier", codeVerifier)
+
+	if provider.ClientSecret != "" {
+		form.Set("client_secret", provider.ClientSecret)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return "", err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := h.httpClient.Do(request)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+
+	if err != nil {
+		return "", err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint responded %d: %s", response.StatusCode, string(body))
+	}
+
+	var tokenResponse struct {
+		IdToken string `json:"id_token"`
+	}
+
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return "", err
+	}
+
+	if tokenResponse.IdToken == "" {
+		return "", errors.New("token response does not contain an id_token")
+	}
+
+	return tokenResponse.IdToken, nil
+}
+
+func (h *oidcHelperStruct) VerifyIdToken(provider OidcProviderConfig, discovery *OidcDiscovery, idToken, nonce string) (*OidcIdentityClaims, error) {
+	keyfunc := func(token *jwt.Token) (interface{}, error) {
+		if _, isHmac := token.Method.(*jwt.SigningMethodHMAC); isHmac {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+
+		kid, _ := token.Header["kid"].(string)
+
+		return h.getJwksKey(discovery.JwksUri, kid)
+	}
+
+	token, err := jwt.ParseWithClaims(idToken, jwt.MapClaims{}, keyfunc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+
+	if !ok {
+		return nil, errors.New("invalid id token")
+	}
+
+	if !claims.VerifyIssuer(discovery.Issuer, true) {
+		return nil, errors.New("id token issuer does not match")
+	}
+
+	if !claims.VerifyAudience(provider.ClientId, true) {
+		return nil, errors.New("id token audience does not match")
+	}
+
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("id token has expired")
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, errors.New("id token nonce does not match")
+	}
+
+	identity := &OidcIdentityClaims{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Given_name, _ = claims["given_name"].(string)
+	identity.Family_name, _ = claims["family_name"].(string)
+	identity.Name, _ = claims["name"].(string)
+
+	// some providers send email_verified as a string
+	switch emailVerified := claims["email_verified"].(type) {
+	case bool:
+		identity.Email_verified = emailVerified
+	case string:
+		identity.Email_verified = emailVerified == "true"
+	}
+
+	if identity.Subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+
+	return identity, nil
+}
+
+func (h *oidcHelperStruct) SaveAuthorizationSession(state string, session OidcAuthorizationSession) error {
+	value, err := json.Marshal(session)
+
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	return redisClient.Set(ctx, fmt.Sprintf("%s:%s", oidcAuthorizationState, state), value, oidcAuthorizationTTL).Err()
+}
+
+// ConsumeAuthorizationSession returns the session only once, a replayed callback finds nothing
+func (h *oidcHelperStruct) ConsumeAuthorizationSession(state string) (*OidcAuthorizationSession, error) {
+	key := fmt.Sprintf("%s:%s", oidcAuthorizationState, state)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	pipe := redisClient.TxPipeline()
+
+	value := pipe.Get(ctx, key)
+	pipe.Del(ctx, key)
+
+	_, err := pipe.Exec(ctx)
+
+	if err == redis.Nil {
+		return nil, errors.New("oidc state is invalid or has expired")
+	} else if err != nil {
+		return nil, err
+	}
+
+	var session OidcAuthorizationSession
+
+	if err := json.Unmarshal([]byte(value.Val()), &session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// HashState is the value of the state cookie, the state itself only travels through the provider
+func (h *oidcHelperStruct) HashState(state string) string {
+	hash := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// IsStateCookieValid rejects a callback started in another browser, which is how a login csrf forces the attacker's account on the victim
+func (h *oidcHelperStruct) IsStateCookieValid(stateCookie, state string) bool {
+	if stateCookie == "" || state == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(stateCookie), []byte(h.HashState(state))) == 1
+}
+
+// getJwksKey refetches the provider key set once when the kid is unknown, which covers provider key rotation
+func (h *oidcHelperStruct) getJwksKey(jwksUri, kid string) (interface{}, error) {
+	cacheKey := "jwks:" + jwksUri
+
+	for attempt := 0; attempt < 2; attempt++ {
+		cached, ok := h.getCache(cacheKey)
+
+		if !ok || attempt > 0 {
+			var jwks struct {
+				Keys []map[string]string `json:"keys"`
+			}
+
+			if err := h.getJson(jwksUri, &jwks); err != nil {
+				return nil, err
+			}
+
+			keys := map[string]interface{}{}
+
+			for _, jwk := range jwks.Keys {
+				if jwk["use"] != "" && jwk["use"] != "sig" {
+					continue
+				}
+
+				publicKey, err := parseJwk(jwk)
+
+				if err != nil {
+					continue
+				}
+
+				keys[jwk["kid"]] = publicKey
+			}
+
+			h.setCache(cacheKey, keys)
+			cached = keys
+		}
+
+		keys := cached.(map[string]interface{})
+
+		if publicKey, ok := keys[kid]; ok {
+			return publicKey, nil
+		}
+
+		// a single key without kid is common for small providers
+		if kid == "" && len(keys) == 1 {
+			for _, publicKey := range keys {
+				return publicKey, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no provider key found for kid %q", kid)
+}
+
+func (h *oidcHelperStruct) getJson(endpoint string, target interface{}) error {
+	response, err := h.httpClient.Get(endpoint)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded %d", endpoint, response.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(target)
+}
+
+func (h *oidcHelperStruct) getCache(key string) (interface{}, bool) {
+	h.cacheMutex.Lock()
+	defer h.cacheMutex.Unlock()
+
+	entry, ok := h.cache[key]
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+
+	return entry.value, true
+}
+
+func (h *oidcHelperStruct) setCache(key string, value interface{}) {
+	h.cacheMutex.Lock()
+	defer h.cacheMutex.Unlock()
+
+	h.cache[key] = oidcCacheEntry{value: value, expiresAt: time.Now().Add(oidcCacheTTL)}
+}
+
+func parseJwk(jwk map[string]string) (interface{}, error) {
+	decode := func(field string) ([]byte, error) {
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk[field], "="))
+	}
+
+	switch jwk["kty"] {
+	case "RSA":
+		n, err := decode("n")
+
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decode("e")
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+
+		switch jwk["crv"] {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", jwk["crv"])
+		}
+
+		x, err := decode("x")
+
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decode("y")
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if jwk["crv"] != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", jwk["crv"])
+		}
+
+		x, err := decode("x")
+
+		if err != nil {
+			return nil, err
+		}
+
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", jwk["kty"])
+}
@@ -1,31 +1,178 @@
 package helpers
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
 
-var PasswordHelper IPasswordHelper = NewPasswordHelper()
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	BcryptAlgorithm   string = "bcrypt"
+	Argon2idAlgorithm string = "argon2id"
+
+	argon2idSaltLength uint32 = 16
+	argon2idKeyLength  uint32 = 32
+)
+
+var (
+	PasswordHelper IPasswordHelper = NewPasswordHelper()
+
+	passwordHashAlgorithm     = DotEnvHelper.GetEnvVariable("PASSWORD_HASH_ALGORITHM")
+	passwordBcryptCost        = DotEnvHelper.GetEnvVariable("PASSWORD_BCRYPT_COST")
+	passwordArgon2MemoryKib   = DotEnvHelper.GetEnvVariable("PASSWORD_ARGON2_MEMORY_KIB")
+	passwordArgon2Iterations  = DotEnvHelper.GetEnvVariable("PASSWORD_ARGON2_ITERATIONS")
+	passwordArgon2Parallelism = DotEnvHelper.GetEnvVariable("PASSWORD_ARGON2_PARALLELISM")
+
+	errPasswordHashInvalid = errors.New("password hash is invalid")
+)
 
 type IPasswordHelper interface {
 	HashPassword(password string) (string, error)
 	VerifyPassword(hashedPassword, userPassword string) error
+	// NeedsRehash reports whether the hash was made with another algorithm or other parameters than the configured ones
+	NeedsRehash(hashedPassword string) bool
 }
 
-type passwordHelperStruct struct{}
+// PasswordHashConfig selects the algorithm new hashes are made with, existing hashes of either algorithm still verify
+type PasswordHashConfig struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2MemoryKib   uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+type passwordHelperStruct struct {
+	config PasswordHashConfig
+}
 
 func NewPasswordHelper() IPasswordHelper {
-	return &passwordHelperStruct{}
+	algorithm := strings.ToLower(strings.TrimSpace(passwordHashAlgorithm))
+
+	if algorithm != Argon2idAlgorithm {
+		algorithm = BcryptAlgorithm
+	}
+
+	return NewPasswordHelperWithConfig(PasswordHashConfig{
+		Algorithm: algorithm,
+		// Bcrypt uses a cost parameter that specify the number of cycles to use in the algorithm.
+		// Increasing this number the algorithm will spend more time to generate the hash output.
+		BcryptCost: getIntEnvVariable(passwordBcryptCost, 14),
+		// OWASP recommends at least 19 MiB, 2 iterations and 1 degree of parallelism
+		Argon2MemoryKib:   uint32(getIntEnvVariable(passwordArgon2MemoryKib, 64*1024)),
+		Argon2Iterations:  uint32(getIntEnvVariable(passwordArgon2Iterations, 3)),
+		Argon2Parallelism: uint8(getIntEnvVariable(passwordArgon2Parallelism, 2)),
+	})
+}
+
+func NewPasswordHelperWithConfig(config PasswordHashConfig) IPasswordHelper {
+	return &passwordHelperStruct{config: config}
 }
 
 func (p *passwordHelperStruct) HashPassword(password string) (string, error) {
-	// 14 >>> cost
-	// Bcrypt uses a cost parameter that specify the number of cycles to use in the algorithm.
-	// Increasing this number the algorithm will spend more time to generate the hash output.
-	hashedPasswordBytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	if p.config.Algorithm == Argon2idAlgorithm {
+		salt := make([]byte, argon2idSaltLength)
+
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		key := argon2.IDKey([]byte(password), salt, p.config.Argon2Iterations, p.config.Argon2MemoryKib, p.config.Argon2Parallelism, argon2idKeyLength)
+
+		// PHC string format, the same as the reference implementation
+		return fmt.Sprintf(
+			"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, p.config.Argon2MemoryKib, p.config.Argon2Iterations, p.config.Argon2Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	}
+
+	hashedPasswordBytes, err := bcrypt.GenerateFromPassword([]byte(password), p.config.BcryptCost)
 
 	return string(hashedPasswordBytes), err
 }
 
 func (p *passwordHelperStruct) VerifyPassword(hashedPassword, userPassword string) error {
+	if strings.HasPrefix(hashedPassword, "$argon2id$") {
+		params, salt, key, err := decodeArgon2idHash(hashedPassword)
+
+		if err != nil {
+			return err
+		}
+
+		otherKey := argon2.IDKey([]byte(userPassword), salt, params.Argon2Iterations, params.Argon2MemoryKib, params.Argon2Parallelism, uint32(len(key)))
+
+		if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+			return errors.New("password does not match")
+		}
+
+		return nil
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(userPassword))
 
 	return err
 }
+
+func (p *passwordHelperStruct) NeedsRehash(hashedPassword string) bool {
+	if strings.HasPrefix(hashedPassword, "$argon2id$") {
+		if p.config.Algorithm != Argon2idAlgorithm {
+			return true
+		}
+
+		params, _, _, err := decodeArgon2idHash(hashedPassword)
+
+		return err != nil ||
+			params.Argon2MemoryKib != p.config.Argon2MemoryKib ||
+			params.Argon2Iterations != p.config.Argon2Iterations ||
+			params.Argon2Parallelism != p.config.Argon2Parallelism
+	}
+
+	if p.config.Algorithm != BcryptAlgorithm {
+		return true
+	}
+
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+
+	return err != nil || cost != p.config.BcryptCost
+}
+
+func decodeArgon2idHash(hashedPassword string) (PasswordHashConfig, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=65536,t=3,p=2", salt, key
+	parts := strings.Split(hashedPassword, "$")
+	params := PasswordHashConfig{Algorithm: Argon2idAlgorithm}
+
+	if len(parts) != 6 {
+		return params, nil, nil, errPasswordHashInvalid
+	}
+
+	var version int
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errPasswordHashInvalid
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Argon2MemoryKib, &params.Argon2Iterations, &params.Argon2Parallelism); err != nil {
+		return params, nil, nil, errPasswordHashInvalid
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+
+	if err != nil {
+		return params, nil, nil, errPasswordHashInvalid
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+
+	if err != nil || len(key) < 1 {
+		return params, nil, nil, errPasswordHashInvalid
+	}
+
+	return params, salt, key, nil
+}
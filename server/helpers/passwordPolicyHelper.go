@@ -0,0 +1,229 @@
+package helpers
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"nft-raffle/logger"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// sha1 hex prefix length the breached hashes are bucketed by, the same split as the k-anonymity range api
+	breachedHashPrefixLength int = 5
+)
+
+var (
+	PasswordPolicyHelper IPasswordPolicyHelper = NewPasswordPolicyHelper()
+
+	passwordMinLength            = DotEnvHelper.GetEnvVariable("PASSWORD_MIN_LENGTH")
+	passwordMaxLength            = DotEnvHelper.GetEnvVariable("PASSWORD_MAX_LENGTH")
+	passwordMinCharacterClasses  = DotEnvHelper.GetEnvVariable("PASSWORD_MIN_CHARACTER_CLASSES")
+	passwordBreachedHashesFile   = DotEnvHelper.GetEnvVariable("PASSWORD_BREACHED_HASHES_FILE")
+	passwordAllowPersonalDetails = DotEnvHelper.GetEnvVariable("PASSWORD_ALLOW_PERSONAL_DETAILS") == "true"
+)
+
+type PasswordPolicyConfig struct {
+	MinLength            int
+	MaxLength            int
+	MinCharacterClasses  int
+	AllowPersonalDetails bool
+}
+
+// PasswordPolicyViolation is a field-level validation error, Code is stable for clients to translate
+type PasswordPolicyViolation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type IPasswordPolicyHelper interface {
+	// Validate checks the password of a user with the given personal details, nil means it is acceptable
+	Validate(password, email, firstName, lastName string) []PasswordPolicyViolation
+}
+
+type passwordPolicyHelperStruct struct {
+	config PasswordPolicyConfig
+	// sha1 prefix -> set of sha1 suffixes
+	breachedHashes map[string]map[string]struct{}
+}
+
+func NewPasswordPolicyHelper() IPasswordPolicyHelper {
+	config := PasswordPolicyConfig{
+		MinLength:            getIntEnvVariable(passwordMinLength, 10),
+		MaxLength:            getIntEnvVariable(passwordMaxLength, 128),
+		MinCharacterClasses:  getIntEnvVariable(passwordMinCharacterClasses, 2),
+		AllowPersonalDetails: passwordAllowPersonalDetails,
+	}
+
+	breachedHashes := map[string]map[string]struct{}{}
+
+	if passwordBreachedHashesFile != "" {
+		file, err := os.Open(passwordBreachedHashesFile)
+
+		if err != nil {
+			logger.Logger.Fatal("Error opening breached password hashes file in passwordPolicyHelper.go " + err.Error())
+		}
+
+		defer file.Close()
+
+		breachedHashes, err = LoadBreachedPasswordHashes(file)
+
+		if err != nil {
+			logger.Logger.Fatal("Error loading breached password hashes in passwordPolicyHelper.go " + err.Error())
+		}
+	}
+
+	return NewPasswordPolicyHelperWithConfig(config, breachedHashes)
+}
+
+func NewPasswordPolicyHelperWithConfig(config PasswordPolicyConfig, breachedHashes map[string]map[string]struct{}) IPasswordPolicyHelper {
+	return &passwordPolicyHelperStruct{config: config, breachedHashes: breachedHashes}
+}
+
+// LoadBreachedPasswordHashes reads one uppercase or lowercase sha1 hex per line, an optional ":count" suffix is ignored,
+// which is the format of the downloadable breached password lists.
+func LoadBreachedPasswordHashes(r io.Reader) (map[string]map[string]struct{}, error) {
+	breachedHashes := map[string]map[string]struct{}{}
+	scanner := bufio.NewScanner(r)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash := strings.ToUpper(strings.SplitN(line, ":", 2)[0])
+
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("line %d is not a sha1 hash", lineNumber)
+		}
+
+		prefix, suffix := hash[:breachedHashPrefixLength], hash[breachedHashPrefixLength:]
+
+		if breachedHashes[prefix] == nil {
+			breachedHashes[prefix] = map[string]struct{}{}
+		}
+
+		breachedHashes[prefix][suffix] = struct{}{}
+	}
+
+	return breachedHashes, scanner.Err()
+}
+
+func (p *passwordPolicyHelperStruct) Validate(password, email, firstName, lastName string) []PasswordPolicyViolation {
+	var violations []PasswordPolicyViolation
+
+	length := utf8.RuneCountInString(password)
+
+	if length < p.config.MinLength {
+		violations = append(violations, PasswordPolicyViolation{
+			Field:   "password",
+			Code:    "too_short",
+			Message: fmt.Sprintf("password must be at least %d characters long", p.config.MinLength),
+		})
+	}
+
+	if length > p.config.MaxLength {
+		violations = append(violations, PasswordPolicyViolation{
+			Field:   "password",
+			Code:    "too_long",
+			Message: fmt.Sprintf("password must be at most %d characters long", p.config.MaxLength),
+		})
+	}
+
+	if classes := countCharacterClasses(password); classes < p.config.MinCharacterClasses {
+		violations = append(violations, PasswordPolicyViolation{
+			Field: "password",
+			Code:  "too_few_character_classes",
+			Message: fmt.Sprintf(
+				"password must contain at least %d of lowercase letters, uppercase letters, digits and symbols",
+				p.config.MinCharacterClasses,
+			),
+		})
+	}
+
+	if !p.config.AllowPersonalDetails && containsPersonalDetails(password, email, firstName, lastName) {
+		violations = append(violations, PasswordPolicyViolation{
+			Field:   "password",
+			Code:    "contains_personal_details",
+			Message: "password must not contain your name or email",
+		})
+	}
+
+	if p.isBreached(password) {
+		violations = append(violations, PasswordPolicyViolation{
+			Field:   "password",
+			Code:    "breached",
+			Message: "password has appeared in a data breach, please choose another one",
+		})
+	}
+
+	return violations
+}
+
+func (p *passwordPolicyHelperStruct) isBreached(password string) bool {
+	if len(p.breachedHashes) < 1 {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	_, ok := p.breachedHashes[hash[:breachedHashPrefixLength]][hash[breachedHashPrefixLength:]]
+
+	return ok
+}
+
+func countCharacterClasses(password string) int {
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+
+	classes := 0
+
+	for _, has := range []bool{hasLower, hasUpper, hasDigit, hasSymbol} {
+		if has {
+			classes++
+		}
+	}
+
+	return classes
+}
+
+// containsPersonalDetails ignores parts shorter than 3 characters, they match too many passwords by accident
+func containsPersonalDetails(password, email, firstName, lastName string) bool {
+	lowerPassword := strings.ToLower(password)
+	parts := []string{firstName, lastName}
+
+	if at := strings.LastIndex(email, "@"); at > 0 {
+		parts = append(parts, email[:at])
+	}
+
+	for _, part := range parts {
+		part = strings.ToLower(strings.TrimSpace(part))
+
+		if utf8.RuneCountInString(part) >= 3 && strings.Contains(lowerPassword, part) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	phoneVerification string = "phone_verification"
+)
+
+var (
+	PhoneVerificationHelper IPhoneVerificationHelper = NewPhoneVerificationHelper()
+)
+
+type phoneVerificationCode struct {
+	Phone     string `json:"phone"`
+	Code_hash string `json:"code_hash"`
+}
+
+// IPhoneVerificationHelper keeps the pending sms code of a user in redis, only its hash is stored and it expires by itself.
+// The code is bound to the number it was sent to, so changing the phone afterwards cannot verify the new number.
+type IPhoneVerificationHelper interface {
+	SaveCode(userId, phone, code string, expiration time.Duration) error
+	// VerifyCode returns the phone number the code was sent to, ok is false for a wrong or expired code
+	VerifyCode(userId, code string) (phone string, ok bool, err error)
+	DeleteCode(userId string) error
+}
+
+type phoneVerificationHelperStruct struct{}
+
+func NewPhoneVerificationHelper() IPhoneVerificationHelper {
+	return &phoneVerificationHelperStruct{}
+}
+
+func (p *phoneVerificationHelperStruct) SaveCode(userId, phone, code string, expiration time.Duration) error {
+	value, err := json.Marshal(phoneVerificationCode{Phone: phone, Code_hash: hashPhoneVerificationCode(code)})
+
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	return redisClient.Set(ctx, phoneVerificationKey(userId), value, expiration).Err()
+}
+
+func (p *phoneVerificationHelperStruct) VerifyCode(userId, code string) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	value, err := redisClient.Get(ctx, phoneVerificationKey(userId)).Bytes()
+
+	if err == redis.Nil {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	var pending phoneVerificationCode
+
+	if err := json.Unmarshal(value, &pending); err != nil {
+		return "", false, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(pending.Code_hash), []byte(hashPhoneVerificationCode(code))) != 1 {
+		return "", false, nil
+	}
+
+	// single use, a concurrent request that already consumed the code wins
+	deleted, err := redisClient.Del(ctx, phoneVerificationKey(userId)).Result()
+
+	if err != nil {
+		return "", false, err
+	}
+
+	return pending.Phone, deleted > 0, nil
+}
+
+func (p *phoneVerificationHelperStruct) DeleteCode(userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	return redisClient.Del(ctx, phoneVerificationKey(userId)).Err()
+}
+
+func phoneVerificationKey(userId string) string {
+	return fmt.Sprintf("%s:%s:%s", phoneVerification, "user_id", userId)
+}
+
+func hashPhoneVerificationCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,96 @@
+package helpers
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/sendgrid/sendgrid-go/helpers/eventwebhook"
+)
+
+const (
+	SendGridEventSignatureHeader string = eventwebhook.VerificationHTTPHeader
+	SendGridEventTimestampHeader string = eventwebhook.TimestampHTTPHeader
+
+	// a signed batch is only accepted this close to its timestamp, a captured request cannot be replayed later
+	sendGridEventTimestampTolerance time.Duration = 10 * time.Minute
+)
+
+var (
+	SendGridEventWebhookHelper ISendGridEventWebhookHelper = NewSendGridEventWebhookHelper(DotEnvHelper.GetEnvVariable("SENDGRID_EVENT_WEBHOOK_PUBLIC_KEY"))
+
+	ErrInvalidEventWebhookSignature = errors.New("event webhook signature is invalid")
+	ErrEventWebhookNotConfigured    = errors.New("event webhook verification key is not configured")
+	ErrEventWebhookTimestampExpired = errors.New("event webhook timestamp is outside the accepted window")
+)
+
+type ISendGridEventWebhookHelper interface {
+	VerifySignature(payload []byte, signature string, timestamp string) error
+}
+
+type sendGridEventWebhookHelperStruct struct {
+	publicKey *ecdsa.PublicKey
+	keyErr    error
+}
+
+// NewSendGridEventWebhookHelper takes the base64 verification key shown in the sendgrid mail settings,
+// without a valid key every batch is rejected instead of accepting unsigned events
+func NewSendGridEventWebhookHelper(base64PublicKey string) ISendGridEventWebhookHelper {
+	if base64PublicKey == "" {
+		return &sendGridEventWebhookHelperStruct{keyErr: ErrEventWebhookNotConfigured}
+	}
+
+	der, err := base64.StdEncoding.DecodeString(base64PublicKey)
+
+	if err != nil {
+		return &sendGridEventWebhookHelperStruct{keyErr: err}
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(der)
+
+	if err != nil {
+		return &sendGridEventWebhookHelperStruct{keyErr: err}
+	}
+
+	ecdsaPublicKey, ok := publicKey.(*ecdsa.PublicKey)
+
+	if !ok {
+		return &sendGridEventWebhookHelperStruct{keyErr: errors.New("event webhook verification key is not an ecdsa key")}
+	}
+
+	return &sendGridEventWebhookHelperStruct{publicKey: ecdsaPublicKey}
+}
+
+// VerifySignature checks the signature over the timestamp header followed by the raw body, then the age of the timestamp
+func (s *sendGridEventWebhookHelperStruct) VerifySignature(payload []byte, signature string, timestamp string) error {
+	if s.keyErr != nil {
+		return s.keyErr
+	}
+
+	if signature == "" || timestamp == "" {
+		return ErrInvalidEventWebhookSignature
+	}
+
+	isValid, err := eventwebhook.VerifySignature(s.publicKey, payload, signature, timestamp)
+
+	if err != nil || !isValid {
+		return ErrInvalidEventWebhookSignature
+	}
+
+	unixTimestamp, err := strconv.ParseInt(timestamp, 10, 64)
+
+	if err != nil {
+		return ErrInvalidEventWebhookSignature
+	}
+
+	age := time.Since(time.Unix(unixTimestamp, 0))
+
+	if age > sendGridEventTimestampTolerance || age < -sendGridEventTimestampTolerance {
+		return ErrEventWebhookTimestampExpired
+	}
+
+	return nil
+}
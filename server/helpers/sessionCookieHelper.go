@@ -0,0 +1,159 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AccessTokenCookie  string = "access_token"
+	RefreshTokenCookie string = "refresh_token"
+	CsrfTokenCookie    string = "csrf_token"
+	CsrfTokenHeader    string = "X-CSRF-Token"
+
+	// the web client opts into cookie sessions per request with this header or the session_mode query param
+	SessionModeHeader string = "X-Session-Mode"
+	SessionModeQuery  string = "session_mode"
+	CookieSessionMode string = "cookie"
+
+	// set on the gin context by flows that cannot carry the header, such as the oidc callback
+	IsCookieSessionKey string = "is_cookie_session"
+
+	refreshTokenCookiePath string = "/api/auth/refresh-token"
+)
+
+var (
+	SessionCookieHelper ISessionCookieHelper = NewSessionCookieHelper()
+
+	sessionCookieDomain   = DotEnvHelper.GetEnvVariable("SESSION_COOKIE_DOMAIN")
+	sessionCookieInsecure = DotEnvHelper.GetEnvVariable("SESSION_COOKIE_INSECURE") == "true"
+	sessionCookieSameSite = DotEnvHelper.GetEnvVariable("SESSION_COOKIE_SAMESITE")
+)
+
+// ISessionCookieHelper keeps the tokens of the web client in HttpOnly cookies instead of javascript storage,
+// requests authenticated by cookie must echo the csrf cookie in the X-CSRF-Token header (double-submit).
+type ISessionCookieHelper interface {
+	IsCookieMode(c *gin.Context) bool
+	SetSessionCookies(c *gin.Context, accessToken, refreshToken string) error
+	ClearSessionCookies(c *gin.Context)
+	GetAccessToken(c *gin.Context) string
+	GetRefreshToken(c *gin.Context) string
+	ValidateCsrfToken(c *gin.Context) bool
+	SetFlowCookie(c *gin.Context, name, value, path string, maxAge int)
+	ClearFlowCookie(c *gin.Context, name, path string)
+}
+
+type sessionCookieHelperStruct struct {
+	domain   string
+	secure   bool
+	sameSite http.SameSite
+}
+
+func NewSessionCookieHelper() ISessionCookieHelper {
+	sameSite := http.SameSiteLaxMode
+
+	if strings.EqualFold(sessionCookieSameSite, "strict") {
+		sameSite = http.SameSiteStrictMode
+	}
+
+	return &sessionCookieHelperStruct{
+		domain:   sessionCookieDomain,
+		secure:   !sessionCookieInsecure,
+		sameSite: sameSite,
+	}
+}
+
+func (s *sessionCookieHelperStruct) IsCookieMode(c *gin.Context) bool {
+	return c.GetBool(IsCookieSessionKey) ||
+		strings.EqualFold(c.GetHeader(SessionModeHeader), CookieSessionMode) ||
+		strings.EqualFold(c.Query(SessionModeQuery), CookieSessionMode)
+}
+
+func (s *sessionCookieHelperStruct) SetSessionCookies(c *gin.Context, accessToken, refreshToken string) error {
+	csrfToken := make([]byte, 32)
+
+	if _, err := rand.Read(csrfToken); err != nil {
+		return err
+	}
+
+	accessTokenMaxAge := getIntEnvVariable(accessTokenTTL, 1) * int(time.Hour/time.Second)
+	refreshTokenMaxAge := getIntEnvVariable(refreshTokenTTL, 24) * int(time.Hour/time.Second)
+
+	s.setCookie(c, AccessTokenCookie, accessToken, "/api", accessTokenMaxAge, true)
+	// only the refresh endpoint ever receives the refresh token
+	s.setCookie(c, RefreshTokenCookie, refreshToken, refreshTokenCookiePath, refreshTokenMaxAge, true)
+	// readable by the client so it can be echoed in the header
+	s.setCookie(c, CsrfTokenCookie, base64.RawURLEncoding.EncodeToString(csrfToken), "/", refreshTokenMaxAge, false)
+
+	return nil
+}
+
+func (s *sessionCookieHelperStruct) ClearSessionCookies(c *gin.Context) {
+	s.setCookie(c, AccessTokenCookie, "", "/api", -1, true)
+	s.setCookie(c, RefreshTokenCookie, "", refreshTokenCookiePath, -1, true)
+	s.setCookie(c, CsrfTokenCookie, "", "/", -1, false)
+}
+
+func (s *sessionCookieHelperStruct) GetAccessToken(c *gin.Context) string {
+	accessToken, _ := c.Cookie(AccessTokenCookie)
+	return accessToken
+}
+
+func (s *sessionCookieHelperStruct) GetRefreshToken(c *gin.Context) string {
+	refreshToken, _ := c.Cookie(RefreshTokenCookie)
+	return refreshToken
+}
+
+// ValidateCsrfToken lets safe methods through, a cross-site page can neither read the cookie nor set the header
+func (s *sessionCookieHelperStruct) ValidateCsrfToken(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	csrfCookie, err := c.Cookie(CsrfTokenCookie)
+
+	if err != nil || csrfCookie == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(csrfCookie), []byte(c.GetHeader(CsrfTokenHeader))) == 1
+}
+
+// SetFlowCookie binds a login flow to the browser that started it, such as the oidc state or the magic link nonce.
+// The flow comes back through a cross-site top level navigation, which a strict cookie would not survive.
+func (s *sessionCookieHelperStruct) SetFlowCookie(c *gin.Context, name, value, path string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   s.domain,
+		MaxAge:   maxAge,
+		Secure:   s.secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (s *sessionCookieHelperStruct) ClearFlowCookie(c *gin.Context, name, path string) {
+	s.SetFlowCookie(c, name, "", path, -1)
+}
+
+func (s *sessionCookieHelperStruct) setCookie(c *gin.Context, name, value, path string, maxAge int, httpOnly bool) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   s.domain,
+		MaxAge:   maxAge,
+		Secure:   s.secure,
+		HttpOnly: httpOnly,
+		SameSite: s.sameSite,
+	})
+}
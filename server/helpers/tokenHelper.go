@@ -33,6 +33,11 @@ var (
 	refreshTokenSecretKey = DotEnvHelper.GetEnvVariable("MY_REFRESH_TOKEN_SECRET_KEY")
 	accessTokenTTL        = DotEnvHelper.GetEnvVariable("ACCESS_TOKEN_TTL")
 	refreshTokenTTL       = DotEnvHelper.GetEnvVariable("REFRESH_TOKEN_TTL")
+	mfaTokenSecretKey     = DotEnvHelper.GetEnvVariable("MY_MFA_TOKEN_SECRET_KEY")
+	mfaTokenTTLMinutes    = DotEnvHelper.GetEnvVariable("MFA_TOKEN_TTL_MINUTES")
+	jwtIssuer             = DotEnvHelper.GetEnvVariable("JWT_ISSUER")
+	// keep accepting HS256 access tokens issued before switching to the key set
+	jwtAcceptLegacyHS256 = DotEnvHelper.GetEnvVariable("JWT_ACCEPT_LEGACY_HS256") == "true"
 )
 
 type ITokenHelper interface {
@@ -45,6 +50,8 @@ type ITokenHelper interface {
 	SetBlacklistAccessAndRefreshTokenUserId(userId string) error
 	GetBlacklistAccessTokenUserId(userId string) (int64, error)
 	GetBlacklistRefreshTokenUserId(userId string) (int64, error)
+	GenerateMfaToken(uid string, isEnrollment bool) (signedToken string, err error)
+	ValidateMfaToken(signedToken string) (claims *MfaSignedDetails, err error)
 }
 
 type tokenHelperStruct struct{}
@@ -59,6 +66,13 @@ type SignedDetails struct {
 	jwt.StandardClaims
 }
 
+// short-lived token proving the password step of login has passed, exchanged for real tokens once the TOTP code is verified
+type MfaSignedDetails struct {
+	Uid           string
+	Is_enrollment bool
+	jwt.StandardClaims
+}
+
 func NewTokenHelper() ITokenHelper {
 	return &tokenHelperStruct{}
 }
@@ -86,6 +100,7 @@ func (t *tokenHelperStruct) GenerateAllTokens(email, firstName, lastName, uid, u
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(accessTokenTTLHoursInt)).Unix(),
 			IssuedAt:  time.Now().Local().Unix(),
+			Issuer:    jwtIssuer,
 			Subject:   uid,
 		},
 	}
@@ -99,7 +114,12 @@ func (t *tokenHelperStruct) GenerateAllTokens(email, firstName, lastName, uid, u
 		},
 	}
 
-	signedToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(accessTokenSecretKey))
+	// access tokens are verified by other services, sign them with the key set when one is configured
+	if keySet := JwtKeySetHelper.GetKeySet(); keySet != nil {
+		signedToken, err = keySet.SignedString(claims)
+	} else {
+		signedToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(accessTokenSecretKey))
+	}
 
 	if err != nil {
 		return
@@ -158,6 +178,16 @@ func (t *tokenHelperStruct) ValidateAccessToken(signedToken string) (claims *Sig
 		signedToken,
 		&SignedDetails{},
 		func(token *jwt.Token) (interface{}, error) {
+			_, isHmac := token.Method.(*jwt.SigningMethodHMAC)
+
+			if keySet := JwtKeySetHelper.GetKeySet(); keySet != nil && !(isHmac && jwtAcceptLegacyHS256) {
+				return keySet.Keyfunc(token)
+			}
+
+			if !isHmac {
+				return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+
 			return []byte(accessTokenSecretKey), nil
 		},
 	)
@@ -184,6 +214,10 @@ func (t *tokenHelperStruct) ValidateRefreshToken(signedToken string) (claims *Si
 		signedToken,
 		&SignedDetails{},
 		func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+
 			return []byte(refreshTokenSecretKey), nil
 		},
 	)
@@ -330,3 +364,65 @@ func (t *tokenHelperStruct) GetBlacklistRefreshTokenUserId(userId string) (int64
 
 	return unixTime, nil
 }
+
+func (t *tokenHelperStruct) GenerateMfaToken(uid string, isEnrollment bool) (signedToken string, err error) {
+	if mfaTokenSecretKey == "" {
+		return "", errors.New("mfa token secret key is not configured")
+	}
+
+	mfaTokenTTLMinutesInt := 5
+
+	if mfaTokenTTLMinutes != "" {
+		mfaTokenTTLMinutesInt, err = strconv.Atoi(mfaTokenTTLMinutes)
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	claims := &MfaSignedDetails{
+		Uid:           uid,
+		Is_enrollment: isEnrollment,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Local().Add(time.Minute * time.Duration(mfaTokenTTLMinutesInt)).Unix(),
+			IssuedAt:  time.Now().Local().Unix(),
+			Subject:   uid,
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(mfaTokenSecretKey))
+}
+
+func (t *tokenHelperStruct) ValidateMfaToken(signedToken string) (claims *MfaSignedDetails, err error) {
+	if mfaTokenSecretKey == "" {
+		return nil, errors.New("mfa token secret key is not configured")
+	}
+
+	token, err := jwt.ParseWithClaims(
+		signedToken,
+		&MfaSignedDetails{},
+		func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+
+			return []byte(mfaTokenSecretKey), nil
+		},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*MfaSignedDetails)
+
+	if !ok {
+		return nil, errors.New("invalid mfa token")
+	}
+
+	if claims.ExpiresAt < time.Now().Local().Unix() {
+		return nil, errors.New("mfa token has expired")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	disabledUser string = "disabled_user"
+)
+
+var (
+	UserStatusHelper IUserStatusHelper = NewUserStatusHelper()
+)
+
+// IUserStatusHelper mirrors the is_disabled flag of the user document into redis,
+// so the auth middleware can reject disabled accounts without a database read per request.
+// The flag only has to outlive the tokens issued before the account was disabled, no token is issued afterwards.
+type IUserStatusHelper interface {
+	SetUserDisabled(userId string, isDisabled bool) error
+	IsUserDisabled(userId string) (bool, error)
+}
+
+type userStatusHelperStruct struct{}
+
+func NewUserStatusHelper() IUserStatusHelper {
+	return &userStatusHelperStruct{}
+}
+
+func (u *userStatusHelperStruct) SetUserDisabled(userId string, isDisabled bool) error {
+	key := fmt.Sprintf("%s:%s:%s", disabledUser, "user_id", userId)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if !isDisabled {
+		return redisClient.Del(ctx, key).Err()
+	}
+
+	refreshTokenTTLHoursInt, err := strconv.Atoi(refreshTokenTTL)
+
+	if err != nil {
+		return err
+	}
+
+	return redisClient.Set(ctx, key, time.Now().Unix(), time.Hour*time.Duration(refreshTokenTTLHoursInt)).Err()
+}
+
+func (u *userStatusHelperStruct) IsUserDisabled(userId string) (bool, error) {
+	key := fmt.Sprintf("%s:%s:%s", disabledUser, "user_id", userId)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	count, err := redisClient.Exists(ctx, key).Result()
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
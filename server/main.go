@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"math/rand"
 	"nft-raffle/helpers"
+	"nft-raffle/logger"
 	"nft-raffle/routes"
+	"nft-raffle/services"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -27,10 +31,25 @@ func main() {
 		port = "8000"
 	}
 
+	indexCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+
+	// mail codes are removed by mongo once expired
+	if err := services.MailService.EnsureMailCodeIndexes(indexCtx); err != nil {
+		logger.Logger.Error(fmt.Sprintf("unable to create the mail code indexes: %v", err.Error()))
+	}
+
+	if err := services.MailService.EnsureMailOutboxIndexes(indexCtx); err != nil {
+		logger.Logger.Error(fmt.Sprintf("unable to create the mail outbox indexes: %v", err.Error()))
+	}
+
+	cancel()
+
 	router := gin.New()
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 
+	routes.WellKnownRoutes(&router.RouterGroup)
+
 	routerGroup := router.Group("/api")
 	routes.AddRoutes(routerGroup)
 
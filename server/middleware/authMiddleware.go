@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"nft-raffle/enums"
 	"nft-raffle/helpers"
 	"nft-raffle/logger"
 	"strings"
@@ -12,11 +15,24 @@ import (
 var (
 	AuthMiddleware IAuthMiddleware = NewAuthMiddleware()
 
-	tokenHelper helpers.ITokenHelper = helpers.TokenHelper
+	tokenHelper         helpers.ITokenHelper         = helpers.TokenHelper
+	userStatusHelper    helpers.IUserStatusHelper    = helpers.UserStatusHelper
+	sessionCookieHelper helpers.ISessionCookieHelper = helpers.SessionCookieHelper
+	apiKeyHelper        helpers.IApiKeyHelper        = helpers.ApiKeyHelper
+)
+
+const (
+	ApiKeyHeader string = "X-API-Key"
+
+	// set by AllowApiKey, routes without it reject api keys
+	apiKeyScopeKey string = "api_key_scope"
 )
 
 type IAuthMiddleware interface {
 	Authenticate(c *gin.Context)
+	AllowApiKey(scope enums.ApiKeyScope) gin.HandlerFunc
+	AuthenticateMfaEnrollment(c *gin.Context)
+	AuthorizeAdmin(c *gin.Context)
 }
 
 type authMiddlewareStruct struct{}
@@ -26,8 +42,27 @@ func NewAuthMiddleware() IAuthMiddleware {
 }
 
 func (a *authMiddlewareStruct) Authenticate(c *gin.Context) {
+	if apiKey := c.GetHeader(ApiKeyHeader); apiKey != "" {
+		a.authenticateApiKey(c, apiKey)
+		return
+	}
+
 	authorizationHeader := strings.Split(c.Request.Header.Get("Authorization"), " ")
 
+	// cookie session of the web client, only used when no Authorization header is present
+	if len(authorizationHeader) < 2 {
+		if cookieAccessToken := sessionCookieHelper.GetAccessToken(c); cookieAccessToken != "" {
+			if !sessionCookieHelper.ValidateCsrfToken(c) {
+				logger.Logger.Error("csrf token is missing or does not match")
+				c.JSON(http.StatusForbidden, gin.H{"error": "csrf token is missing or does not match"})
+				c.Abort()
+				return
+			}
+
+			authorizationHeader = []string{"Bearer", cookieAccessToken}
+		}
+	}
+
 	if len(authorizationHeader) < 2 {
 		logger.Logger.Error("no authorization header provided")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "No authorization header provided"})
@@ -74,6 +109,22 @@ func (a *authMiddlewareStruct) Authenticate(c *gin.Context) {
 		}
 	}
 
+	isDisabled, err := userStatusHelper.IsUserDisabled(claims.Uid)
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	if isDisabled {
+		logger.Logger.Error("account is disabled")
+		c.JSON(http.StatusForbidden, gin.H{"error": "account is disabled"})
+		c.Abort()
+		return
+	}
+
 	c.Set("uid", claims.Uid)
 	c.Set("email", claims.Email)
 	c.Set("first_name", claims.First_name)
@@ -83,3 +134,104 @@ func (a *authMiddlewareStruct) Authenticate(c *gin.Context) {
 	c.Set("subject", claims.Subject)
 	c.Next()
 }
+
+// AllowApiKey must run before Authenticate, it lets api keys holding the scope through on the route
+func (a *authMiddlewareStruct) AllowApiKey(scope enums.ApiKeyScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(apiKeyScopeKey, scope.String())
+		c.Next()
+	}
+}
+
+func (a *authMiddlewareStruct) authenticateApiKey(c *gin.Context, apiKey string) {
+	requiredScope := c.GetString(apiKeyScopeKey)
+
+	if requiredScope == "" {
+		logger.Logger.Error("api key used on an endpoint that does not accept api keys")
+		c.JSON(http.StatusForbidden, gin.H{"error": "api keys are not accepted on this endpoint"})
+		c.Abort()
+		return
+	}
+
+	details, err := apiKeyHelper.ValidateApiKey(apiKey)
+
+	if errors.Is(err, helpers.ErrInvalidApiKey) {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	} else if err != nil {
+		logger.Logger.Error(err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	hasScope := false
+
+	for _, scope := range details.Scopes {
+		if scope == requiredScope {
+			hasScope = true
+			break
+		}
+	}
+
+	if !hasScope {
+		logger.Logger.Error(fmt.Sprintf("api key %s is missing the %s scope", details.Api_key_id, requiredScope))
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("api key is missing the %s scope", requiredScope)})
+		c.Abort()
+		return
+	}
+
+	c.Set("uid", details.Uid)
+	c.Set("email", details.Email)
+	c.Set("first_name", details.First_name)
+	c.Set("last_name", details.Last_name)
+	c.Set("user_role", details.User_role)
+	c.Set("api_key_id", details.Api_key_id)
+	c.Next()
+}
+
+// AuthenticateMfaEnrollment accepts either a normal access token or the enrollment mfa token
+// returned by login to a user who is forced into 2FA but has not enrolled yet.
+func (a *authMiddlewareStruct) AuthenticateMfaEnrollment(c *gin.Context) {
+	authorizationHeader := strings.Split(c.Request.Header.Get("Authorization"), " ")
+
+	if len(authorizationHeader) < 2 || authorizationHeader[1] == "" {
+		logger.Logger.Error("no authorization header provided")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No authorization header provided"})
+		c.Abort()
+		return
+	}
+
+	mfaClaims, err := tokenHelper.ValidateMfaToken(authorizationHeader[1])
+
+	if err != nil {
+		// not an mfa token, fall back to the access token
+		a.Authenticate(c)
+		return
+	}
+
+	if !mfaClaims.Is_enrollment {
+		logger.Logger.Error("mfa token is not an enrollment token")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "mfa token is not an enrollment token"})
+		c.Abort()
+		return
+	}
+
+	c.Set("uid", mfaClaims.Uid)
+	c.Set("is_mfa_enrollment", true)
+	c.Next()
+}
+
+// AuthorizeAdmin must run after Authenticate
+func (a *authMiddlewareStruct) AuthorizeAdmin(c *gin.Context) {
+	if c.GetString("user_role") != enums.Admin.String() {
+		logger.Logger.Error("user is not an admin")
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin role is required"})
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
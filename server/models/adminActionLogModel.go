@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AdminActionLog struct {
+	ID                  primitive.ObjectID `bson:"_id"`
+	Admin_action_log_id string             `json:"admin_action_log_id" bson:"admin_action_log_id"`
+	Admin_id            string             `json:"admin_id" bson:"admin_id"`
+	Target_user_id      string             `json:"target_user_id" bson:"target_user_id"`
+	Action              string             `json:"action" bson:"action"`
+	Details             map[string]string  `json:"details,omitempty" bson:"details,omitempty"`
+	Ip_address          string             `json:"ip_address" bson:"ip_address"`
+	Created_at          time.Time          `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ApiKey only keeps the sha256 of the key, the plain key is returned once when it is created
+type ApiKey struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Api_key_id   string             `json:"api_key_id" bson:"api_key_id"`
+	User_id      string             `json:"user_id" bson:"user_id"`
+	Name         string             `json:"name" bson:"name"`
+	Prefix       string             `json:"prefix" bson:"prefix"`
+	Key_hash     string             `json:"-" bson:"key_hash"`
+	Scopes       []string           `json:"scopes" bson:"scopes"`
+	Expires_at   time.Time          `json:"expires_at" bson:"expires_at"`
+	Last_used_at *time.Time         `json:"last_used_at" bson:"last_used_at"`
+	Created_at   time.Time          `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExpenseDigest is the weekly digest claim the cron module writes, the server only reads it for the data export
+type ExpenseDigest struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Expense_digest_id string             `json:"expense_digest_id" bson:"expense_digest_id"`
+	User_id           string             `json:"user_id" bson:"user_id"`
+	Week_start        time.Time          `json:"week_start" bson:"week_start"`
+	Is_sent           bool               `json:"is_sent" bson:"is_sent"`
+	Created_at        time.Time          `json:"created_at" bson:"created_at"`
+}
@@ -10,9 +10,25 @@ type Mail struct {
 	ID         primitive.ObjectID `bson:"_id"`
 	Mail_id    string             `json:"mail_id" bson:"mail_id"`
 	Email      string             `json:"email" bson:"email"`
-	Code       string             `json:"code" bson:"code"`
+	User_id    string             `json:"user_id,omitempty" bson:"user_id,omitempty"`
 	Type       string             `json:"type" bson:"type"`
 	Created_at time.Time          `json:"created_at" bson:"created_at"`
 	Updated_at time.Time          `json:"updated_at" bson:"updated_at"`
-	Expires_at time.Time          `json:"expires_at" bson:"expires_at"`
+	// removed by the ttl index once passed
+	Expires_at time.Time `json:"expires_at" bson:"expires_at"`
+	// keyed hash of the code, see helpers.MailCodeHelper
+	Code_hash string `json:"-" bson:"code_hash"`
+	// set when the code is used, a consumed code is kept until it expires but never accepted again
+	Consumed_at *time.Time `json:"consumed_at,omitempty" bson:"consumed_at,omitempty"`
+	Nonce_hash  string     `json:"-" bson:"nonce_hash,omitempty"`
+	// last delivery event reported by the sendgrid event webhook
+	Delivery_status string              `json:"delivery_status,omitempty" bson:"delivery_status,omitempty"`
+	Delivery_events []MailDeliveryEvent `json:"delivery_events,omitempty" bson:"delivery_events,omitempty"`
+}
+
+type MailDeliveryEvent struct {
+	Sg_event_id string    `json:"sg_event_id" bson:"sg_event_id"`
+	Event       string    `json:"event" bson:"event"`
+	Reason      string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	Occurred_at time.Time `json:"occurred_at" bson:"occurred_at"`
 }
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MailAddress struct {
+	Name    string `json:"name" bson:"name"`
+	Address string `json:"address" bson:"address"`
+}
+
+// MailOutbox is an outgoing mail, written in the transaction of the business change and delivered by the cron module.
+// Template data and body carry one-time links, so they never leave the server in json.
+type MailOutbox struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Mail_outbox_id  string             `json:"mail_outbox_id" bson:"mail_outbox_id"`
+	Mail_type       string             `json:"mail_type" bson:"mail_type"`
+	User_id         string             `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	From_name       string             `json:"from_name" bson:"from_name"`
+	From_email      string             `json:"from_email" bson:"from_email"`
+	Tos             []MailAddress      `json:"tos" bson:"tos"`
+	Locale          string             `json:"locale" bson:"locale"`
+	Template_data   map[string]string  `json:"-" bson:"template_data"`
+	Subject         string             `json:"subject" bson:"subject"`
+	Text_body       string             `json:"-" bson:"text_body"`
+	Html_body       string             `json:"-" bson:"html_body"`
+	Headers         map[string]string  `json:"-" bson:"headers,omitempty"`
+	Status          string             `json:"status" bson:"status"`
+	Attempts        int                `json:"attempts" bson:"attempts"`
+	Next_attempt_at time.Time          `json:"next_attempt_at" bson:"next_attempt_at"`
+	Locked_until    *time.Time         `json:"locked_until,omitempty" bson:"locked_until,omitempty"`
+	Last_error      string             `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	Created_at      time.Time          `json:"created_at" bson:"created_at"`
+	Updated_at      time.Time          `json:"updated_at" bson:"updated_at"`
+	Sent_at         *time.Time         `json:"sent_at,omitempty" bson:"sent_at,omitempty"`
+	// last delivery event reported by the sendgrid event webhook
+	Delivery_status string              `json:"delivery_status,omitempty" bson:"delivery_status,omitempty"`
+	Delivery_events []MailDeliveryEvent `json:"delivery_events,omitempty" bson:"delivery_events,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MailSuppression is an address no mail is sent to anymore, after a hard bounce or a spam report
+type MailSuppression struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Email      string             `json:"email" bson:"email"`
+	Event      string             `json:"event" bson:"event"`
+	Reason     string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	Created_at time.Time          `json:"created_at" bson:"created_at"`
+}
@@ -19,5 +19,32 @@ type User struct {
 	Created_at        time.Time          `json:"created_at" bson:"created_at"`
 	Updated_at        time.Time          `json:"updated_at" bson:"updated_at"`
 	Is_email_verified bool               `json:"is_email_verified" bson:"is_email_verified"`
+	Is_phone_verified bool               `json:"is_phone_verified" bson:"is_phone_verified"`
 	User_id           string             `json:"user_id" bson:"user_id"`
+	Avatar_url        string             `json:"avatar_url" bson:"avatar_url"`
+	Locale            string             `json:"locale" bson:"locale"`
+	Timezone          string             `json:"timezone" bson:"timezone"`
+	Is_disabled       bool               `json:"is_disabled" bson:"is_disabled"`
+
+	// two-factor authentication
+	Is_mfa_enabled           bool     `json:"is_mfa_enabled" bson:"is_mfa_enabled"`
+	Is_mfa_required          bool     `json:"is_mfa_required" bson:"is_mfa_required"`
+	Mfa_secret               string   `json:"-" bson:"mfa_secret"`
+	Mfa_pending_secret       string   `json:"-" bson:"mfa_pending_secret"`
+	Mfa_last_used_step       int64    `json:"-" bson:"mfa_last_used_step"`
+	Mfa_recovery_code_hashes []string `json:"-" bson:"mfa_recovery_code_hashes"`
+
+	// account deletion, the cron module anonymizes the account once deletion_scheduled_at has passed
+	Deletion_scheduled_at *time.Time `json:"deletion_scheduled_at,omitempty" bson:"deletion_scheduled_at,omitempty"`
+
+	// opt in or out per optional mail category, categories missing here use their default
+	Mail_preferences map[string]bool `json:"mail_preferences,omitempty" bson:"mail_preferences,omitempty"`
+
+	// social login
+	Oidc_identities []OidcIdentity `json:"-" bson:"oidc_identities"`
+}
+
+type OidcIdentity struct {
+	Provider string `json:"provider" bson:"provider"`
+	Subject  string `json:"subject" bson:"subject"`
 }
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"nft-raffle/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	adminController controllers.IAdminController = controllers.AdminController
+)
+
+func AdminRoutes(superRoute *gin.RouterGroup) {
+	adminRouter := superRoute.Group("/admin", authMiddleware.Authenticate, authMiddleware.AuthorizeAdmin)
+
+	adminRouter.GET("/users", adminController.ListUsers)
+	adminRouter.GET("/users/:user_id", adminController.GetUser)
+	adminRouter.PATCH("/users/:user_id/role", adminController.ChangeUserRole)
+	adminRouter.POST("/users/:user_id/verify-email", adminController.VerifyUserEmail)
+	adminRouter.POST("/users/:user_id/disable", adminController.DisableUser)
+	adminRouter.POST("/users/:user_id/enable", adminController.EnableUser)
+	adminRouter.POST("/users/:user_id/logout", adminController.ForceLogoutUser)
+	adminRouter.GET("/action-logs", adminController.ListActionLogs)
+	adminRouter.GET("/mail-outbox", adminController.ListOutboxMails)
+	adminRouter.POST("/mail-outbox/:mail_outbox_id/retry", adminController.RetryOutboxMail)
+	adminRouter.GET("/mail/preview/:type", adminController.PreviewMail)
+	adminRouter.POST("/mail/test-send", adminController.TestSendMail)
+}
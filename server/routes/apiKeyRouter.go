@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"nft-raffle/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	apiKeyController controllers.IApiKeyController = controllers.ApiKeyController
+)
+
+// ApiKeyRoutes only accept the user's own session, an api key cannot mint or revoke keys
+func ApiKeyRoutes(superRoute *gin.RouterGroup) {
+	apiKeyRouter := superRoute.Group("/user/api-keys", authMiddleware.Authenticate)
+
+	apiKeyRouter.POST("", apiKeyController.CreateApiKey)
+	apiKeyRouter.GET("", apiKeyController.ListApiKeys)
+	apiKeyRouter.DELETE("/:api_key_id", apiKeyController.RevokeApiKey)
+}
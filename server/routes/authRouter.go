@@ -18,6 +18,9 @@ func AuthRoutes(superRoute *gin.RouterGroup) {
 	authRouter.POST("/signup", authController.SignUp)
 	authRouter.POST("/login", authController.Login)
 	authRouter.POST("/refresh-token", authController.RefreshToken)
+	authRouter.POST("/logout", authController.Logout)
 	authRouter.POST("/reset-user-password", authController.ResetUserPassword)
+	authRouter.PATCH("/password", authMiddleware.Authenticate, authController.ChangePassword)
+	authRouter.POST("/email", authMiddleware.Authenticate, authController.ChangeEmail)
 	authRouter.GET("/test-redis", authMiddleware.Authenticate, authController.TestRedis)
 }
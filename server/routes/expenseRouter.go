@@ -2,6 +2,7 @@ package routes
 
 import (
 	"nft-raffle/controllers"
+	"nft-raffle/enums"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,8 +14,8 @@ var (
 func ExpenseRoutes(superRoute *gin.RouterGroup) {
 	expenseRouter := superRoute.Group("/expense")
 
-	expenseRouter.POST("/create-new-expense", authMiddleware.Authenticate, expenseController.CreateNewExpense)
-	expenseRouter.POST("/get-expenses", authMiddleware.Authenticate, expenseController.GetExpenses)
-	expenseRouter.POST("/get-expenses-by-type", authMiddleware.Authenticate, expenseController.GetExpensesByType)
-	expenseRouter.PATCH("/update-expense", authMiddleware.Authenticate, expenseController.UpdateExpense)
+	expenseRouter.POST("/create-new-expense", authMiddleware.AllowApiKey(enums.ExpenseWrite), authMiddleware.Authenticate, expenseController.CreateNewExpense)
+	expenseRouter.POST("/get-expenses", authMiddleware.AllowApiKey(enums.ExpenseRead), authMiddleware.Authenticate, expenseController.GetExpenses)
+	expenseRouter.POST("/get-expenses-by-type", authMiddleware.AllowApiKey(enums.ExpenseRead), authMiddleware.Authenticate, expenseController.GetExpensesByType)
+	expenseRouter.PATCH("/update-expense", authMiddleware.AllowApiKey(enums.ExpenseWrite), authMiddleware.Authenticate, expenseController.UpdateExpense)
 }
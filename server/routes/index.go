@@ -4,6 +4,13 @@ import "github.com/gin-gonic/gin"
 
 func AddRoutes(superRoute *gin.RouterGroup) {
 	AuthRoutes(superRoute)
+	MfaRoutes(superRoute)
+	OidcRoutes(superRoute)
+	UserRoutes(superRoute)
+	ApiKeyRoutes(superRoute)
+	PhoneVerificationRoutes(superRoute)
+	MailPreferenceRoutes(superRoute)
+	AdminRoutes(superRoute)
 	SendGridMailRoutes(superRoute)
 	ExpenseRoutes(superRoute)
 }
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"nft-raffle/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	mailPreferenceController controllers.IMailPreferenceController = controllers.MailPreferenceController
+)
+
+func MailPreferenceRoutes(superRoute *gin.RouterGroup) {
+	superRoute.GET("/user/me/mail-preferences", authMiddleware.Authenticate, mailPreferenceController.GetMailPreferences)
+	superRoute.PATCH("/user/me/mail-preferences", authMiddleware.Authenticate, mailPreferenceController.UpdateMailPreferences)
+
+	// linked from the mails and the List-Unsubscribe header, authenticated by the signed token
+	mailRouter := superRoute.Group("/mail")
+	mailRouter.GET("/unsubscribe", mailPreferenceController.ShowUnsubscribe)
+	mailRouter.POST("/unsubscribe", mailPreferenceController.Unsubscribe)
+}
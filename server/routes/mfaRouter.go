@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"nft-raffle/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	mfaController controllers.IMfaController = controllers.MfaController
+)
+
+func MfaRoutes(superRoute *gin.RouterGroup) {
+	mfaRouter := superRoute.Group("/auth/mfa")
+
+	mfaRouter.POST("/enroll", authMiddleware.AuthenticateMfaEnrollment, mfaController.Enroll)
+	mfaRouter.POST("/confirm", authMiddleware.AuthenticateMfaEnrollment, mfaController.ConfirmEnrollment)
+	mfaRouter.POST("/verify", mfaController.VerifyLogin)
+	mfaRouter.POST("/disable", authMiddleware.Authenticate, mfaController.Disable)
+	mfaRouter.POST("/recovery-codes", authMiddleware.Authenticate, mfaController.RegenerateRecoveryCodes)
+	mfaRouter.PATCH("/require", authMiddleware.Authenticate, authMiddleware.AuthorizeAdmin, mfaController.SetMfaRequired)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"nft-raffle/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	oidcController controllers.IOidcController = controllers.OidcController
+)
+
+func OidcRoutes(superRoute *gin.RouterGroup) {
+	oidcRouter := superRoute.Group("/auth/oidc")
+
+	oidcRouter.GET("/:provider/login", oidcController.Login)
+	oidcRouter.GET("/:provider/callback", oidcController.Callback)
+}
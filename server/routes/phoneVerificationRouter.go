@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"nft-raffle/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	phoneVerificationController controllers.IPhoneVerificationController = controllers.PhoneVerificationController
+)
+
+func PhoneVerificationRoutes(superRoute *gin.RouterGroup) {
+	phoneVerificationRouter := superRoute.Group("/user/me/phone", authMiddleware.Authenticate)
+
+	phoneVerificationRouter.POST("/send-verification-code", phoneVerificationController.SendVerificationCode)
+	phoneVerificationRouter.POST("/verify", phoneVerificationController.VerifyPhone)
+}
@@ -7,7 +7,8 @@ import (
 )
 
 var (
-	sendGridController controllers.ISendGridController = controllers.SendGridController
+	sendGridController      controllers.ISendGridController      = controllers.SendGridController
+	sendGridEventController controllers.ISendGridEventController = controllers.SendGridEventController
 )
 
 func SendGridMailRoutes(superRoute *gin.RouterGroup) {
@@ -15,5 +16,11 @@ func SendGridMailRoutes(superRoute *gin.RouterGroup) {
 
 	sendgridMailRouter.POST("/send-password-reset-mail", sendGridController.SendPasswordResetMail)
 	sendgridMailRouter.GET("/verify-verification-mail", sendGridController.VerifyVerificationMail)
+	sendgridMailRouter.POST("/resend-verification-mail", sendGridController.ResendVerificationMail)
 	sendgridMailRouter.GET("/verify-password-reset-mail", sendGridController.VerifyPasswordResetMail)
+	sendgridMailRouter.GET("/verify-email-change-mail", sendGridController.VerifyEmailChangeMail)
+	sendgridMailRouter.POST("/send-magic-link-mail", sendGridController.SendMagicLinkMail)
+	sendgridMailRouter.GET("/verify-magic-link-mail", sendGridController.VerifyMagicLinkMail)
+	// called by sendgrid, authenticated by the event webhook signature
+	sendgridMailRouter.POST("/events", sendGridEventController.ReceiveEvents)
 }
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"nft-raffle/controllers"
+	"nft-raffle/enums"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	userController controllers.IUserController = controllers.UserController
+)
+
+func UserRoutes(superRoute *gin.RouterGroup) {
+	userRouter := superRoute.Group("/user")
+
+	userRouter.GET("/me", authMiddleware.AllowApiKey(enums.ProfileRead), authMiddleware.Authenticate, userController.GetMe)
+	userRouter.PATCH("/me", authMiddleware.Authenticate, userController.UpdateMe)
+	userRouter.DELETE("/me", authMiddleware.Authenticate, userController.DeleteMe)
+	userRouter.GET("/me/export", authMiddleware.Authenticate, userController.ExportMe)
+	userRouter.POST("/me/cancel-deletion", authMiddleware.Authenticate, userController.CancelDeletion)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"nft-raffle/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	jwksController controllers.IJwksController = controllers.JwksController
+)
+
+// WellKnownRoutes are served from the root, outside of /api
+func WellKnownRoutes(superRoute *gin.RouterGroup) {
+	wellKnownRouter := superRoute.Group("/.well-known")
+
+	wellKnownRouter.GET("/jwks.json", jwksController.GetJwks)
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"nft-raffle-mail/expensedigest"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ExpenseService IExpenseService = NewExpenseService()
+
+	expenseCollection *mongo.Collection = nftRaffleDb.OpenCollection(nftRaffleDbClient, "expense")
+)
+
+type IExpenseService interface {
+	AggregateExpensesByType(ctx context.Context, userId string, fromDate time.Time, toDate time.Time, results interface{}) error
+}
+
+type expenseServiceStruct struct{}
+
+func NewExpenseService() IExpenseService {
+	return &expenseServiceStruct{}
+}
+
+// AggregateExpensesByType groups the expenses of the user between both dates by type, biggest type first.
+// results receives at most one document, nothing when there are no expenses.
+func (e *expenseServiceStruct) AggregateExpensesByType(ctx context.Context, userId string, fromDate time.Time, toDate time.Time, results interface{}) error {
+	cursor, err := expenseCollection.Aggregate(ctx, expensedigest.ExpensesByTypePipeline(userId, fromDate, toDate))
+
+	if err != nil {
+		return err
+	}
+
+	return cursor.All(ctx, results)
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"nft-raffle-mail/mailtransport"
+	"nft-raffle/dto"
+)
+
+const (
+	// every mail goes to the address of the user
+	DeliverRecipientPolicy string = mailtransport.DeliverRecipientPolicy
+	// for staging, only allowlisted domains get their mail, everything else goes to the catch-all address
+	RedirectRecipientPolicy string = mailtransport.RedirectRecipientPolicy
+
+	OriginalRecipientHeader string = mailtransport.OriginalRecipientHeader
+)
+
+var (
+	MailRecipientPolicy IMailRecipientPolicy = NewMailRecipientPolicyWithPolicy(mailtransport.NewRecipientPolicyFromEnv(dotEnvHelper.GetEnvVariable))
+)
+
+type MailRecipientPolicyConfig struct {
+	Policy          string
+	RedirectAddress string
+	AllowedDomains  []string
+}
+
+// IMailRecipientPolicy decides who really receives a mail, the original recipients it replaced are returned
+// so they can be noted in a header. The cron module applies the same policy to the mails it queues.
+type IMailRecipientPolicy interface {
+	Apply(tos []dto.MailAddress) (recipients []dto.MailAddress, originalTos []string)
+}
+
+type mailRecipientPolicyStruct struct {
+	recipientPolicy *mailtransport.RecipientPolicy
+}
+
+func NewMailRecipientPolicy(config MailRecipientPolicyConfig) IMailRecipientPolicy {
+	return NewMailRecipientPolicyWithPolicy(mailtransport.NewRecipientPolicy(config.Policy, config.RedirectAddress, config.AllowedDomains))
+}
+
+func NewMailRecipientPolicyWithPolicy(recipientPolicy *mailtransport.RecipientPolicy) IMailRecipientPolicy {
+	return &mailRecipientPolicyStruct{recipientPolicy: recipientPolicy}
+}
+
+func (m *mailRecipientPolicyStruct) Apply(tos []dto.MailAddress) ([]dto.MailAddress, []string) {
+	addresses := make([]mailtransport.Address, 0, len(tos))
+
+	for _, to := range tos {
+		addresses = append(addresses, mailtransport.Address{Name: to.Name, Address: to.Address})
+	}
+
+	recipients, originalTos := m.recipientPolicy.Apply(addresses)
+
+	var recipientTos []dto.MailAddress
+
+	for _, recipient := range recipients {
+		recipientTos = append(recipientTos, dto.MailAddress{Name: recipient.Name, Address: recipient.Address})
+	}
+
+	return recipientTos, originalTos
+}
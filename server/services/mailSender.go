@@ -0,0 +1,59 @@
+package services
+
+import (
+	"nft-raffle-mail/mailtransport"
+	"nft-raffle/dto"
+)
+
+var (
+	MailSender IMailSender = NewMailSender()
+)
+
+// IMailSender delivers one mail request right away, only the admin test sends use it,
+// every other mail goes through the outbox and is delivered by the cron module with the same transports
+type IMailSender interface {
+	Send(mailRequest *dto.MailRequest) error
+}
+
+type mailSenderStruct struct {
+	mailTransport        mailtransport.MailTransport
+	mailTemplateRenderer IMailTemplateRenderer
+}
+
+// NewMailSender picks the transport from MAIL_TRANSPORT, sendgrid stays the default
+func NewMailSender() IMailSender {
+	return NewMailSenderWithTransport(mailtransport.NewMailTransport(dotEnvHelper.GetEnvVariable))
+}
+
+func NewMailSenderWithTransport(mailTransport mailtransport.MailTransport) IMailSender {
+	return &mailSenderStruct{
+		mailTransport:        mailTransport,
+		mailTemplateRenderer: MailTemplateRenderer,
+	}
+}
+
+func (m *mailSenderStruct) Send(mailRequest *dto.MailRequest) error {
+	renderedMail, err := m.mailTemplateRenderer.Render(mailRequest)
+
+	if err != nil {
+		return err
+	}
+
+	message := mailtransport.Message{
+		MailType:     mailRequest.MailType.String(),
+		FromName:     mailRequest.FromName,
+		FromEmail:    mailRequest.FromEmail,
+		Locale:       renderedMail.Locale,
+		TemplateData: mailRequest.DynamicTemplateData,
+		Subject:      renderedMail.Subject,
+		TextBody:     renderedMail.TextBody,
+		HtmlBody:     renderedMail.HtmlBody,
+		Headers:      mailRequest.Headers,
+	}
+
+	for _, to := range mailRequest.Tos {
+		message.Tos = append(message.Tos, mailtransport.Address{Name: to.Name, Address: to.Address})
+	}
+
+	return m.mailTransport.Send(message)
+}
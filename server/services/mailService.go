@@ -0,0 +1,416 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nft-raffle/database"
+	"nft-raffle/dto"
+	"nft-raffle/enums"
+	"nft-raffle/helpers"
+	"nft-raffle/logger"
+	"nft-raffle/models"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	MailService IMailService = NewMailService()
+
+	nftRaffleDbClient *mongo.Client                        = database.NftRaffleDbClient
+	nftRaffleDb       database.INftRaffleMongoDbConnection = database.NftRaffleMongoDbConnection
+	mailCollection    *mongo.Collection                    = nftRaffleDb.OpenCollection(nftRaffleDbClient, "mail")
+	// outgoing mails waiting for the cron mail outbox worker
+	mailOutboxCollection *mongo.Collection = nftRaffleDb.OpenCollection(nftRaffleDbClient, "mailOutbox")
+	// addresses that hard bounced or reported spam, nothing is queued for them
+	mailSuppressionCollection *mongo.Collection = nftRaffleDb.OpenCollection(nftRaffleDbClient, "mailSuppression")
+	userCollection            *mongo.Collection = nftRaffleDb.OpenCollection(nftRaffleDbClient, "user")
+
+	mailPreferenceHelper helpers.IMailPreferenceHelper = helpers.MailPreferenceHelper
+	mailCodeHelper       helpers.IMailCodeHelper       = helpers.MailCodeHelper
+
+	ErrMailCodeNotFound = errors.New("no pending mail for this email")
+	ErrMailCodeMismatch = errors.New("mail code does not match")
+	ErrMailCodeExpired  = errors.New("mail code has expired")
+	ErrMailCodeConsumed = errors.New("mail code has already been used")
+
+	dotEnvHelper helpers.IDotEnvHelper = helpers.DotEnvHelper
+	timeHelper   helpers.ITimeHelper   = helpers.TimeHelper
+)
+
+// IMailService stores the mailed codes and queues the mails in the outbox, the cron module delivers them.
+// Pass the session context of the business write so the code and the mail are committed together.
+type IMailService interface {
+	EnqueueMail(ctx context.Context, mailRequest *dto.MailRequest) error
+	SuppressEmail(ctx context.Context, email string, event enums.MailDeliveryEvent, reason string) error
+	IssueMailCode(ctx context.Context, mailType enums.MailType, userId string, email string, code string, expires_at time.Time) (*models.Mail, error)
+	VerifyMailCode(ctx context.Context, mailType enums.MailType, email string, code string) (*models.Mail, error)
+	ConsumeMailCode(ctx context.Context, mail *models.Mail) error
+	EnsureMailCodeIndexes(ctx context.Context) error
+	EnsureMailOutboxIndexes(ctx context.Context) error
+}
+
+type mailServiceStruct struct {
+	mailTemplateRenderer IMailTemplateRenderer
+	mailRecipientPolicy  IMailRecipientPolicy
+}
+
+func NewMailService() IMailService {
+	return &mailServiceStruct{mailTemplateRenderer: MailTemplateRenderer, mailRecipientPolicy: MailRecipientPolicy}
+}
+
+// EnqueueMail renders the local templates now, so the cron module only delivers what was reviewed in the repo
+func (s *mailServiceStruct) EnqueueMail(ctx context.Context, mailRequest *dto.MailRequest) error {
+	if len(mailRequest.Tos) < 1 {
+		return fmt.Errorf("%s mail has no recipient", mailRequest.MailType.String())
+	}
+
+	tos, err := s.removeSuppressedAddresses(ctx, mailRequest.Tos)
+
+	if err != nil {
+		return err
+	}
+
+	// not an error, the flow that asked for the mail goes on as if it was sent
+	if len(tos) < 1 {
+		logger.Logger.Warn(fmt.Sprintf("%s mail skipped, every recipient is suppressed", mailRequest.MailType.String()))
+		return nil
+	}
+
+	isAllowed, err := s.applyMailPreferences(ctx, mailRequest)
+
+	if err != nil {
+		return err
+	}
+
+	if !isAllowed {
+		logger.Logger.Info(fmt.Sprintf("%s mail skipped, user %s opted out of %s mails", mailRequest.MailType.String(), mailRequest.UserId, mailRequest.MailType.Category().String()))
+		return nil
+	}
+
+	renderedMail, err := s.mailTemplateRenderer.Render(mailRequest)
+
+	if err != nil {
+		return err
+	}
+
+	recipients, originalTos := s.mailRecipientPolicy.Apply(tos)
+
+	if len(recipients) < 1 {
+		logger.Logger.Info(fmt.Sprintf("%s mail skipped, the recipient policy allows none of the recipients", mailRequest.MailType.String()))
+		return nil
+	}
+
+	headers := map[string]string{}
+
+	for key, value := range mailRequest.Headers {
+		headers[key] = value
+	}
+
+	if len(originalTos) > 0 {
+		headers[OriginalRecipientHeader] = strings.Join(originalTos, ", ")
+	}
+
+	var outboxMail models.MailOutbox
+	outboxMail.ID = primitive.NewObjectID()
+	outboxMail.Mail_outbox_id = outboxMail.ID.Hex()
+	outboxMail.Mail_type = mailRequest.MailType.String()
+	// the recipient policy may redirect the mail, the account deletion finds it by user
+	outboxMail.User_id = mailRequest.UserId
+	outboxMail.From_name = mailRequest.FromName
+	outboxMail.From_email = mailRequest.FromEmail
+	outboxMail.Template_data = mailRequest.DynamicTemplateData
+	outboxMail.Locale = renderedMail.Locale
+	outboxMail.Subject = renderedMail.Subject
+	outboxMail.Text_body = renderedMail.TextBody
+	outboxMail.Html_body = renderedMail.HtmlBody
+	outboxMail.Headers = headers
+	outboxMail.Status = enums.MailOutboxPending.String()
+
+	for _, to := range recipients {
+		outboxMail.Tos = append(outboxMail.Tos, models.MailAddress{Name: to.Name, Address: to.Address})
+	}
+
+	outboxMail.Created_at, err = timeHelper.GetCurrentLocationTime()
+
+	if err != nil {
+		return err
+	}
+
+	outboxMail.Updated_at = outboxMail.Created_at
+	outboxMail.Next_attempt_at = outboxMail.Created_at
+
+	if _, err := mailOutboxCollection.InsertOne(ctx, outboxMail); err != nil {
+		return err
+	}
+
+	logger.Logger.Info(fmt.Sprintf("%s mail %s has been queued", mailRequest.MailType.String(), outboxMail.Mail_outbox_id))
+
+	return nil
+}
+
+// applyMailPreferences lets security mails through, optional categories are checked against the preferences of
+// the user and get the one-click unsubscribe link of RFC 8058 in the headers and the template data
+func (s *mailServiceStruct) applyMailPreferences(ctx context.Context, mailRequest *dto.MailRequest) (bool, error) {
+	category := mailRequest.MailType.Category()
+
+	if !category.IsOptional() {
+		return true, nil
+	}
+
+	if mailRequest.UserId == "" {
+		return false, fmt.Errorf("%s mail needs the user id to check the mail preferences", mailRequest.MailType.String())
+	}
+
+	var user models.User
+
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": mailRequest.UserId}).Decode(&user); err != nil {
+		return false, err
+	}
+
+	if !mailPreferenceHelper.IsCategoryEnabled(user, category) {
+		return false, nil
+	}
+
+	unsubscribeLink, err := mailPreferenceHelper.GenerateUnsubscribeLink(user.User_id, category)
+
+	if err != nil {
+		return false, err
+	}
+
+	headers := map[string]string{}
+
+	for key, value := range mailRequest.Headers {
+		headers[key] = value
+	}
+
+	headers["List-Unsubscribe"] = "<" + unsubscribeLink + ">"
+	headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	mailRequest.Headers = headers
+
+	templateData := map[string]string{}
+
+	for key, value := range mailRequest.DynamicTemplateData {
+		templateData[key] = value
+	}
+
+	templateData["Unsubscribe_Link"] = unsubscribeLink
+	mailRequest.DynamicTemplateData = templateData
+
+	return true, nil
+}
+
+func (s *mailServiceStruct) removeSuppressedAddresses(ctx context.Context, tos []dto.MailAddress) ([]dto.MailAddress, error) {
+	addresses := make([]string, 0, len(tos))
+
+	for _, to := range tos {
+		addresses = append(addresses, strings.ToLower(to.Address))
+	}
+
+	cursor, err := mailSuppressionCollection.Find(ctx, bson.M{"email": bson.M{"$in": addresses}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var suppressions []models.MailSuppression
+
+	if err := cursor.All(ctx, &suppressions); err != nil {
+		return nil, err
+	}
+
+	suppressed := map[string]bool{}
+
+	for _, suppression := range suppressions {
+		suppressed[suppression.Email] = true
+	}
+
+	var allowedTos []dto.MailAddress
+
+	for _, to := range tos {
+		if !suppressed[strings.ToLower(to.Address)] {
+			allowedTos = append(allowedTos, to)
+		}
+	}
+
+	return allowedTos, nil
+}
+
+// SuppressEmail keeps the first reason when an address is reported more than once
+func (s *mailServiceStruct) SuppressEmail(ctx context.Context, email string, event enums.MailDeliveryEvent, reason string) error {
+	now, err := timeHelper.GetCurrentLocationTime()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = mailSuppressionCollection.UpdateOne(
+		ctx,
+		bson.M{"email": strings.ToLower(email)},
+		bson.D{{Key: "$setOnInsert", Value: bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "event", Value: event.String()},
+			{Key: "reason", Value: reason},
+			{Key: "created_at", Value: now},
+		}}},
+		options.Update().SetUpsert(true),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	logger.Logger.Warn(fmt.Sprintf("mails to an address are suppressed after a %s event", event.String()))
+
+	return nil
+}
+
+// IssueMailCode stores the hash of a new code, older codes of the same type for the user or the address
+// are removed so only the latest mail works
+func (s *mailServiceStruct) IssueMailCode(ctx context.Context, mailType enums.MailType, userId string, email string, code string, expires_at time.Time) (*models.Mail, error) {
+	codeHash, err := mailCodeHelper.HashCode(mailType, email, code)
+
+	if err != nil {
+		return nil, err
+	}
+
+	owners := bson.A{bson.D{{Key: "email", Value: email}}}
+
+	if userId != "" {
+		owners = append(owners, bson.D{{Key: "user_id", Value: userId}})
+	}
+
+	_, err = mailCollection.DeleteMany(ctx, bson.D{
+		{Key: "type", Value: mailType.String()},
+		{Key: "$or", Value: owners},
+	})
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		return nil, err
+	}
+
+	var mail models.Mail
+	mail.ID = primitive.NewObjectID()
+	mail.Mail_id = mail.ID.Hex()
+	mail.Email = email
+	mail.User_id = userId
+	mail.Type = mailType.String()
+	mail.Code_hash = codeHash
+
+	mail.Created_at, err = timeHelper.GetCurrentLocationTime()
+
+	if err != nil {
+		logger.Logger.Error(err.Error())
+		return nil, err
+	}
+
+	mail.Updated_at = mail.Created_at
+	mail.Expires_at = expires_at
+
+	_, insertError := mailCollection.InsertOne(ctx, mail)
+
+	if insertError != nil {
+		logger.Logger.Error(insertError.Error())
+		return nil, insertError
+	}
+
+	return &mail, nil
+}
+
+// VerifyMailCode checks the code against the latest unused mail of the type sent to the address,
+// it does not use the code up, see ConsumeMailCode
+func (s *mailServiceStruct) VerifyMailCode(ctx context.Context, mailType enums.MailType, email string, code string) (*models.Mail, error) {
+	var mail models.Mail
+
+	err := mailCollection.FindOne(
+		ctx,
+		bson.D{
+			{Key: "email", Value: email},
+			{Key: "type", Value: mailType.String()},
+			{Key: "consumed_at", Value: bson.M{"$exists": false}},
+		},
+		options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	).Decode(&mail)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrMailCodeNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !mailCodeHelper.IsCodeValid(mail.Code_hash, mailType, email, code) {
+		return nil, ErrMailCodeMismatch
+	}
+
+	// the ttl monitor runs about once a minute, expired mails may still be found
+	if mail.Expires_at.Unix() < time.Now().Local().Unix() {
+		return nil, ErrMailCodeExpired
+	}
+
+	return &mail, nil
+}
+
+// ConsumeMailCode marks the mail as used, only one of two concurrent requests with the same code succeeds
+func (s *mailServiceStruct) ConsumeMailCode(ctx context.Context, mail *models.Mail) error {
+	consumed_at, err := timeHelper.GetCurrentLocationTime()
+
+	if err != nil {
+		return err
+	}
+
+	updateResult, err := mailCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "mail_id", Value: mail.Mail_id},
+			{Key: "consumed_at", Value: bson.M{"$exists": false}},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "consumed_at", Value: consumed_at},
+				{Key: "updated_at", Value: consumed_at},
+			}},
+		},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if updateResult.MatchedCount < 1 {
+		return ErrMailCodeConsumed
+	}
+
+	return nil
+}
+
+// EnsureMailCodeIndexes lets mongo remove the mails once expires_at has passed
+func (s *mailServiceStruct) EnsureMailCodeIndexes(ctx context.Context) error {
+	_, err := mailCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys: bson.D{{Key: "email", Value: 1}, {Key: "type", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	})
+
+	return err
+}
+
+// EnsureMailOutboxIndexes lets the sendgrid event webhook find the outbox mail of an event by its custom arg
+func (s *mailServiceStruct) EnsureMailOutboxIndexes(ctx context.Context) error {
+	_, err := mailOutboxCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "mail_outbox_id", Value: 1}},
+	})
+
+	return err
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"nft-raffle-mail/mailtemplate"
+	"nft-raffle/dto"
+)
+
+const (
+	// every mail type must exist in the default locale, other locales fall back to it
+	DefaultMailLocale string = mailtemplate.DefaultMailLocale
+)
+
+var (
+	MailTemplateRenderer IMailTemplateRenderer = NewMailTemplateRenderer()
+)
+
+// RenderedMail is the mail rendered from the templates of the shared mail module, the cron module renders the same ones
+type RenderedMail = mailtemplate.RenderedMail
+
+type IMailTemplateRenderer interface {
+	Render(mailRequest *dto.MailRequest) (*RenderedMail, error)
+	ResolveLocale(locale string) string
+}
+
+type mailTemplateRendererStruct struct {
+	mailTemplateRenderer *mailtemplate.MailTemplateRenderer
+}
+
+func NewMailTemplateRenderer() IMailTemplateRenderer {
+	mailTemplateRenderer, err := mailtemplate.NewMailTemplateRenderer()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return &mailTemplateRendererStruct{mailTemplateRenderer: mailTemplateRenderer}
+}
+
+// ResolveLocale maps a BCP 47 tag of the user to a template locale, "es-MX" uses "es" and unknown tags use the default
+func (m *mailTemplateRendererStruct) ResolveLocale(locale string) string {
+	return m.mailTemplateRenderer.ResolveLocale(locale)
+}
+
+func (m *mailTemplateRendererStruct) Render(mailRequest *dto.MailRequest) (*RenderedMail, error) {
+	return m.mailTemplateRenderer.Render(mailRequest.MailType.String(), mailRequest.Locale, mailRequest.DynamicTemplateData)
+}
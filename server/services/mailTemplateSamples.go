@@ -0,0 +1,48 @@
+package services
+
+import "nft-raffle/enums"
+
+// MailTemplateSampleData fills every variable of the mail type for the admin preview and test sends
+var MailTemplateSampleData = map[enums.MailType]map[string]string{
+	enums.MailVerification: {
+		"Full_Name":        "Jane Doe",
+		"Verify_Mail_Link": "https://example.com/api/send-grid/verify-verification-mail?email=sample&code=sample",
+	},
+	enums.PasswordReset: {
+		"Full_Name":                "Jane Doe",
+		"Password_Reset_Mail_Link": "https://example.com/api/send-grid/verify-password-reset-mail?email=sample&code=sample",
+	},
+	enums.AccountLocked: {
+		"Full_Name":       "Jane Doe",
+		"Lockout_Minutes": "15",
+		"Ip_Address":      "203.0.113.7",
+	},
+	enums.EmailChange: {
+		"Full_Name":              "Jane Doe",
+		"New_Email":              "jane.new@example.com",
+		"Email_Change_Mail_Link": "https://example.com/api/send-grid/verify-email-change-mail?email=sample&code=sample",
+	},
+	enums.EmailChangeNotice: {
+		"Full_Name": "Jane Doe",
+		"New_Email": "jane.new@example.com",
+	},
+	enums.MagicLinkLogin: {
+		"Full_Name":          "Jane Doe",
+		"Expiration_Minutes": "15",
+		"Magic_Link":         "https://example.com/api/send-grid/verify-magic-link-mail?email=sample&code=sample",
+	},
+	enums.WeeklyExpenseDigest: {
+		"Full_Name":              "Jane Doe",
+		"Week_Start":             "2024-01-01",
+		"Week_End":               "2024-01-07",
+		"Total_Spend":            "420",
+		"Previous_Total_Spend":   "350",
+		"Spend_Change":           "+20.0%",
+		"Expense_Count":          "12",
+		"Top_Categories":         "Food 180, Transport 120, Entertainment 60",
+		"Largest_Expense_Label":  "Concert tickets",
+		"Largest_Expense_Type":   "Entertainment",
+		"Largest_Expense_Amount": "60",
+		"Unsubscribe_Link":       "https://example.com/api/mail/unsubscribe?token=sample",
+	},
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"nft-raffle/logger"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TwilioSmsProvider string = "twilio"
+	FakeSmsProvider   string = "fake"
+
+	defaultTwilioApiBaseUrl string = "https://api.twilio.com"
+)
+
+var (
+	SmsSender ISmsSender = NewSmsSender()
+
+	ErrSmsNotConfigured = errors.New("sms provider is not configured")
+
+	smsProvider               string = dotEnvHelper.GetEnvVariable("SMS_PROVIDER")
+	smsFakeOutputFile         string = dotEnvHelper.GetEnvVariable("SMS_FAKE_OUTPUT_FILE")
+	twilioAccountSid          string = dotEnvHelper.GetEnvVariable("TWILIO_ACCOUNT_SID")
+	twilioAuthToken           string = dotEnvHelper.GetEnvVariable("TWILIO_AUTH_TOKEN")
+	twilioFromNumber          string = dotEnvHelper.GetEnvVariable("TWILIO_FROM_NUMBER")
+	twilioApiBaseUrl          string = dotEnvHelper.GetEnvVariable("TWILIO_API_BASE_URL")
+	twilioMessagingServiceSid string = dotEnvHelper.GetEnvVariable("TWILIO_MESSAGING_SERVICE_SID")
+)
+
+// ISmsSender delivers a text message to an E.164 phone number
+type ISmsSender interface {
+	SendSms(to string, body string) error
+}
+
+// NewSmsSender picks the provider from SMS_PROVIDER, the fake sender has to be chosen explicitly for local runs.
+// Without a usable provider every send fails, the server still starts for the routes that do not send sms.
+func NewSmsSender() ISmsSender {
+	switch strings.ToLower(smsProvider) {
+	case TwilioSmsProvider:
+		if twilioAccountSid == "" || twilioAuthToken == "" || (twilioFromNumber == "" && twilioMessagingServiceSid == "") {
+			return newUnconfiguredSmsSender(fmt.Errorf("%w: twilio needs an account sid, an auth token and a sender", ErrSmsNotConfigured))
+		}
+
+		return NewTwilioSmsSender(TwilioConfig{
+			ApiBaseUrl:          twilioApiBaseUrl,
+			AccountSid:          twilioAccountSid,
+			AuthToken:           twilioAuthToken,
+			FromNumber:          twilioFromNumber,
+			MessagingServiceSid: twilioMessagingServiceSid,
+		}, &http.Client{Timeout: 10 * time.Second})
+	case FakeSmsProvider:
+		logger.Logger.Warn("SMS_PROVIDER is fake, text messages are not delivered")
+		return NewFileSmsSender(smsFakeOutputFile)
+	}
+
+	return newUnconfiguredSmsSender(fmt.Errorf("%w: SMS_PROVIDER must be %s or %s, got %q", ErrSmsNotConfigured, TwilioSmsProvider, FakeSmsProvider, smsProvider))
+}
+
+// unconfiguredSmsSenderStruct refuses every message, the configuration error is logged once at startup
+type unconfiguredSmsSenderStruct struct {
+	err error
+}
+
+func newUnconfiguredSmsSender(err error) ISmsSender {
+	logger.Logger.Error(err.Error())
+	return &unconfiguredSmsSenderStruct{err: err}
+}
+
+func (u *unconfiguredSmsSenderStruct) SendSms(to string, body string) error {
+	return u.err
+}
+
+type TwilioConfig struct {
+	ApiBaseUrl          string
+	AccountSid          string
+	AuthToken           string
+	FromNumber          string
+	MessagingServiceSid string
+}
+
+type twilioSmsSenderStruct struct {
+	config     TwilioConfig
+	httpClient *http.Client
+}
+
+func NewTwilioSmsSender(config TwilioConfig, httpClient *http.Client) ISmsSender {
+	if config.ApiBaseUrl == "" {
+		config.ApiBaseUrl = defaultTwilioApiBaseUrl
+	}
+
+	config.ApiBaseUrl = strings.TrimSuffix(config.ApiBaseUrl, "/")
+
+	return &twilioSmsSenderStruct{config: config, httpClient: httpClient}
+}
+
+func (t *twilioSmsSenderStruct) SendSms(to string, body string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("Body", body)
+
+	// a messaging service picks the sender number itself
+	if t.config.MessagingServiceSid != "" {
+		form.Set("MessagingServiceSid", t.config.MessagingServiceSid)
+	} else {
+		form.Set("From", t.config.FromNumber)
+	}
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", t.config.ApiBaseUrl, url.PathEscape(t.config.AccountSid))
+
+	request, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return err
+	}
+
+	request.SetBasicAuth(t.config.AccountSid, t.config.AuthToken)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := t.httpClient.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1<<16))
+		return fmt.Errorf("sms provider responded %d: %s", response.StatusCode, string(responseBody))
+	}
+
+	return nil
+}
+
+// fileSmsSenderStruct appends every message to a file for local runs and tests.
+// Without a file only the recipient is logged, the body holds the code.
+type fileSmsSenderStruct struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileSmsSender(path string) ISmsSender {
+	return &fileSmsSenderStruct{path: path}
+}
+
+func (f *fileSmsSenderStruct) SendSms(to string, body string) error {
+	if f.path == "" {
+		logger.Logger.Info(fmt.Sprintf("fake sms to %s, set SMS_FAKE_OUTPUT_FILE to read it", to))
+		return nil
+	}
+
+	line := fmt.Sprintf("%s\t%s\t%s\n", time.Now().UTC().Format(time.RFC3339), to, strings.ReplaceAll(body, "\n", " "))
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = file.WriteString(line)
+
+	return err
+}
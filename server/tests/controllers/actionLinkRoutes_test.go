@@ -0,0 +1,29 @@
+package tests_controllers
+
+import (
+	"net/http"
+	"nft-raffle/helpers"
+	"nft-raffle/routes"
+	"nft-raffle/tests"
+	"testing"
+)
+
+// the links sent by mail must open a registered route, they pointed at /api/test before
+func TestActionLinkPathsAreRoutes(t *testing.T) {
+	r := tests.GetGinEngine()
+	routes.AddRoutes(r.Group("/api"))
+
+	getRoutes := map[string]bool{}
+
+	for _, route := range r.Routes() {
+		if route.Method == http.MethodGet {
+			getRoutes[route.Path] = true
+		}
+	}
+
+	for purpose, path := range helpers.ActionLinkPaths {
+		if !getRoutes[path] {
+			t.Errorf("%s link path %s is not a GET route", purpose.String(), path)
+		}
+	}
+}
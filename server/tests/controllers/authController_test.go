@@ -50,3 +50,27 @@ func TestLoginInvalidPassword(t *testing.T) {
 	r.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
+
+func TestLoginResponseHidesPassword(t *testing.T) {
+	r := tests.GetGinEngine()
+	r.POST("/api/login", fakeAuthController.FakeLogin)
+
+	loginUser := &models.User{
+		Email:    "testingaaa@gmail.com",
+		Password: "11111111",
+	}
+
+	jsonValue, _ := json.Marshal(loginUser)
+	req, _ := http.NewRequest("POST", "/api/login", bytes.NewBuffer(jsonValue))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+
+	_, hasPassword := body["password"]
+	assert.Equal(t, false, hasPassword)
+	assert.Equal(t, "aaa", body["first_name"])
+}
@@ -0,0 +1,53 @@
+package tests_controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nft-raffle/controllers"
+	"nft-raffle/helpers"
+	"nft-raffle/tests"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestOidcCallbackRequiresStateCookie(t *testing.T) {
+	cases := []struct {
+		name           string
+		stateCookie    string
+		expectedStatus int
+	}{
+		{"missing cookie", "", http.StatusUnauthorized},
+		{"mismatched cookie", helpers.OidcHelper.HashState("other-state"), http.StatusUnauthorized},
+		// passes the state check, then stops at the provider which the tests do not configure
+		{"matching cookie", helpers.OidcHelper.HashState("state"), http.StatusNotFound},
+	}
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			r := tests.GetGinEngine()
+			r.GET("/api/auth/oidc/:provider/callback", controllers.OidcController.Callback)
+
+			req, _ := http.NewRequest("GET", "/api/auth/oidc/unconfigured/callback?state=state&code=code", nil)
+
+			if test.stateCookie != "" {
+				req.AddCookie(&http.Cookie{Name: helpers.OidcStateCookie, Value: test.stateCookie})
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, test.expectedStatus, w.Code)
+
+			// the cookie is single use, whatever the outcome
+			isCleared := false
+
+			for _, cookie := range w.Result().Cookies() {
+				if cookie.Name == helpers.OidcStateCookie && cookie.MaxAge < 0 && cookie.HttpOnly {
+					isCleared = true
+				}
+			}
+
+			assert.Equal(t, true, isCleared)
+		})
+	}
+}
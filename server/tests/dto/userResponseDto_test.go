@@ -0,0 +1,56 @@
+package tests_dto
+
+import (
+	"encoding/json"
+	"nft-raffle/dto"
+	"nft-raffle/models"
+	"strings"
+	"testing"
+)
+
+// the 2FA enrollment and login responses go through this dto, it must never carry the secrets of the user
+func TestUserResponseDtoLeavesOutSecrets(t *testing.T) {
+	user := models.User{
+		User_id:                  "user-id",
+		Email:                    "user@example.com",
+		Password:                 "$2a$04$passwordhash",
+		Access_token:             "access-token",
+		Refresh_token:            "refresh-token",
+		Mfa_secret:               "mfa-secret",
+		Mfa_pending_secret:       "mfa-pending-secret",
+		Mfa_recovery_code_hashes: []string{"recovery-code-hash"},
+	}
+
+	tests := []struct {
+		name          string
+		includeTokens bool
+		secrets       []string
+	}{
+		{
+			name:          "with tokens",
+			includeTokens: true,
+			secrets:       []string{"passwordhash", "mfa-secret", "mfa-pending-secret", "recovery-code-hash"},
+		},
+		{
+			name:          "without tokens",
+			includeTokens: false,
+			secrets:       []string{"passwordhash", "mfa-secret", "mfa-pending-secret", "recovery-code-hash", "access-token", "refresh-token"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := json.Marshal(dto.NewUserResponseDto(user, test.includeTokens))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, secret := range test.secrets {
+				if strings.Contains(string(body), secret) {
+					t.Errorf("response %s should not contain %q", body, secret)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package tests_helpers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"nft-raffle/enums"
+	"nft-raffle/helpers"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oldActionLinkKey = "old:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("o", 32)))
+	newActionLinkKey = "new:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("n", 32)))
+)
+
+func TestActionLinkToken(t *testing.T) {
+	actionLinkHelper := helpers.NewActionLinkHelper("https://api.example.com", oldActionLinkKey, "")
+
+	token, err := actionLinkHelper.GenerateToken(enums.PasswordReset, "jane@example.com", "123456", time.Now().Add(time.Hour))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !strings.HasPrefix(token, "v1.old.") {
+		t.Errorf("expected a v1 token signed by the old key, got %s", token)
+	}
+
+	claims, err := actionLinkHelper.ParseToken(token, enums.PasswordReset)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if claims.Subject != "jane@example.com" || claims.Code != "123456" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	if _, err := actionLinkHelper.ParseToken(token, enums.MagicLinkLogin); !errors.Is(err, helpers.ErrInvalidActionLink) {
+		t.Errorf("expected a token of another purpose to be invalid, got %v", err)
+	}
+
+	tampered := token[:len(token)-2] + "AA"
+
+	if _, err := actionLinkHelper.ParseToken(tampered, enums.PasswordReset); !errors.Is(err, helpers.ErrInvalidActionLink) {
+		t.Errorf("expected a tampered token to be invalid, got %v", err)
+	}
+
+	expired, err := actionLinkHelper.GenerateToken(enums.PasswordReset, "jane@example.com", "123456", time.Now().Add(-time.Minute))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := actionLinkHelper.ParseToken(expired, enums.PasswordReset); !errors.Is(err, helpers.ErrActionLinkExpired) {
+		t.Errorf("expected ErrActionLinkExpired, got %v", err)
+	}
+}
+
+func TestActionLinkKeyRotation(t *testing.T) {
+	oldHelper := helpers.NewActionLinkHelper("https://api.example.com", oldActionLinkKey, "")
+	rotatedHelper := helpers.NewActionLinkHelper("https://api.example.com", oldActionLinkKey+","+newActionLinkKey, "new")
+	newOnlyHelper := helpers.NewActionLinkHelper("https://api.example.com", newActionLinkKey, "")
+
+	token, err := oldHelper.GenerateToken(enums.MailVerification, "jane@example.com", "123456", time.Now().Add(time.Hour))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := rotatedHelper.ParseToken(token, enums.MailVerification); err != nil {
+		t.Errorf("expected links of the old key to work during the rotation, got %v", err)
+	}
+
+	if _, err := newOnlyHelper.ParseToken(token, enums.MailVerification); !errors.Is(err, helpers.ErrInvalidActionLink) {
+		t.Errorf("expected links of a removed key to be invalid, got %v", err)
+	}
+
+	rotatedToken, err := rotatedHelper.GenerateToken(enums.MailVerification, "jane@example.com", "123456", time.Now().Add(time.Hour))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !strings.HasPrefix(rotatedToken, "v1.new.") {
+		t.Errorf("expected the signing key id to pick the key, got %s", rotatedToken)
+	}
+}
+
+func TestActionLinkConfiguration(t *testing.T) {
+	tests := []struct {
+		name         string
+		keys         string
+		signingKeyId string
+	}{
+		{name: "no keys", keys: ""},
+		{name: "short key", keys: "short:" + base64.StdEncoding.EncodeToString([]byte("secret"))},
+		{name: "not base64", keys: "raw:not base64 at all"},
+		{name: "unknown signing key", keys: oldActionLinkKey, signingKeyId: "missing"},
+		{name: "several keys without signing key", keys: oldActionLinkKey + "," + newActionLinkKey},
+	}
+
+	for _, test := range tests {
+		actionLinkHelper := helpers.NewActionLinkHelper("https://api.example.com", test.keys, test.signingKeyId)
+
+		if _, err := actionLinkHelper.GenerateToken(enums.MailVerification, "jane@example.com", "123456", time.Now().Add(time.Hour)); err == nil {
+			t.Errorf("%s: expected a configuration error", test.name)
+		}
+	}
+}
+
+func TestActionLinkUrl(t *testing.T) {
+	actionLinkHelper := helpers.NewActionLinkHelper("https://api.example.com/", oldActionLinkKey, "")
+
+	link, err := actionLinkHelper.GenerateLink(enums.MagicLinkLogin, "jane@example.com", "abc", time.Now().Add(time.Hour), url.Values{helpers.SessionModeQuery: {helpers.CookieSessionMode}})
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	parsedLink, err := url.Parse(link)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if parsedLink.Host != "api.example.com" || parsedLink.Path != helpers.ActionLinkPaths[enums.MagicLinkLogin] {
+		t.Errorf("unexpected link %s", link)
+	}
+
+	if parsedLink.Query().Get(helpers.SessionModeQuery) != helpers.CookieSessionMode {
+		t.Errorf("expected the extra query params to be kept, got %s", link)
+	}
+
+	if _, err := actionLinkHelper.ParseToken(parsedLink.Query().Get(helpers.ActionLinkTokenQuery), enums.MagicLinkLogin); err != nil {
+		t.Errorf("expected the token of the link to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package tests_helpers
+
+import (
+	"nft-raffle/helpers"
+	"strings"
+	"testing"
+)
+
+func TestGenerateApiKey(t *testing.T) {
+	apiKeyHelper := helpers.NewApiKeyHelper()
+
+	apiKey, prefix, keyHash, err := apiKeyHelper.GenerateApiKey()
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !strings.HasPrefix(apiKey, prefix) || !strings.HasPrefix(apiKey, "nfr_") {
+		t.Errorf("expected %s to start with display prefix %s", apiKey, prefix)
+	}
+
+	if keyHash != apiKeyHelper.HashApiKey(apiKey) || strings.Contains(keyHash, apiKey) {
+		t.Error("expected the stored hash to be derived from the key without containing it")
+	}
+
+	otherApiKey, _, otherKeyHash, err := apiKeyHelper.GenerateApiKey()
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if otherApiKey == apiKey || otherKeyHash == keyHash {
+		t.Error("expected every generated api key to be unique")
+	}
+}
+
+func TestValidateApiKeyRejectsUnknownFormat(t *testing.T) {
+	if _, err := helpers.NewApiKeyHelper().ValidateApiKey("not-an-api-key"); err != helpers.ErrInvalidApiKey {
+		t.Errorf("expected ErrInvalidApiKey, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package tests_helpers
+
+import (
+	"nft-raffle/enums"
+	"nft-raffle/helpers"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+var testAttemptLimitConfig = helpers.AttemptLimitConfig{
+	MaxFailedLoginsPerAccount: 4,
+	MaxFailedLoginsPerIp:      6,
+	FailedLoginWindow:         15 * time.Minute,
+	LoginLockout:              10 * time.Minute,
+	MaxMailCodeAttempts:       3,
+}
+
+func newTestAttemptLimitHelper(t *testing.T) (helpers.IAttemptLimitHelper, *miniredis.Miniredis) {
+	redisServer := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return helpers.NewAttemptLimitHelperWithConfig(client, testAttemptLimitConfig), redisServer
+}
+
+func TestRecordFailedLogin(t *testing.T) {
+	tests := []struct {
+		name               string
+		failures           int
+		expectedIsLocked   bool
+		expectedRetryAfter time.Duration
+	}{
+		{name: "free attempts are not delayed", failures: 2, expectedIsLocked: false, expectedRetryAfter: 0},
+		{name: "first delayed attempt", failures: 3, expectedIsLocked: false, expectedRetryAfter: time.Second},
+		{name: "lockout threshold", failures: 4, expectedIsLocked: true, expectedRetryAfter: testAttemptLimitConfig.LoginLockout},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attemptLimitHelper, _ := newTestAttemptLimitHelper(t)
+
+			var isLocked bool
+			var err error
+
+			for i := 0; i < test.failures; i++ {
+				isLocked, err = attemptLimitHelper.RecordFailedLogin("User@Example.com ", "127.0.0.1")
+
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if isLocked != test.expectedIsLocked {
+				t.Errorf("got locked %v, expected %v", isLocked, test.expectedIsLocked)
+			}
+
+			// the email is normalized, the casing of the next attempt does not matter
+			retryAfter, err := attemptLimitHelper.GetLoginRetryAfter("user@example.com", "127.0.0.1")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if retryAfter != test.expectedRetryAfter {
+				t.Errorf("got retry after %v, expected %v", retryAfter, test.expectedRetryAfter)
+			}
+		})
+	}
+}
+
+func TestRecordFailedLoginLocksTheIp(t *testing.T) {
+	attemptLimitHelper, _ := newTestAttemptLimitHelper(t)
+
+	// failures spread over accounts, none of them reaches the account threshold
+	for i := 0; i < testAttemptLimitConfig.MaxFailedLoginsPerIp; i++ {
+		if _, err := attemptLimitHelper.RecordFailedLogin(string(rune('a'+i))+"@example.com", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	retryAfter, err := attemptLimitHelper.GetLoginRetryAfter("other@example.com", "10.0.0.1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if retryAfter != testAttemptLimitConfig.LoginLockout {
+		t.Errorf("got retry after %v, expected the ip to be locked for %v", retryAfter, testAttemptLimitConfig.LoginLockout)
+	}
+
+	retryAfter, err = attemptLimitHelper.GetLoginRetryAfter("other@example.com", "10.0.0.2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if retryAfter != 0 {
+		t.Errorf("got retry after %v, another ip should not be locked", retryAfter)
+	}
+}
+
+func TestLoginLockoutExpires(t *testing.T) {
+	attemptLimitHelper, redisServer := newTestAttemptLimitHelper(t)
+
+	for i := 0; i < testAttemptLimitConfig.MaxFailedLoginsPerAccount; i++ {
+		if _, err := attemptLimitHelper.RecordFailedLogin("user@example.com", "127.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	redisServer.FastForward(testAttemptLimitConfig.LoginLockout)
+
+	retryAfter, err := attemptLimitHelper.GetLoginRetryAfter("user@example.com", "127.0.0.1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if retryAfter != 0 {
+		t.Errorf("got retry after %v, the lockout should have expired", retryAfter)
+	}
+
+	// the lockout cleared the failures, the next one is a free attempt again
+	isLocked, err := attemptLimitHelper.RecordFailedLogin("user@example.com", "127.0.0.1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isLocked {
+		t.Error("the first failure after the lockout should not lock the account")
+	}
+}
+
+func TestResetFailedLogins(t *testing.T) {
+	attemptLimitHelper, _ := newTestAttemptLimitHelper(t)
+
+	for i := 0; i < testAttemptLimitConfig.MaxFailedLoginsPerAccount-1; i++ {
+		if _, err := attemptLimitHelper.RecordFailedLogin("user@example.com", "127.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := attemptLimitHelper.ResetFailedLogins("user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	retryAfter, err := attemptLimitHelper.GetLoginRetryAfter("user@example.com", "127.0.0.1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if retryAfter != 0 {
+		t.Errorf("got retry after %v, a successful login should clear the delay", retryAfter)
+	}
+
+	isLocked, err := attemptLimitHelper.RecordFailedLogin("user@example.com", "127.0.0.1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isLocked {
+		t.Error("the failures before the successful login should not count anymore")
+	}
+}
+
+func TestRecordFailedMailCodeAttempt(t *testing.T) {
+	attemptLimitHelper, redisServer := newTestAttemptLimitHelper(t)
+
+	for i := 1; i <= testAttemptLimitConfig.MaxMailCodeAttempts; i++ {
+		isCodeInvalidated, err := attemptLimitHelper.RecordFailedMailCodeAttempt(enums.PasswordReset, "user@example.com")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := i == testAttemptLimitConfig.MaxMailCodeAttempts; isCodeInvalidated != expected {
+			t.Errorf("attempt %d: got invalidated %v, expected %v", i, isCodeInvalidated, expected)
+		}
+	}
+
+	// the counter starts over for the next code
+	isCodeInvalidated, err := attemptLimitHelper.RecordFailedMailCodeAttempt(enums.PasswordReset, "user@example.com")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isCodeInvalidated {
+		t.Error("the counter should have been cleared once the code was invalidated")
+	}
+
+	// counters are kept per mail type
+	if _, err := attemptLimitHelper.RecordFailedMailCodeAttempt(enums.EmailChange, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := attemptLimitHelper.ResetMailCodeAttempts(enums.PasswordReset, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(redisServer.Keys()) != 1 {
+		t.Errorf("got keys %v, expected only the email change counter to remain", redisServer.Keys())
+	}
+
+	redisServer.FastForward(24 * time.Hour)
+
+	if len(redisServer.Keys()) != 0 {
+		t.Errorf("got keys %v, expected the counters to expire", redisServer.Keys())
+	}
+}
+
+func TestReserveMailSend(t *testing.T) {
+	attemptLimitHelper, redisServer := newTestAttemptLimitHelper(t)
+
+	retryAfter, err := attemptLimitHelper.ReserveMailSend(enums.MailVerification, "user@example.com", time.Minute, 2)
+
+	if err != nil || retryAfter != 0 {
+		t.Fatalf("first send should be reserved, got %v %v", retryAfter, err)
+	}
+
+	retryAfter, err = attemptLimitHelper.ReserveMailSend(enums.MailVerification, "user@example.com", time.Minute, 2)
+
+	if err != nil || retryAfter != time.Minute {
+		t.Errorf("send during the cooldown should wait %v, got %v %v", time.Minute, retryAfter, err)
+	}
+
+	redisServer.FastForward(time.Minute)
+
+	retryAfter, err = attemptLimitHelper.ReserveMailSend(enums.MailVerification, "user@example.com", time.Minute, 2)
+
+	if err != nil || retryAfter != 0 {
+		t.Errorf("second send after the cooldown should be reserved, got %v %v", retryAfter, err)
+	}
+
+	redisServer.FastForward(time.Minute)
+
+	retryAfter, err = attemptLimitHelper.ReserveMailSend(enums.MailVerification, "user@example.com", time.Minute, 2)
+
+	if err != nil || retryAfter <= time.Minute {
+		t.Errorf("third send should hit the daily cap, got %v %v", retryAfter, err)
+	}
+}
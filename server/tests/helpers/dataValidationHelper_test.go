@@ -17,3 +17,36 @@ func TestIsEmailValid(t *testing.T) {
 		t.Error(err.Error())
 	}
 }
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	dataValidationHelper := helpers.NewDataValidationHelperWithCountryCode("60")
+
+	valid := map[string]string{
+		"+1 (415) 555-2671": "+14155552671",
+		"0044 20 7946 0958": "+442079460958",
+		"012-345 6789":      "+60123456789",
+		"+60.12.345.6789":   "+60123456789",
+	}
+
+	for phone, expected := range valid {
+		normalized, err := dataValidationHelper.NormalizePhoneNumber(phone)
+
+		if err != nil {
+			t.Errorf("%s: %s", phone, err.Error())
+		} else if normalized != expected {
+			t.Errorf("%s: expected %s, got %s", phone, expected, normalized)
+		}
+	}
+
+	invalid := []string{"", "+0123456789", "+1234567", "+1234567890123456", "+1 415 555 2671 ext 5", "4155552671", "+1+4155552671"}
+
+	for _, phone := range invalid {
+		if normalized, err := dataValidationHelper.NormalizePhoneNumber(phone); err == nil {
+			t.Errorf("%q: expected an error, got %s", phone, normalized)
+		}
+	}
+
+	if _, err := helpers.NewDataValidationHelperWithCountryCode("").NormalizePhoneNumber("0123456789"); err == nil {
+		t.Error("expected a national number to be rejected without a default country code")
+	}
+}
@@ -0,0 +1,139 @@
+package tests_helpers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"nft-raffle/helpers"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func writeJwtKeyPem(t *testing.T, dir, kid, pemType string, der []byte) {
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: der})
+
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), pemBytes, 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func createJwtKeysDir(t *testing.T) string {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	rsaDer, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+	writeJwtKeyPem(t, dir, "rsa-2023", "PRIVATE KEY", rsaDer)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	edDer, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	writeJwtKeyPem(t, dir, "ed-2024", "PRIVATE KEY", edDer)
+
+	// retired key, verification only
+	retiredKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	retiredDer, _ := x509.MarshalPKIXPublicKey(&retiredKey.PublicKey)
+	writeJwtKeyPem(t, dir, "rsa-2022", "PUBLIC KEY", retiredDer)
+
+	return dir
+}
+
+func TestJwtKeySetSignAndVerify(t *testing.T) {
+	dir := createJwtKeysDir(t)
+
+	for _, kid := range []string{"rsa-2023", "ed-2024"} {
+		keySet, err := helpers.LoadJwtKeySet(dir, kid)
+
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		signedToken, err := keySet.SignedString(&helpers.SignedDetails{Uid: "123"})
+
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		token, err := jwt.ParseWithClaims(signedToken, &helpers.SignedDetails{}, keySet.Keyfunc)
+
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if token.Header["kid"] != kid {
+			t.Errorf("expected kid %s, got %v", kid, token.Header["kid"])
+		}
+
+		if token.Claims.(*helpers.SignedDetails).Uid != "123" {
+			t.Error("uid claim not matching")
+		}
+	}
+}
+
+func TestJwtKeySetRotation(t *testing.T) {
+	dir := createJwtKeysDir(t)
+
+	oldKeySet, _ := helpers.LoadJwtKeySet(dir, "rsa-2023")
+	newKeySet, _ := helpers.LoadJwtKeySet(dir, "ed-2024")
+
+	// tokens signed before the switch still verify with the new signing key configured
+	signedToken, _ := oldKeySet.SignedString(&helpers.SignedDetails{Uid: "123"})
+
+	if _, err := jwt.ParseWithClaims(signedToken, &helpers.SignedDetails{}, newKeySet.Keyfunc); err != nil {
+		t.Error(err.Error())
+	}
+
+	if _, err := helpers.LoadJwtKeySet(dir, "rsa-2022"); err == nil {
+		t.Error("public key must not be accepted as signing key")
+	}
+}
+
+func TestJwtKeySetRejectsHmacWithKid(t *testing.T) {
+	dir := createJwtKeysDir(t)
+	keySet, _ := helpers.LoadJwtKeySet(dir, "rsa-2023")
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &helpers.SignedDetails{Uid: "123"})
+	token.Header["kid"] = "rsa-2023"
+	signedToken, _ := token.SignedString([]byte("secret"))
+
+	if _, err := jwt.ParseWithClaims(signedToken, &helpers.SignedDetails{}, keySet.Keyfunc); err == nil {
+		t.Error("HS256 token must be rejected by the key set")
+	}
+}
+
+func TestJwtKeySetJwks(t *testing.T) {
+	dir := createJwtKeysDir(t)
+	keySet, _ := helpers.LoadJwtKeySet(dir, "rsa-2023")
+
+	jwks := keySet.Jwks()
+
+	if len(jwks.Keys) != 3 {
+		t.Fatalf("expected 3 keys, got %d", len(jwks.Keys))
+	}
+
+	for _, jwk := range jwks.Keys {
+		switch jwk.Kid {
+		case "ed-2024":
+			if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.Alg != "EdDSA" || jwk.X == "" {
+				t.Errorf("unexpected jwk %+v", jwk)
+			}
+		default:
+			if jwk.Kty != "RSA" || jwk.Alg != "RS256" || jwk.N == "" || jwk.E != "AQAB" {
+				t.Errorf("unexpected jwk %+v", jwk)
+			}
+		}
+	}
+}
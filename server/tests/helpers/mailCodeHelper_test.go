@@ -0,0 +1,59 @@
+package tests_helpers
+
+import (
+	"errors"
+	"nft-raffle/enums"
+	"nft-raffle/helpers"
+	"testing"
+)
+
+func TestMailCodeHash(t *testing.T) {
+	mailCodeHelper := helpers.NewMailCodeHelper("secret")
+
+	codeHash, err := mailCodeHelper.HashCode(enums.PasswordReset, "Jane@Example.com", "123456")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if codeHash == "123456" {
+		t.Error("code is stored in plaintext")
+	}
+
+	if !mailCodeHelper.IsCodeValid(codeHash, enums.PasswordReset, "jane@example.com", "123456") {
+		t.Error("expected the code to be valid, the address is not case sensitive")
+	}
+
+	invalid := []struct {
+		name     string
+		mailType enums.MailType
+		email    string
+		code     string
+	}{
+		{name: "wrong code", mailType: enums.PasswordReset, email: "jane@example.com", code: "654321"},
+		{name: "other mail type", mailType: enums.MailVerification, email: "jane@example.com", code: "123456"},
+		{name: "other address", mailType: enums.PasswordReset, email: "john@example.com", code: "123456"},
+	}
+
+	for _, test := range invalid {
+		if mailCodeHelper.IsCodeValid(codeHash, test.mailType, test.email, test.code) {
+			t.Errorf("%s: expected the code to be invalid", test.name)
+		}
+	}
+
+	if helpers.NewMailCodeHelper("other secret").IsCodeValid(codeHash, enums.PasswordReset, "jane@example.com", "123456") {
+		t.Error("expected the hash to depend on the secret")
+	}
+}
+
+func TestMailCodeHashNotConfigured(t *testing.T) {
+	mailCodeHelper := helpers.NewMailCodeHelper("")
+
+	if _, err := mailCodeHelper.HashCode(enums.PasswordReset, "jane@example.com", "123456"); !errors.Is(err, helpers.ErrMailCodeNotConfigured) {
+		t.Errorf("expected ErrMailCodeNotConfigured, got %v", err)
+	}
+
+	if mailCodeHelper.IsCodeValid("", enums.PasswordReset, "jane@example.com", "123456") {
+		t.Error("expected no code to be valid without a secret")
+	}
+}
@@ -0,0 +1,121 @@
+package tests_helpers
+
+import (
+	"errors"
+	"net/url"
+	"nft-raffle/enums"
+	"nft-raffle/helpers"
+	"nft-raffle/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMailPreferenceDefaults(t *testing.T) {
+	mailPreferenceHelper := helpers.NewMailPreferenceHelper(helpers.NewActionLinkHelper("https://api.example.com", oldActionLinkKey, ""))
+
+	tests := []struct {
+		name     string
+		user     models.User
+		expected map[string]bool
+	}{
+		{"no saved preference", models.User{}, map[string]bool{
+			enums.SecurityMail.String():      true,
+			enums.RaffleResultsMail.String(): true,
+			enums.MarketingMail.String():     false,
+			enums.DigestMail.String():        false,
+		}},
+		{"opted in the digest and out of security mails", models.User{Mail_preferences: map[string]bool{
+			enums.DigestMail.String():   true,
+			enums.SecurityMail.String(): false,
+		}}, map[string]bool{
+			enums.SecurityMail.String():      true,
+			enums.RaffleResultsMail.String(): true,
+			enums.MarketingMail.String():     false,
+			enums.DigestMail.String():        true,
+		}},
+	}
+
+	for _, test := range tests {
+		for category, isEnabled := range mailPreferenceHelper.GetPreferences(test.user) {
+			if test.expected[category] != isEnabled {
+				t.Errorf("%s, %s: expected %v, got %v", test.name, category, test.expected[category], isEnabled)
+			}
+		}
+	}
+}
+
+func TestUnsubscribeLink(t *testing.T) {
+	actionLinkHelper := helpers.NewActionLinkHelper("https://api.example.com", oldActionLinkKey, "")
+	mailPreferenceHelper := helpers.NewMailPreferenceHelper(actionLinkHelper)
+
+	link, err := mailPreferenceHelper.GenerateUnsubscribeLink("64b7f1c2a1b2c3d4e5f60718", enums.DigestMail)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	parsedLink, err := url.Parse(link)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if parsedLink.Host != "api.example.com" || parsedLink.Path != helpers.ActionLinkPaths[enums.UnsubscribeLink] {
+		t.Errorf("unexpected unsubscribe link %s", link)
+	}
+
+	token := parsedLink.Query().Get(helpers.ActionLinkTokenQuery)
+
+	if !strings.HasPrefix(token, "v1.old.") {
+		t.Errorf("expected an action link token, got %s", token)
+	}
+
+	userId, category, err := mailPreferenceHelper.ParseUnsubscribeToken(token)
+
+	if err != nil || userId != "64b7f1c2a1b2c3d4e5f60718" || category != enums.DigestMail {
+		t.Errorf("unexpected parse result %q %q %v", userId, category, err)
+	}
+
+	// the unsubscribe links follow the key rotation of the other action links
+	rotatedHelper := helpers.NewMailPreferenceHelper(helpers.NewActionLinkHelper("https://api.example.com", oldActionLinkKey+","+newActionLinkKey, "new"))
+
+	if _, _, err := rotatedHelper.ParseUnsubscribeToken(token); err != nil {
+		t.Errorf("expected links of the old key to work during the rotation, got %v", err)
+	}
+
+	if _, _, err := helpers.NewMailPreferenceHelper(helpers.NewActionLinkHelper("https://api.example.com", newActionLinkKey, "")).ParseUnsubscribeToken(token); !errors.Is(err, helpers.ErrInvalidUnsubscribeToken) {
+		t.Errorf("expected links of a removed key to be invalid, got %v", err)
+	}
+
+	// a link of another purpose signed by the same key is not an unsubscribe link
+	verificationToken, err := actionLinkHelper.GenerateToken(enums.MailVerification, "64b7f1c2a1b2c3d4e5f60718", enums.DigestMail.String(), time.Now().Add(time.Hour))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, invalid := range []string{"", "abc", token + "x", verificationToken} {
+		if _, _, err := mailPreferenceHelper.ParseUnsubscribeToken(invalid); !errors.Is(err, helpers.ErrInvalidUnsubscribeToken) {
+			t.Errorf("%q: expected invalid token, got %v", invalid, err)
+		}
+	}
+
+	securityToken, err := actionLinkHelper.GenerateToken(enums.UnsubscribeLink, "64b7f1c2a1b2c3d4e5f60718", enums.SecurityMail.String(), time.Now().Add(time.Hour))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, _, err := mailPreferenceHelper.ParseUnsubscribeToken(securityToken); !errors.Is(err, helpers.ErrInvalidUnsubscribeToken) {
+		t.Errorf("expected security mails to be refused, got %v", err)
+	}
+
+	if _, err := mailPreferenceHelper.GenerateUnsubscribeLink("64b7f1c2a1b2c3d4e5f60718", enums.SecurityMail); !errors.Is(err, helpers.ErrMailCategoryNotOptional) {
+		t.Errorf("expected security mails to have no unsubscribe link, got %v", err)
+	}
+
+	if _, err := helpers.NewMailPreferenceHelper(helpers.NewActionLinkHelper("https://api.example.com", "", "")).GenerateUnsubscribeLink("64b7f1c2a1b2c3d4e5f60718", enums.DigestMail); !errors.Is(err, helpers.ErrActionLinkNotConfigured) {
+		t.Errorf("expected missing keys error, got %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package tests_helpers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"nft-raffle/helpers"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+type mockOidcServer struct {
+	server       *httptest.Server
+	signingKey   *ecdsa.PrivateKey
+	kid          string
+	nonce        string
+	codeVerifier string
+	claims       jwt.MapClaims
+}
+
+func newMockOidcServer(t *testing.T) *mockOidcServer {
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	mock := &mockOidcServer{signingKey: signingKey, kid: "mock-1"}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 mock.server.URL,
+			"authorization_endpoint": mock.server.URL + "/authorize",
+			"token_endpoint":         mock.server.URL + "/token",
+			"jwks_uri":               mock.server.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		publicKey := mock.signingKey.PublicKey
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "EC",
+				"crv": "P-256",
+				"kid": mock.kid,
+				"use": "sig",
+				"x":   base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, 32))),
+				"y":   base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, 32))),
+			}},
+		})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		if r.Form.Get("code") != "good-code" || r.Form.Get("code_verifier") != mock.codeVerifier {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": mock.signIdToken(t, mock.claims)})
+	})
+
+	mock.server = httptest.NewServer(mux)
+	t.Cleanup(mock.server.Close)
+
+	mock.claims = jwt.MapClaims{
+		"iss":            mock.server.URL,
+		"aud":            "client-123",
+		"sub":            "subject-1",
+		"email":          "user@example.com",
+		"email_verified": true,
+		"given_name":     "Jane",
+		"family_name":    "Doe",
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+	}
+
+	return mock
+}
+
+func (m *mockOidcServer) signIdToken(t *testing.T, claims jwt.MapClaims) string {
+	claims["nonce"] = m.nonce
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = m.kid
+
+	signed, err := token.SignedString(m.signingKey)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return signed
+}
+
+func (m *mockOidcServer) provider() helpers.OidcProviderConfig {
+	return helpers.OidcProviderConfig{
+		Name:        "mock",
+		Issuer:      m.server.URL,
+		ClientId:    "client-123",
+		RedirectUrl: "http://localhost/api/auth/oidc/mock/callback",
+		Scopes:      []string{"openid", "email"},
+	}
+}
+
+func TestOidcAuthorizationCodeFlow(t *testing.T) {
+	mock := newMockOidcServer(t)
+	oidcHelper := helpers.NewOidcHelperWithProviders(map[string]helpers.OidcProviderConfig{"mock": mock.provider()}, mock.server.Client())
+
+	provider, ok := oidcHelper.GetProvider("MOCK")
+
+	if !ok {
+		t.Fatal("provider should be found case insensitively")
+	}
+
+	discovery, err := oidcHelper.Discover(provider)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	mock.nonce, _ = oidcHelper.GenerateRandomToken()
+	mock.codeVerifier, _ = oidcHelper.GenerateRandomToken()
+	codeChallenge := oidcHelper.GeneratePkceCodeChallenge(mock.codeVerifier)
+
+	authorizationUrl, err := url.Parse(oidcHelper.BuildAuthorizationUrl(provider, discovery, "state-1", mock.nonce, codeChallenge))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	query := authorizationUrl.Query()
+
+	if query.Get("code_challenge") != codeChallenge || query.Get("code_challenge_method") != "S256" || query.Get("nonce") != mock.nonce {
+		t.Errorf("unexpected authorization url %s", authorizationUrl)
+	}
+
+	if _, err := oidcHelper.ExchangeCode(provider, discovery, "good-code", "wrong-verifier"); err == nil {
+		t.Error("exchange with a wrong code verifier should fail")
+	}
+
+	idToken, err := oidcHelper.ExchangeCode(provider, discovery, "good-code", mock.codeVerifier)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	identity, err := oidcHelper.VerifyIdToken(provider, discovery, idToken, mock.nonce)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if identity.Subject != "subject-1" || identity.Email != "user@example.com" || !identity.Email_verified || identity.Given_name != "Jane" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+
+	if _, err := oidcHelper.VerifyIdToken(provider, discovery, idToken, "other-nonce"); err == nil {
+		t.Error("id token with a different nonce should be rejected")
+	}
+}
+
+func TestOidcVerifyIdTokenRejectsInvalidClaims(t *testing.T) {
+	mock := newMockOidcServer(t)
+	oidcHelper := helpers.NewOidcHelperWithProviders(map[string]helpers.OidcProviderConfig{"mock": mock.provider()}, mock.server.Client())
+
+	provider, _ := oidcHelper.GetProvider("mock")
+	discovery, err := oidcHelper.Discover(provider)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	mock.nonce = "nonce-1"
+
+	cases := map[string]func(claims jwt.MapClaims){
+		"wrong audience": func(claims jwt.MapClaims) { claims["aud"] = "someone-else" },
+		"wrong issuer":   func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" },
+		"expired":        func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+	}
+
+	for name, mutate := range cases {
+		claims := jwt.MapClaims{}
+
+		for key, value := range mock.claims {
+			claims[key] = value
+		}
+
+		mutate(claims)
+
+		if _, err := oidcHelper.VerifyIdToken(provider, discovery, mock.signIdToken(t, claims), "nonce-1"); err == nil {
+			t.Errorf("%s: id token should be rejected", name)
+		}
+	}
+
+	// HS256 signed with the public client id must never be accepted
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, mock.claims).SignedString([]byte("client-123"))
+
+	if _, err := oidcHelper.VerifyIdToken(provider, discovery, hmacToken, "nonce-1"); err == nil || !strings.Contains(err.Error(), "signing method") {
+		t.Errorf("hmac id token should be rejected, got %v", err)
+	}
+}
+
+func TestOidcVerifyIdTokenRefetchesRotatedKeys(t *testing.T) {
+	mock := newMockOidcServer(t)
+	oidcHelper := helpers.NewOidcHelperWithProviders(map[string]helpers.OidcProviderConfig{"mock": mock.provider()}, mock.server.Client())
+
+	provider, _ := oidcHelper.GetProvider("mock")
+	discovery, _ := oidcHelper.Discover(provider)
+	mock.nonce = "nonce-1"
+
+	if _, err := oidcHelper.VerifyIdToken(provider, discovery, mock.signIdToken(t, mock.claims), "nonce-1"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// provider rotates its key, the cached key set no longer knows the new kid
+	mock.signingKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	mock.kid = "mock-2"
+
+	if _, err := oidcHelper.VerifyIdToken(provider, discovery, mock.signIdToken(t, mock.claims), "nonce-1"); err != nil {
+		t.Errorf("rotated key should be picked up, got %v", err)
+	}
+}
+
+func TestOidcStateCookie(t *testing.T) {
+	oidcHelper := helpers.NewOidcHelperWithProviders(map[string]helpers.OidcProviderConfig{}, http.DefaultClient)
+
+	tests := []struct {
+		name        string
+		stateCookie string
+		state       string
+		expected    bool
+	}{
+		{"matching cookie", oidcHelper.HashState("state"), "state", true},
+		{"missing cookie", "", "state", false},
+		{"cookie of another login", oidcHelper.HashState("other-state"), "state", false},
+		{"raw state as cookie", "state", "state", false},
+		{"missing state", oidcHelper.HashState(""), "", false},
+	}
+
+	for _, test := range tests {
+		if got := oidcHelper.IsStateCookieValid(test.stateCookie, test.state); got != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package tests_helpers
+
+import (
+	"nft-raffle/helpers"
+	"strings"
+	"testing"
+)
+
+var (
+	fastBcryptConfig = helpers.PasswordHashConfig{
+		Algorithm:  helpers.BcryptAlgorithm,
+		BcryptCost: 4,
+	}
+	fastArgon2idConfig = helpers.PasswordHashConfig{
+		Algorithm:         helpers.Argon2idAlgorithm,
+		Argon2MemoryKib:   1024,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+	}
+)
+
+func TestPasswordHashAndVerify(t *testing.T) {
+	for _, config := range []helpers.PasswordHashConfig{fastBcryptConfig, fastArgon2idConfig} {
+		passwordHelper := helpers.NewPasswordHelperWithConfig(config)
+
+		hashedPassword, err := passwordHelper.HashPassword("11111111")
+
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if err := passwordHelper.VerifyPassword(hashedPassword, "11111111"); err != nil {
+			t.Errorf("%s: correct password should verify, got %v", config.Algorithm, err)
+		}
+
+		if err := passwordHelper.VerifyPassword(hashedPassword, "11111112"); err == nil {
+			t.Errorf("%s: wrong password should not verify", config.Algorithm)
+		}
+
+		if passwordHelper.NeedsRehash(hashedPassword) {
+			t.Errorf("%s: fresh hash should not need a rehash", config.Algorithm)
+		}
+	}
+}
+
+func TestPasswordArgon2idHashFormat(t *testing.T) {
+	hashedPassword, _ := helpers.NewPasswordHelperWithConfig(fastArgon2idConfig).HashPassword("11111111")
+
+	if !strings.HasPrefix(hashedPassword, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("unexpected argon2id hash %s", hashedPassword)
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	bcryptHash, _ := helpers.NewPasswordHelperWithConfig(fastBcryptConfig).HashPassword("11111111")
+	argon2idHash, _ := helpers.NewPasswordHelperWithConfig(fastArgon2idConfig).HashPassword("11111111")
+
+	argon2idHelper := helpers.NewPasswordHelperWithConfig(fastArgon2idConfig)
+
+	// switching algorithm keeps old hashes verifiable but marks them for upgrade
+	if err := argon2idHelper.VerifyPassword(bcryptHash, "11111111"); err != nil {
+		t.Errorf("bcrypt hash should still verify, got %v", err)
+	}
+
+	if !argon2idHelper.NeedsRehash(bcryptHash) {
+		t.Error("bcrypt hash should need a rehash when argon2id is configured")
+	}
+
+	strongerArgon2idConfig := fastArgon2idConfig
+	strongerArgon2idConfig.Argon2Iterations = 2
+
+	if !helpers.NewPasswordHelperWithConfig(strongerArgon2idConfig).NeedsRehash(argon2idHash) {
+		t.Error("argon2id hash with fewer iterations should need a rehash")
+	}
+
+	strongerBcryptConfig := fastBcryptConfig
+	strongerBcryptConfig.BcryptCost = 5
+
+	if !helpers.NewPasswordHelperWithConfig(strongerBcryptConfig).NeedsRehash(bcryptHash) {
+		t.Error("bcrypt hash with a lower cost should need a rehash")
+	}
+
+	// the fake controller repository stores a cost 14 bcrypt hash
+	if helpers.NewPasswordHelperWithConfig(helpers.PasswordHashConfig{Algorithm: helpers.BcryptAlgorithm, BcryptCost: 14}).
+		NeedsRehash("$2a$14$X7pxIBiQtS/SFhyOHo1aIO6PFTEY5.w2xHR84e.0nOi.kqwdiTylm") {
+		t.Error("hash matching the configuration should not need a rehash")
+	}
+}
+
+func TestPasswordVerifyRejectsMalformedHash(t *testing.T) {
+	passwordHelper := helpers.NewPasswordHelperWithConfig(fastArgon2idConfig)
+
+	for _, hashedPassword := range []string{"", "$argon2id$v=19$m=1024,t=1,p=1$bad", "$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$a2V5"} {
+		if err := passwordHelper.VerifyPassword(hashedPassword, "11111111"); err == nil {
+			t.Errorf("malformed hash %q should not verify", hashedPassword)
+		}
+	}
+}
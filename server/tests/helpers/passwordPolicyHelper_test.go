@@ -0,0 +1,92 @@
+package tests_helpers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"nft-raffle/helpers"
+	"strings"
+	"testing"
+)
+
+var defaultPasswordPolicyConfig = helpers.PasswordPolicyConfig{
+	MinLength:           10,
+	MaxLength:           128,
+	MinCharacterClasses: 3,
+}
+
+func getViolationCodes(violations []helpers.PasswordPolicyViolation) []string {
+	codes := []string{}
+
+	for _, violation := range violations {
+		codes = append(codes, violation.Code)
+	}
+
+	return codes
+}
+
+func TestPasswordPolicyAcceptsStrongPassword(t *testing.T) {
+	passwordPolicyHelper := helpers.NewPasswordPolicyHelperWithConfig(defaultPasswordPolicyConfig, nil)
+
+	if violations := passwordPolicyHelper.Validate("Correct-Horse-42", "jane@example.com", "Jane", "Doe"); len(violations) > 0 {
+		t.Errorf("strong password should be accepted, got %v", getViolationCodes(violations))
+	}
+}
+
+func TestPasswordPolicyViolations(t *testing.T) {
+	passwordPolicyHelper := helpers.NewPasswordPolicyHelperWithConfig(defaultPasswordPolicyConfig, nil)
+
+	cases := map[string]string{
+		"a":                       "too_short",
+		strings.Repeat("Ab1", 50): "too_long",
+		"alllowercaseletters":     "too_few_character_classes",
+		"Jane-Secret-2023":        "contains_personal_details",
+		"Pass-jane.smith-1":       "contains_personal_details",
+		"Doe-Is-Not-Allowed-1":    "contains_personal_details",
+	}
+
+	for password, expectedCode := range cases {
+		codes := getViolationCodes(passwordPolicyHelper.Validate(password, "jane.smith@example.com", "Jane", "Doe"))
+
+		if !strings.Contains(strings.Join(codes, ","), expectedCode) {
+			t.Errorf("%q: expected %s, got %v", password, expectedCode, codes)
+		}
+	}
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestPasswordPolicyBreachedPasswords(t *testing.T) {
+	breachedPassword := "Correct-Horse-42"
+
+	// lowercase and uppercase hashes, with and without counts, as found in downloadable lists
+	breachedHashes, err := helpers.LoadBreachedPasswordHashes(strings.NewReader(
+		"# breached password list\n" +
+			sha1Hex(breachedPassword) + ":3\n" +
+			strings.ToUpper(sha1Hex("Summer-Time-2023")) + "\n",
+	))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	passwordPolicyHelper := helpers.NewPasswordPolicyHelperWithConfig(defaultPasswordPolicyConfig, breachedHashes)
+
+	for _, password := range []string{breachedPassword, "Summer-Time-2023"} {
+		codes := getViolationCodes(passwordPolicyHelper.Validate(password, "jane@example.com", "Jane", "Doe"))
+
+		if strings.Join(codes, ",") != "breached" {
+			t.Errorf("%q: breached password should be rejected, got %v", password, codes)
+		}
+	}
+
+	if violations := passwordPolicyHelper.Validate("Another-Horse-43", "jane@example.com", "Jane", "Doe"); len(violations) > 0 {
+		t.Errorf("password not in the list should be accepted, got %v", getViolationCodes(violations))
+	}
+
+	if _, err := helpers.LoadBreachedPasswordHashes(strings.NewReader("not-a-hash\n")); err == nil {
+		t.Error("malformed list should fail to load")
+	}
+}
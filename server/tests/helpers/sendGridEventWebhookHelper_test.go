@@ -0,0 +1,115 @@
+package tests_helpers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"nft-raffle/helpers"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signSendGridEvents(t *testing.T, privateKey *ecdsa.PrivateKey, payload []byte, timestamp string) string {
+	hash := sha256.Sum256(append([]byte(timestamp), payload...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, privateKey, hash[:])
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func TestSendGridEventWebhookVerifySignature(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	webhookHelper := helpers.NewSendGridEventWebhookHelper(base64.StdEncoding.EncodeToString(der))
+
+	payload := []byte(`[{"email":"jane@example.com","event":"bounce","sg_event_id":"abc"}]`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := signSendGridEvents(t, privateKey, payload, timestamp)
+
+	if err := webhookHelper.VerifySignature(payload, signature, timestamp); err != nil {
+		t.Errorf("expected valid signature, got %s", err.Error())
+	}
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+		timestamp string
+	}{
+		{"tampered payload", []byte(`[{"email":"john@example.com","event":"bounce","sg_event_id":"abc"}]`), signature, timestamp},
+		{"other timestamp", payload, signature, strconv.FormatInt(time.Now().Unix()+1, 10)},
+		{"missing signature", payload, "", timestamp},
+		{"garbage signature", payload, "bm90IGEgc2lnbmF0dXJl", timestamp},
+	}
+
+	for _, test := range tests {
+		if err := webhookHelper.VerifySignature(test.payload, test.signature, test.timestamp); !errors.Is(err, helpers.ErrInvalidEventWebhookSignature) {
+			t.Errorf("%s: expected invalid signature, got %v", test.name, err)
+		}
+	}
+}
+
+func TestSendGridEventWebhookRejectsReplayedBatches(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	webhookHelper := helpers.NewSendGridEventWebhookHelper(base64.StdEncoding.EncodeToString(der))
+	payload := []byte(`[{"email":"jane@example.com","event":"bounce","sg_event_id":"abc"}]`)
+
+	tests := []struct {
+		name     string
+		age      time.Duration
+		expected error
+	}{
+		{"recent batch", 2 * time.Minute, nil},
+		{"clock slightly ahead", -2 * time.Minute, nil},
+		{"replayed batch", 11 * time.Minute, helpers.ErrEventWebhookTimestampExpired},
+		{"timestamp in the future", -11 * time.Minute, helpers.ErrEventWebhookTimestampExpired},
+	}
+
+	for _, test := range tests {
+		// the signature is valid, only the timestamp decides
+		timestamp := strconv.FormatInt(time.Now().Add(-test.age).Unix(), 10)
+		signature := signSendGridEvents(t, privateKey, payload, timestamp)
+
+		if err := webhookHelper.VerifySignature(payload, signature, timestamp); !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestSendGridEventWebhookWithoutKey(t *testing.T) {
+	err := helpers.NewSendGridEventWebhookHelper("").VerifySignature([]byte("[]"), "c2ln", "1700000000")
+
+	if !errors.Is(err, helpers.ErrEventWebhookNotConfigured) {
+		t.Errorf("expected not configured error, got %v", err)
+	}
+}
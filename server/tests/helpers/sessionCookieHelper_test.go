@@ -0,0 +1,72 @@
+package tests_helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nft-raffle/helpers"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newSessionCookieTestContext(method string, cookies map[string]string, headers map[string]string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(method, "/api/user/me", nil)
+
+	for name, value := range cookies {
+		c.Request.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+
+	for name, value := range headers {
+		c.Request.Header.Set(name, value)
+	}
+
+	return c
+}
+
+func TestValidateCsrfToken(t *testing.T) {
+	sessionCookieHelper := helpers.NewSessionCookieHelper()
+
+	tests := []struct {
+		name     string
+		method   string
+		cookies  map[string]string
+		headers  map[string]string
+		expected bool
+	}{
+		{"safe method", http.MethodGet, nil, nil, true},
+		{"matching header", http.MethodPost, map[string]string{helpers.CsrfTokenCookie: "token"}, map[string]string{helpers.CsrfTokenHeader: "token"}, true},
+		{"missing header", http.MethodPost, map[string]string{helpers.CsrfTokenCookie: "token"}, nil, false},
+		{"mismatched header", http.MethodDelete, map[string]string{helpers.CsrfTokenCookie: "token"}, map[string]string{helpers.CsrfTokenHeader: "other"}, false},
+		{"missing cookie", http.MethodPatch, nil, map[string]string{helpers.CsrfTokenHeader: "token"}, false},
+	}
+
+	for _, test := range tests {
+		c := newSessionCookieTestContext(test.method, test.cookies, test.headers)
+
+		if got := sessionCookieHelper.ValidateCsrfToken(c); got != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestIsCookieMode(t *testing.T) {
+	sessionCookieHelper := helpers.NewSessionCookieHelper()
+
+	if sessionCookieHelper.IsCookieMode(newSessionCookieTestContext(http.MethodPost, nil, nil)) {
+		t.Error("expected bearer mode without opt in")
+	}
+
+	if !sessionCookieHelper.IsCookieMode(newSessionCookieTestContext(http.MethodPost, nil, map[string]string{helpers.SessionModeHeader: "cookie"})) {
+		t.Error("expected cookie mode from header")
+	}
+
+	c := newSessionCookieTestContext(http.MethodGet, nil, nil)
+	c.Set(helpers.IsCookieSessionKey, true)
+
+	if !sessionCookieHelper.IsCookieMode(c) {
+		t.Error("expected cookie mode from context")
+	}
+}
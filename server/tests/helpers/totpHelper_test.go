@@ -0,0 +1,84 @@
+package tests_helpers
+
+import (
+	"encoding/base32"
+	"nft-raffle/helpers"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	totpHelper helpers.ITotpHelper = helpers.TotpHelper
+)
+
+// RFC 6238 appendix B, SHA1 seed "12345678901234567890", truncated to 6 digits
+func TestGenerateCodeRfc6238(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	testCases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range testCases {
+		code, err := totpHelper.GenerateCode(secret, time.Unix(unix, 0))
+
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		if code != expected {
+			t.Errorf("code at %d is %s, expected %s", unix, code, expected)
+		}
+	}
+}
+
+func TestValidateCodeWithClockDrift(t *testing.T) {
+	secret, err := totpHelper.GenerateSecret()
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	now := time.Now()
+	previousCode, _ := totpHelper.GenerateCode(secret, now.Add(-30*time.Second))
+
+	if _, ok := totpHelper.ValidateCode(secret, previousCode, now); !ok {
+		t.Error("code from previous time step should be accepted")
+	}
+
+	oldCode, _ := totpHelper.GenerateCode(secret, now.Add(-90*time.Second))
+
+	if _, ok := totpHelper.ValidateCode(secret, oldCode, now); ok {
+		t.Error("code from three time steps ago should be rejected")
+	}
+}
+
+func TestGenerateOtpAuthUri(t *testing.T) {
+	uri := totpHelper.GenerateOtpAuthUri("JBSWY3DPEHPK3PXP", "testingaaa@gmail.com")
+
+	if !strings.HasPrefix(uri, "otpauth://totp/") || !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Errorf("unexpected otpauth uri %s", uri)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	recoveryCodes, err := totpHelper.GenerateRecoveryCodes()
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if len(recoveryCodes) != 10 {
+		t.Error("expected 10 recovery codes")
+	}
+
+	if totpHelper.HashRecoveryCode(recoveryCodes[0]) != totpHelper.HashRecoveryCode(" "+strings.ToUpper(recoveryCodes[0])) {
+		t.Error("recovery code hash should ignore case and surrounding spaces")
+	}
+}
@@ -0,0 +1,65 @@
+package tests_services
+
+import (
+	"nft-raffle/dto"
+	"nft-raffle/services"
+	"reflect"
+	"testing"
+)
+
+func TestMailRecipientPolicy(t *testing.T) {
+	tos := []dto.MailAddress{
+		{Name: "Jane Doe", Address: "jane@example.com"},
+		{Name: "John Doe", Address: "john@Team.Example.org"},
+		{Name: "Max Doe", Address: "max@gmail.com"},
+	}
+
+	tests := []struct {
+		name               string
+		config             services.MailRecipientPolicyConfig
+		expectedRecipients []dto.MailAddress
+		expectedOriginals  []string
+	}{
+		{
+			name:               "deliver by default",
+			config:             services.MailRecipientPolicyConfig{},
+			expectedRecipients: tos,
+		},
+		{
+			name:               "redirect everything",
+			config:             services.MailRecipientPolicyConfig{Policy: "redirect", RedirectAddress: "catch-all@example.net"},
+			expectedRecipients: []dto.MailAddress{{Name: "Jane Doe", Address: "catch-all@example.net"}},
+			expectedOriginals:  []string{"jane@example.com", "john@Team.Example.org", "max@gmail.com"},
+		},
+		{
+			name: "redirect outside the allowlist",
+			config: services.MailRecipientPolicyConfig{
+				Policy:          "REDIRECT",
+				RedirectAddress: "catch-all@example.net",
+				AllowedDomains:  []string{" team.example.org", ""},
+			},
+			expectedRecipients: []dto.MailAddress{
+				{Name: "Jane Doe", Address: "catch-all@example.net"},
+				{Name: "John Doe", Address: "john@Team.Example.org"},
+			},
+			expectedOriginals: []string{"jane@example.com", "max@gmail.com"},
+		},
+		{
+			name:              "drop without a catch-all address",
+			config:            services.MailRecipientPolicyConfig{Policy: "redirect"},
+			expectedOriginals: []string{"jane@example.com", "john@Team.Example.org", "max@gmail.com"},
+		},
+	}
+
+	for _, test := range tests {
+		recipients, originalTos := services.NewMailRecipientPolicy(test.config).Apply(tos)
+
+		if !reflect.DeepEqual(recipients, test.expectedRecipients) {
+			t.Errorf("%s: expected recipients %v, got %v", test.name, test.expectedRecipients, recipients)
+		}
+
+		if !reflect.DeepEqual(originalTos, test.expectedOriginals) {
+			t.Errorf("%s: expected original recipients %v, got %v", test.name, test.expectedOriginals, originalTos)
+		}
+	}
+}
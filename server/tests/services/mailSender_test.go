@@ -0,0 +1,168 @@
+package tests_services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"nft-raffle-mail/mailtransport"
+	"nft-raffle/dto"
+	"nft-raffle/enums"
+	"nft-raffle/services"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestMailRequest(mailType enums.MailType) *dto.MailRequest {
+	return &dto.MailRequest{
+		FromName:  "Expense Tracker",
+		FromEmail: "no-reply@example.com",
+		MailType:  mailType,
+		Tos:       []dto.MailAddress{{Name: "Jane Doe", Address: "jane@example.com"}},
+		DynamicTemplateData: map[string]string{
+			"Full_Name":        "Jane Doe",
+			"Verify_Mail_Link": "http://localhost:3000/verify?code=abc",
+		},
+	}
+}
+
+func TestFileMailSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mailbox")
+
+	if err := services.NewMailSenderWithTransport(&mailtransport.FileTransport{Dir: dir}).Send(newTestMailRequest(enums.MailVerification)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	files, err := os.ReadDir(dir)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(files) != 1 || !strings.Contains(files[0].Name(), "-MailVerification-") || !strings.HasSuffix(files[0].Name(), ".eml") {
+		t.Fatalf("expected one MailVerification .eml file, got %v", files)
+	}
+
+	message, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, expected := range []string{
+		"To: \"Jane Doe\" <jane@example.com>\r\n",
+		"Subject: Verify your email address\r\n",
+		"Hi Jane Doe,",
+		"http://localhost:3000/verify?code=3Dabc",
+	} {
+		if !strings.Contains(string(message), expected) {
+			t.Errorf("expected message to contain %q, got:\n%s", expected, string(message))
+		}
+	}
+}
+
+func TestSendGridMailSender(t *testing.T) {
+	var body struct {
+		TemplateId       string `json:"template_id"`
+		Personalizations []struct {
+			To []struct {
+				Email string `json:"email"`
+			} `json:"to"`
+			DynamicTemplateData map[string]string `json:"dynamic_template_data"`
+		} `json:"personalizations"`
+	}
+	var authorization string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		raw, _ := io.ReadAll(r.Body)
+
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Error(err.Error())
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	mailSender := services.NewMailSenderWithTransport(&mailtransport.SendGridTransport{
+		ApiKey:      "SG.test",
+		Url:         server.URL + "/v3/mail/send",
+		TemplateIds: map[string]string{"MailVerification": "d-verification"},
+
+		UseDynamicTemplates: true,
+	})
+
+	if err := mailSender.Send(newTestMailRequest(enums.MailVerification)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if authorization != "Bearer SG.test" || body.TemplateId != "d-verification" {
+		t.Errorf("unexpected request %q %q", authorization, body.TemplateId)
+	}
+
+	if len(body.Personalizations) != 1 || len(body.Personalizations[0].To) != 1 || body.Personalizations[0].To[0].Email != "jane@example.com" {
+		t.Fatalf("unexpected personalizations %+v", body.Personalizations)
+	}
+
+	if body.Personalizations[0].DynamicTemplateData["Full_Name"] != "Jane Doe" {
+		t.Errorf("unexpected dynamic template data %v", body.Personalizations[0].DynamicTemplateData)
+	}
+}
+
+func TestSendGridMailSenderWithLocalTemplates(t *testing.T) {
+	var body struct {
+		TemplateId string `json:"template_id"`
+		Subject    string `json:"subject"`
+		Content    []struct {
+			Type  string `json:"type"`
+			Value string `json:"value"`
+		} `json:"content"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Error(err.Error())
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	mailSender := services.NewMailSenderWithTransport(&mailtransport.SendGridTransport{
+		ApiKey:      "SG.test",
+		Url:         server.URL + "/v3/mail/send",
+		TemplateIds: map[string]string{"MailVerification": "d-verification"},
+	})
+
+	mailRequest := newTestMailRequest(enums.MailVerification)
+	mailRequest.Locale = "es-ES"
+
+	if err := mailSender.Send(mailRequest); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if body.TemplateId != "" || body.Subject != "Verifica tu dirección de correo" {
+		t.Errorf("unexpected template %q and subject %q", body.TemplateId, body.Subject)
+	}
+
+	if len(body.Content) != 2 || body.Content[0].Type != "text/plain" || body.Content[1].Type != "text/html" {
+		t.Fatalf("unexpected content %+v", body.Content)
+	}
+
+	if !strings.Contains(body.Content[1].Value, "Hola Jane Doe") {
+		t.Errorf("unexpected html body %q", body.Content[1].Value)
+	}
+}
+
+func TestEveryMailTypeHasSendGridTemplate(t *testing.T) {
+	for _, mailType := range enums.MailTypes {
+		if mailtransport.SendGridTemplateIdVariables[mailType.String()] == "" {
+			t.Errorf("%s has no sendgrid dynamic template variable", mailType.String())
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package tests_services
+
+import (
+	"flag"
+	"fmt"
+	"nft-raffle/dto"
+	"nft-raffle/enums"
+	"nft-raffle/services"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// go test ./tests/services -run TestMailTemplateGoldenFiles -update rewrites the golden files after a template change
+var updateGoldenFiles = flag.Bool("update", false, "rewrite the mail template golden files")
+
+var (
+	mailTemplateLocales = []string{"en", "es"}
+)
+
+// every key any template reads, so a golden file shows the whole mail
+func newGoldenMailRequest(mailType enums.MailType, locale string) *dto.MailRequest {
+	return &dto.MailRequest{
+		FromName:  "Expense Tracker",
+		FromEmail: "no-reply@example.com",
+		MailType:  mailType,
+		Locale:    locale,
+		Tos:       []dto.MailAddress{{Name: "Jane Doe", Address: "jane@example.com"}},
+		DynamicTemplateData: map[string]string{
+			"Full_Name":                "Jane <Doe>",
+			"Verify_Mail_Link":         "http://localhost:3000/verify?email=abc&code=def",
+			"Password_Reset_Mail_Link": "http://localhost:3000/reset-password?email=abc&code=def",
+			"Email_Change_Mail_Link":   "http://localhost:3000/verify-email-change?email=abc&code=def",
+			"Magic_Link":               "http://localhost:3000/magic-link?email=abc&code=def",
+			"New_Email":                "jane.new@example.com",
+			"Lockout_Minutes":          "15",
+			"Expiration_Minutes":       "10",
+			"Ip_Address":               "203.0.113.7",
+			"Week_Start":               "2024-01-01",
+			"Week_End":                 "2024-01-07",
+			"Total_Spend":              "420",
+			"Previous_Total_Spend":     "350",
+			"Spend_Change":             "+20.0%",
+			"Expense_Count":            "12",
+			"Top_Categories":           "Food 180, Transport 120, Entertainment 60",
+			"Largest_Expense_Label":    "Concert tickets",
+			"Largest_Expense_Type":     "Entertainment",
+			"Largest_Expense_Amount":   "60",
+			"Unsubscribe_Link":         "http://localhost:8000/api/mail/unsubscribe?token=abc",
+		},
+	}
+}
+
+func TestMailTemplateGoldenFiles(t *testing.T) {
+	renderer := services.NewMailTemplateRenderer()
+
+	for _, locale := range mailTemplateLocales {
+		for _, mailType := range enums.MailTypes {
+			renderedMail, err := renderer.Render(newGoldenMailRequest(mailType, locale))
+
+			if err != nil {
+				t.Errorf("%s %s: %s", locale, mailType.String(), err.Error())
+				continue
+			}
+
+			if renderedMail.Locale != locale {
+				t.Errorf("%s %s: rendered with locale %s, the template is missing", locale, mailType.String(), renderedMail.Locale)
+			}
+
+			got := fmt.Sprintf("Subject: %s\n\n--- text ---\n%s\n--- html ---\n%s", renderedMail.Subject, renderedMail.TextBody, renderedMail.HtmlBody)
+			goldenPath := filepath.Join("testdata", "mailTemplates", locale, mailType.String()+".golden")
+
+			if *updateGoldenFiles {
+				if err := os.MkdirAll(filepath.Dir(goldenPath), 0755); err != nil {
+					t.Fatal(err.Error())
+				}
+
+				if err := os.WriteFile(goldenPath, []byte(got), 0644); err != nil {
+					t.Fatal(err.Error())
+				}
+
+				continue
+			}
+
+			expected, err := os.ReadFile(goldenPath)
+
+			if err != nil {
+				t.Errorf("%s, run the test with -update to create it", err.Error())
+				continue
+			}
+
+			if got != string(expected) {
+				t.Errorf("%s does not match, run the test with -update if the change is intended:\n%s", goldenPath, got)
+			}
+		}
+	}
+}
+
+func TestResolveMailLocale(t *testing.T) {
+	renderer := services.NewMailTemplateRenderer()
+
+	tests := map[string]string{
+		"":         "en",
+		"en":       "en",
+		"en-GB":    "en",
+		"es":       "es",
+		"es-MX":    "es",
+		"ES_es":    "es",
+		"fr-FR":    "en",
+		"zh-Hant":  "en",
+		"es-419-x": "es",
+	}
+
+	for locale, expected := range tests {
+		if got := renderer.ResolveLocale(locale); got != expected {
+			t.Errorf("%q: expected %s, got %s", locale, expected, got)
+		}
+	}
+}
+
+func TestMailTemplateSampleDataIsComplete(t *testing.T) {
+	renderer := services.NewMailTemplateRenderer()
+
+	for _, mailType := range enums.MailTypes {
+		sampleData, ok := services.MailTemplateSampleData[mailType]
+
+		if !ok {
+			t.Errorf("%s has no sample data", mailType.String())
+			continue
+		}
+
+		for _, locale := range mailTemplateLocales {
+			renderedMail, err := renderer.Render(&dto.MailRequest{MailType: mailType, Locale: locale, DynamicTemplateData: sampleData})
+
+			if err != nil {
+				t.Errorf("%s %s: %s", locale, mailType.String(), err.Error())
+				continue
+			}
+
+			if len(renderedMail.MissingVariables) > 0 {
+				t.Errorf("%s %s: sample data misses %v", locale, mailType.String(), renderedMail.MissingVariables)
+			}
+		}
+	}
+}
+
+func TestMailTemplateMissingVariables(t *testing.T) {
+	renderedMail, err := services.NewMailTemplateRenderer().Render(&dto.MailRequest{
+		MailType:            enums.EmailChange,
+		DynamicTemplateData: map[string]string{"Full_Name": "Jane Doe"},
+	})
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{"Email_Change_Mail_Link", "New_Email"}
+
+	if fmt.Sprint(renderedMail.MissingVariables) != fmt.Sprint(expected) {
+		t.Errorf("expected missing variables %v, got %v", expected, renderedMail.MissingVariables)
+	}
+}
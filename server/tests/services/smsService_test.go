@@ -0,0 +1,81 @@
+package tests_services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nft-raffle/services"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTwilioSmsSender(t *testing.T) {
+	var form map[string]string
+	var accountSid, authToken, path string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		accountSid, authToken, _ = r.BasicAuth()
+
+		// the handler runs on the server goroutine, where t.Fatal must not be called
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("unable to parse the form: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		form = map[string]string{"To": r.PostForm.Get("To"), "From": r.PostForm.Get("From"), "Body": r.PostForm.Get("Body")}
+
+		if form["To"] == "+10000000000" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message":"invalid number"}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	smsSender := services.NewTwilioSmsSender(services.TwilioConfig{
+		ApiBaseUrl: server.URL,
+		AccountSid: "AC123",
+		AuthToken:  "secret",
+		FromNumber: "+15005550006",
+	}, server.Client())
+
+	if err := smsSender.SendSms("+14155552671", "code 123456"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if path != "/2010-04-01/Accounts/AC123/Messages.json" || accountSid != "AC123" || authToken != "secret" {
+		t.Errorf("unexpected request to %s as %s", path, accountSid)
+	}
+
+	if form["To"] != "+14155552671" || form["From"] != "+15005550006" || form["Body"] != "code 123456" {
+		t.Errorf("unexpected form %v", form)
+	}
+
+	if err := smsSender.SendSms("+10000000000", "code 123456"); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("expected the provider error to be returned, got %v", err)
+	}
+}
+
+func TestFileSmsSender(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "sms.log")
+	smsSender := services.NewFileSmsSender(outputFile)
+
+	if err := smsSender.SendSms("+14155552671", "code\n123456"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	output, err := os.ReadFile(outputFile)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !strings.Contains(string(output), "\t+14155552671\tcode 123456\n") {
+		t.Errorf("unexpected output %q", string(output))
+	}
+}